@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 
 	"gorm.io/gorm"
 	"imanager.io/config"
+	"imanager.io/internal/tracing"
 	"imanager.io/utils"
 )
 
@@ -26,3 +28,12 @@ func InitConfigAndDatabase() (config.Config, *gorm.DB) {
 
 	return cfg, db
 }
+
+// InitTracing installs the tracer provider; the returned func flushes pending spans.
+func InitTracing(cfg config.Config) func(context.Context) error {
+	shutdown, err := tracing.InitProvider(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	return shutdown
+}
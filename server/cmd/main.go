@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"imanager.io/utils"
 )
 
 func main() {
 	cfg, db := InitConfigAndDatabase()
+	shutdownTracing := InitTracing(cfg)
 	services := InitServices(db, &cfg)
 
 	// Initialize Router, passing gateway components
@@ -21,11 +25,31 @@ func main() {
 
 	go func() {
 		log.Println("      \n                   * \n.         * *A* *\n.        *A* **=** *A*\n        *\"\"\"* *|\"\"\"|* *\"\"\"*\n       *|***|* *|*+*|* *|***|*\n*********\"\"\"*___*//+\\\\*___*\"\"\"*********\n@@@@@@@@@@@@@@@@//   \\\\@@@@@@@@@@@@@@@@@\n###############||ព្រះពុទ្ធ||#################\nTTTTTTTTTTTTTTT||ព្រះធម័||TTTTTTTTTTTTTTTTT\nLLLLLLLLLLLLLL//ព្រះសង្ឃ\\\\LLLLLLLLLLLLLLLLL\n៚ សូមប្រោសប្រទានពរឱ្យប្រតិប័ត្តិការណ៍ប្រព្រឹត្តទៅជាធម្មតា ៚ \n៚ ជោគជ័យ   //  ៚សិរីសួរស្តី \\\\   ៚សុវត្តិភាព \n___________//___៚(♨️)៚__\\\\____________\n៚Application Service is Running Port: " + cfg.Service.Port)
-		if err := router.Run("0.0.0.0:" + cfg.Service.Port); err != nil {
+		// The plain listener also answers ACME http-01 challenges when enabled.
+		if err := http.ListenAndServe("0.0.0.0:"+cfg.Service.Port, services.Certs.HTTPHandler(router.Handler())); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
 
+	if services.Certs != nil {
+		go func() {
+			server := &http.Server{
+				Addr:      "0.0.0.0:" + cfg.TLS.Port,
+				Handler:   router.Handler(),
+				TLSConfig: services.Certs.TLSConfig(),
+			}
+			log.Printf("INFO: HTTPS listener running on port %s", cfg.TLS.Port)
+			if err := server.ListenAndServeTLS("", ""); err != nil {
+				log.Fatalf("HTTPS server error: %v", err)
+			}
+		}()
+	}
+
 	<-quit
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		utils.ErrorLog(err.Error(), "Failed to flush traces")
+	}
 	utils.InfoLog("Server exited cleanly", "")
 }
@@ -2,24 +2,23 @@ package main
 
 import (
 	"log"
-	"time"
+	"net/http"
+	"strings"
+	"sync"
 
+	"imanager.io/internal/alerting"
+	"imanager.io/internal/certstore"
+	"imanager.io/internal/eventbus"
 	gatewayio "imanager.io/internal/gateway.io"
 	service "imanager.io/internal/services"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"imanager.io/config"
 	"imanager.io/internal/api"
 	docker "imanager.io/internal/docker"
+	"imanager.io/utils"
 )
 
-type SendMessageRequest struct {
-	GroupID string `json:"groups"`
-	Message string `json:"msg"`
-	Phone   string `json:"phone"`
-}
-
 var whitelist = map[string]bool{
 	"/api/auth02/github/login":                 true,
 	"/api/auth02/logout":                       true,
@@ -52,17 +51,7 @@ func InitRoutes(cfg config.Config, s *Services) *gin.Engine {
 
 	r := gin.Default()
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{
-			"http://localhost:5173",
-			"http://192.168.50.102:5173",
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	r.Use(utils.CORSMiddleware(cfg.CORS))
 	containerHandler.RegisterRoutes(r)
 	imageHandler.RegisterRoutes(r)
 	return r
@@ -70,35 +59,107 @@ func InitRoutes(cfg config.Config, s *Services) *gin.Engine {
 
 // Example of how InitRoutes should look (adjust types as needed)
 func InitGatewayRoutes(cfg config.Config, s *Services) *gin.Engine {
-	cli, err := docker.NewDockerClient()
-	if err != nil {
-		log.Fatalf("Error creating Docker client: %v", err)
-	}
-	containerService := service.NewContainerService(cli)
-	imageService := service.NewImageService(cli)
+	containerService := service.NewContainerService(s.Docker)
+	imageService := service.NewImageService(s.Docker)
 	containerHandler := api.NewContainerHandler(containerService)
 	imageHandler := api.NewImageHandler(imageService)
 	configHandler := gatewayio.NewGatewayConfigHandler(s.BackendService) // Use the service layer
+	alertHandler := alerting.NewAlertHandler(s.Alerts)
+	eventHandler := eventbus.NewEventHandler(s.Events, utils.ManagementOrigins(cfg.CORS))
+	wsHandler := gatewayio.NewWebSocketHandler(s.Gateway)
+	metricsHandler := gatewayio.NewMetricsHandler(s.Gateway)
+	cacheHandler := gatewayio.NewCacheHandler(s.Gateway)
+	staticHandler := gatewayio.NewStaticHandler(s.Gateway)
+	certHandler := certstore.NewCertHandler(s.Certs)
 	r := gin.Default()
-	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{
-			"http://localhost:5173",
-			"http://192.168.50.102:5173",
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	r.UseH2C = true // gRPC and other HTTP/2 clients reach the gateway over cleartext
+	// Gateway routes answer CORS from their own BackendConfig policy.
+	r.Use(managementOnly(r, utils.CORSMiddleware(cfg.CORS)))
 	containerHandler.RegisterRoutes(r)
 	imageHandler.RegisterRoutes(r)
+	alertHandler.RegisterRoutes(r)
+	eventHandler.RegisterRoutes(r)
+	wsHandler.RegisterRoutes(r)
+	metricsHandler.RegisterRoutes(r)
+	cacheHandler.RegisterRoutes(r)
+	staticHandler.RegisterRoutes(r)
+	certHandler.RegisterRoutes(r)
 	r.POST("/config/v1/backends", configHandler.CreateConfig)
 	r.GET("/config/v1/backends", configHandler.ListConfigs)
+	r.PUT("/config/v1/backends/:id", configHandler.UpdateConfig)
+	r.DELETE("/config/v1/backends/:id", configHandler.DeleteConfig)
+	r.PUT("/config/v1/backends/:id/maintenance", configHandler.SetMaintenance)
+	r.GET("/config/v1/backends/:id/waiting-room", configHandler.GetWaitingRoom)
+	r.PUT("/config/v1/backends/:id/waiting-room", configHandler.SetWaitingRoom)
+	r.DELETE("/config/v1/backends/:id/waiting-room", configHandler.DeleteWaitingRoom)
+	r.PUT("/config/v1/backends/:id/endpoints/:endpointId/state", configHandler.SetEndpointState)
+	r.GET("/config/v1/backends/:id/endpoints/:endpointId/drain", configHandler.GetEndpointDrain)
+	r.GET("/config/v1/backends/:id/connections", configHandler.GetConnectionLogs)
+	r.GET("/config/v1/backends/:id/mirror", configHandler.GetMirrorLogs)
+	r.GET("/config/v1/backends/:id/mirror/summary", configHandler.GetMirrorSummary)
 	r.GET("/config/v1/backends/:id/history", configHandler.GetHealthHistory)
+	r.GET("/config/v1/backends/:id/history/rollups", configHandler.GetHealthRollups)
+	r.GET("/config/v1/reports/uptime", configHandler.GetUptimeReport)
+	r.GET("/config/v1/ip-bans", configHandler.ListIPBans)
+	r.POST("/config/v1/ip-bans", configHandler.CreateIPBan)
+	r.DELETE("/config/v1/ip-bans/:id", configHandler.DeleteIPBan)
+	r.GET("/config/v1/error-pages", configHandler.ListErrorPages)
+	r.PUT("/config/v1/error-pages", configHandler.SaveErrorPage)
+	r.DELETE("/config/v1/error-pages/:id", configHandler.DeleteErrorPage)
 	accessLogger := gatewayio.AccessLoggingHandler(s.Gateway)
 	r.NoRoute(accessLogger)
 	//r.NoRoute(gin.WrapH(s.Gateway))
 
 	return r
 }
+
+// managementOnly runs h for requests to registered management routes, including
+// their CORS preflights, and skips requests that fall through to the gateway.
+func managementOnly(r *gin.Engine, h gin.HandlerFunc) gin.HandlerFunc {
+	var (
+		once   sync.Once
+		routes gin.RoutesInfo
+	)
+	return func(c *gin.Context) {
+		if c.FullPath() != "" {
+			h(c)
+			return
+		}
+		// Preflights have no OPTIONS route, so match the method they ask about.
+		if method := c.GetHeader("Access-Control-Request-Method"); c.Request.Method == http.MethodOptions && method != "" {
+			once.Do(func() { routes = r.Routes() })
+			if hasRoute(routes, method, c.Request.URL.Path) {
+				h(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// hasRoute reports whether a registered route pattern matches method and path.
+func hasRoute(routes gin.RoutesInfo, method, path string) bool {
+	want := strings.Split(strings.Trim(path, "/"), "/")
+	for _, route := range routes {
+		if route.Method != method {
+			continue
+		}
+		pattern := strings.Split(strings.Trim(route.Path, "/"), "/")
+		if matchSegments(pattern, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, path []string) bool {
+	for i, seg := range pattern {
+		if strings.HasPrefix(seg, "*") {
+			return true
+		}
+		if i >= len(path) || (!strings.HasPrefix(seg, ":") && seg != path[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"gorm.io/gorm"
 	"imanager.io/config"
+	"imanager.io/internal/alerting"
+	"imanager.io/internal/certstore"
+	docker "imanager.io/internal/docker"
+	"imanager.io/internal/eventbus"
 	gatewayio "imanager.io/internal/gateway.io"
 )
 
@@ -12,6 +18,10 @@ type Services struct {
 	Config         *config.Config
 	Gateway        *gatewayio.Gateway
 	BackendService gatewayio.BackendService
+	Alerts         *alerting.Engine
+	Events         *eventbus.Bus
+	Docker         *docker.DockerClient
+	Certs          *certstore.Store // nil unless tls.enabled
 }
 
 func InitServices(db *gorm.DB, cfg *config.Config) *Services {
@@ -20,14 +30,79 @@ func InitServices(db *gorm.DB, cfg *config.Config) *Services {
 		log.Fatalf("Failed to run database migrations: %v", err)
 	}
 
-	gateway := &gatewayio.Gateway{}
+	cli, err := docker.NewDockerClient()
+	if err != nil {
+		log.Fatalf("Error creating Docker client: %v", err)
+	}
+
+	alertRepo := alerting.NewGormRepository(db)
+	if err := alertRepo.Migrate(); err != nil {
+		log.Fatalf("Failed to run alerting migrations: %v", err)
+	}
+	alerts, err := alerting.NewEngine(cfg.Alerting, alertRepo)
+	if err != nil {
+		log.Fatalf("Invalid alerting configuration: %v", err)
+	}
+	go alerts.Start()
+
+	bus := eventbus.NewBus(cfg.Events.BufferSize)
+	go cli.WatchEvents(context.Background(), alerts.HandleDockerEvent, bus.HandleDockerEvent)
+
+	gateway := &gatewayio.Gateway{
+		Alerts:                alerts,
+		Events:                bus,
+		AccessLogSampleRate:   cfg.Events.AccessLogSampleRate,
+		CertWarningDays:       cfg.TLS.ExpiryWarningDays,
+		SecretKey:             cfg.SecretKey,
+		StaticDir:             cfg.Gateway.Static.Dir,
+		StaticRootDir:         cfg.Gateway.Static.RootDir,
+		StaticMaxArchiveBytes: int64(cfg.Gateway.Static.MaxArchiveMB) << 20,
+		StaticMaxSiteBytes:    int64(cfg.Gateway.Static.MaxSiteMB) << 20,
+	}
+	ipFilter := gatewayio.IPFilter{Allow: cfg.Gateway.IPFilter.Allow, Deny: cfg.Gateway.IPFilter.Deny}
+	if err := gateway.SetIPPolicy(ipFilter, cfg.Gateway.TrustedProxies); err != nil {
+		log.Fatalf("Invalid gateway IP policy: %v", err)
+	}
+	if gateway.Cache, err = gatewayio.NewCacheStore(cfg.Gateway.Cache, cfg.Redis); err != nil {
+		log.Fatalf("Failed to open response cache store: %v", err)
+	}
 	backendService := gatewayio.NewBackendService(backendRepo, gateway)
 	gateway.BackendService = backendService
 	go gateway.StartHealthChecks()
 
+	retention := gatewayio.NewHistoryRetentionJob(backendRepo, gatewayio.RetentionPolicy{
+		RawRetention:    days(cfg.History.RawRetentionDays),
+		HourlyRetention: days(cfg.History.HourlyRetentionDays),
+		DailyRetention:  days(cfg.History.DailyRetentionDays),
+		Interval:        time.Duration(cfg.History.IntervalMinutes) * time.Minute,
+	})
+	go retention.Start()
+
+	var certs *certstore.Store
+	if cfg.TLS.Enabled {
+		certRepo := certstore.NewGormRepository(db)
+		if err := certRepo.Migrate(); err != nil {
+			log.Fatalf("Failed to run certificate migrations: %v", err)
+		}
+		certs, err = certstore.NewStore(cfg.TLS, cfg.SecretKey, certRepo, bus)
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		go certs.Start()
+		gateway.HTTPSPort = cfg.TLS.Port
+	}
+
 	return &Services{
 		Config:         cfg,
 		Gateway:        gateway,
 		BackendService: backendService, // Expose service for handlers
+		Alerts:         alerts,
+		Events:         bus,
+		Docker:         cli,
+		Certs:          certs,
 	}
 }
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
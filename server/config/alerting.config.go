@@ -0,0 +1,47 @@
+package config
+
+// AlertingConfig holds notification channels and alert rules.
+type AlertingConfig struct {
+	Enabled         bool           `yaml:"enabled"`
+	EvaluateSeconds int            `yaml:"evaluateSeconds"`
+	RepeatMinutes   int            `yaml:"repeatMinutes"` // re-send firing alerts; 0 sends once
+	Channels        []AlertChannel `yaml:"channels"`
+	Rules           []AlertRule    `yaml:"rules"`
+}
+
+// AlertChannel is a notifier destination. Type is webhook, telegram, slack or smtp;
+// only the fields of that type are used.
+type AlertChannel struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+
+	// webhook, slack (and telegram API base override)
+	URL    string `yaml:"url" json:"-"`
+	Secret string `yaml:"secret" json:"-"` // webhook HMAC-SHA256 key
+
+	// telegram
+	BotToken string `yaml:"botToken" json:"-"`
+	ChatID   string `yaml:"chatId" json:"-"`
+
+	// smtp
+	Host     string   `yaml:"host" json:"-"`
+	Port     string   `yaml:"port" json:"-"`
+	Username string   `yaml:"username" json:"-"`
+	Password string   `yaml:"password" json:"-"`
+	From     string   `yaml:"from" json:"-"`
+	To       []string `yaml:"to" json:"-"`
+}
+
+// AlertRule describes when an alert fires. Type is endpoint_down, route_down,
+// error_rate, container_exited or upstream_cert.
+type AlertRule struct {
+	Name             string   `yaml:"name" json:"name"`
+	Type             string   `yaml:"type" json:"type"`
+	BackendID        string   `yaml:"backendId" json:"backendId,omitempty"` // empty matches every route
+	ForSeconds       int      `yaml:"forSeconds" json:"forSeconds"`
+	ThresholdPercent float64  `yaml:"thresholdPercent" json:"thresholdPercent,omitempty"`
+	WindowSeconds    int      `yaml:"windowSeconds" json:"windowSeconds,omitempty"`
+	MinRequests      int      `yaml:"minRequests" json:"minRequests,omitempty"`
+	ExpiryDays       int      `yaml:"expiryDays" json:"expiryDays,omitempty"` // upstream_cert: days before notAfter to fire
+	Channels         []string `yaml:"channels" json:"channels"`               // empty notifies every channel
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 
 	"gopkg.in/yaml.v2"
 	"gorm.io/driver/mysql"
@@ -19,6 +20,13 @@ type Config struct {
 	Redis       ConfigRedis    `yaml:"redis"`
 	Service     ServiceConfig  `yaml:"service"`
 	Github      GitConfig      `yaml:"github"`
+	Tracing     TracingConfig  `yaml:"tracing"`
+	History     HistoryConfig  `yaml:"history"`
+	Alerting    AlertingConfig `yaml:"alerting"`
+	Events      EventsConfig   `yaml:"events"`
+	TLS         TLSConfig      `yaml:"tls"`
+	Gateway     GatewayConfig  `yaml:"gateway"`
+	CORS        CORSConfig     `yaml:"cors"`
 }
 
 // Server
@@ -28,6 +36,97 @@ type ServiceConfig struct {
 	TimeZone string `yaml:"timeZone"`
 }
 
+// Tracing
+type TracingConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Exporter    string   `yaml:"exporter"` // stdout, file or otlp
+	FilePath    string   `yaml:"filePath"`
+	Endpoint    string   `yaml:"endpoint"` // OTLP/HTTP collector, e.g. localhost:4318
+	ServiceName string   `yaml:"serviceName"`
+	SampleRatio *float64 `yaml:"sampleRatio"` // fraction of new traces sampled, 0..1; unset samples all
+}
+
+// Health history retention (0 falls back to the built-in defaults)
+type HistoryConfig struct {
+	RawRetentionDays    int `yaml:"rawRetentionDays"`
+	HourlyRetentionDays int `yaml:"hourlyRetentionDays"`
+	DailyRetentionDays  int `yaml:"dailyRetentionDays"` // 0 keeps daily rollups forever
+	IntervalMinutes     int `yaml:"intervalMinutes"`
+}
+
+// Live event stream
+type EventsConfig struct {
+	BufferSize          int     `yaml:"bufferSize"`          // events kept for Last-Event-ID replay
+	AccessLogSampleRate float64 `yaml:"accessLogSampleRate"` // 0..1 fraction of access logs published
+}
+
+// Management API CORS; proxied routes use their own BackendConfig policy.
+// Empty lists fall back to the development defaults (localhost:5173).
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allowOrigins"` // exact origins, "*" or wildcards like "https://*.example.com"
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	MaxAgeSeconds    int      `yaml:"maxAgeSeconds"`
+}
+
+// Validate rejects allowCredentials with a "*" origin: the middleware would echo
+// every Origin back with credentials, letting any site read the API as the user.
+func (c CORSConfig) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowOrigins, "*") {
+		return fmt.Errorf("cors: allowCredentials cannot be combined with \"*\" in allowOrigins")
+	}
+	return nil
+}
+
+// Gateway-wide client policies
+type GatewayConfig struct {
+	TrustedProxies []string       `yaml:"trustedProxies"` // CIDRs whose X-Forwarded-For is believed
+	IPFilter       IPFilterConfig `yaml:"ipFilter"`
+	Cache          CacheConfig    `yaml:"cache"`
+	Static         StaticConfig   `yaml:"static"`
+}
+
+// Global CIDR allow/deny lists, applied before the per-route ones
+type IPFilterConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Response cache backing store for routes with a cache policy
+type CacheConfig struct {
+	Store       string `yaml:"store"`       // "memory" (default) or "redis"
+	MaxMemoryMB int    `yaml:"maxMemoryMB"` // memory store size cap; 0 uses 256
+	RedisDB     int    `yaml:"redisDB"`     // database number on the shared redis server
+}
+
+// Storage for the uploaded archives of STATIC routes
+type StaticConfig struct {
+	Dir          string `yaml:"dir"`          // unpacked sites live in <dir>/<backend id>; empty uses data/static
+	RootDir      string `yaml:"rootDir"`      // host directories set as a route root must be inside it; empty disables them
+	MaxArchiveMB int    `yaml:"maxArchiveMB"` // upload size cap; 0 uses 100
+	MaxSiteMB    int    `yaml:"maxSiteMB"`    // unpacked size cap; 0 uses 500
+}
+
+// HTTPS listener and certificate store
+type TLSConfig struct {
+	Enabled           bool       `yaml:"enabled"`
+	Port              string     `yaml:"port"`
+	ExpiryWarningDays int        `yaml:"expiryWarningDays"` // warn when a stored certificate expires within this many days
+	ACME              ACMEConfig `yaml:"acme"`
+}
+
+// Automatic certificate issuance (Let's Encrypt, or pebble for local testing)
+type ACMEConfig struct {
+	Enabled            bool     `yaml:"enabled"`
+	DirectoryURL       string   `yaml:"directoryUrl"`
+	Email              string   `yaml:"email"`
+	Domains            []string `yaml:"domains"`            // host names allowed to request certificates
+	CABundle           string   `yaml:"caBundle"`           // PEM file trusted for the ACME server (pebble's minica)
+	InsecureSkipVerify bool     `yaml:"insecureSkipVerify"` // testing only
+}
+
 // Redis
 type ConfigRedis struct {
 	Host     string `yaml:"host"`
@@ -58,6 +157,9 @@ func LoadConfig(filename string) (Config, error) {
 	if err != nil {
 		return config, err
 	}
+	if err := config.CORS.Validate(); err != nil {
+		return config, err
+	}
 	return config, nil
 }
 
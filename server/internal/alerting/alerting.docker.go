@@ -0,0 +1,26 @@
+package alerting
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// HandleDockerEvent feeds container lifecycle events from the Docker event stream.
+func (e *Engine) HandleDockerEvent(msg events.Message) {
+	if msg.Type != events.ContainerEventType {
+		return
+	}
+	at := time.Unix(0, msg.TimeNano)
+	if msg.TimeNano == 0 {
+		at = time.Now()
+	}
+	e.ObserveContainer(ContainerEvent{
+		ContainerID:   msg.Actor.ID,
+		ContainerName: strings.TrimPrefix(msg.Actor.Attributes["name"], "/"),
+		Action:        string(msg.Action),
+		ExitCode:      msg.Actor.Attributes["exitCode"],
+		At:            at,
+	})
+}
@@ -0,0 +1,614 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"imanager.io/config"
+)
+
+const (
+	// staleAfter drops endpoint/route state that has not been probed recently
+	// (e.g. the route was deleted), which resolves any alert it was driving.
+	staleAfter = 2 * time.Minute
+	// requestBucket is the resolution of the error-rate sliding window.
+	requestBucket = 10 * time.Second
+	// maxErrorWindow bounds how much request history is kept.
+	maxErrorWindow = time.Hour
+)
+
+type endpointKey struct {
+	backendID  string
+	endpointID uint
+}
+
+type endpointState struct {
+	obs       EndpointObservation
+	downSince time.Time
+}
+
+type routeState struct {
+	pathPrefix string
+	downSince  time.Time
+	lastSeen   time.Time
+}
+
+type containerState struct {
+	name     string
+	exitCode string
+	exitedAt time.Time
+}
+
+type requestCount struct {
+	total  int64
+	errors int64
+}
+
+// Engine evaluates alert rules against gateway and Docker observations and
+// sends deduplicated firing/resolved notifications.
+type Engine struct {
+	enabled   bool
+	rules     []config.AlertRule
+	notifiers map[string]Notifier
+	repo      Repository
+	interval  time.Duration
+	repeat    time.Duration
+
+	mu         sync.Mutex
+	endpoints  map[endpointKey]*endpointState
+	certs      map[endpointKey]*CertificateObservation
+	routes     map[string]*routeState
+	requests   map[string]map[int64]*requestCount
+	containers map[string]*containerState
+	active     map[string]*Alert
+	silences   []*Silence
+}
+
+// NewEngine validates the channels and rules in cfg.
+func NewEngine(cfg config.AlertingConfig, repo Repository) (*Engine, error) {
+	e := &Engine{
+		enabled:    cfg.Enabled,
+		rules:      cfg.Rules,
+		notifiers:  make(map[string]Notifier),
+		repo:       repo,
+		interval:   time.Duration(cfg.EvaluateSeconds) * time.Second,
+		repeat:     time.Duration(cfg.RepeatMinutes) * time.Minute,
+		endpoints:  make(map[endpointKey]*endpointState),
+		certs:      make(map[endpointKey]*CertificateObservation),
+		routes:     make(map[string]*routeState),
+		requests:   make(map[string]map[int64]*requestCount),
+		containers: make(map[string]*containerState),
+		active:     make(map[string]*Alert),
+	}
+	if e.interval <= 0 {
+		e.interval = 10 * time.Second
+	}
+
+	for _, ch := range cfg.Channels {
+		n, err := NewNotifier(ch)
+		if err != nil {
+			return nil, err
+		}
+		e.notifiers[ch.Name] = n
+	}
+	for _, rule := range cfg.Rules {
+		switch rule.Type {
+		case RuleEndpointDown, RuleRouteDown, RuleContainerExited, RuleUpstreamCert:
+		case RuleErrorRate:
+			if rule.ThresholdPercent <= 0 || rule.WindowSeconds <= 0 {
+				return nil, fmt.Errorf("rule %s: error_rate requires thresholdPercent and windowSeconds", rule.Name)
+			}
+			if time.Duration(rule.WindowSeconds)*time.Second > maxErrorWindow {
+				return nil, fmt.Errorf("rule %s: windowSeconds may not exceed %d", rule.Name, int(maxErrorWindow.Seconds()))
+			}
+		default:
+			return nil, fmt.Errorf("rule %s: unsupported type %q", rule.Name, rule.Type)
+		}
+		for _, name := range rule.Channels {
+			if _, ok := e.notifiers[name]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown channel %q", rule.Name, name)
+			}
+		}
+	}
+	return e, nil
+}
+
+// Start loads persisted silences and evaluates rules on every interval. It blocks.
+func (e *Engine) Start() {
+	if e == nil || !e.enabled {
+		return
+	}
+	if err := e.reloadSilences(); err != nil {
+		log.Printf("ERROR: Alerting failed to load silences: %v", err)
+	}
+	log.Printf("INFO: Alerting engine started with %d rule(s) and %d channel(s)", len(e.rules), len(e.notifiers))
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		e.Evaluate(now)
+	}
+}
+
+// ObserveEndpoint records a health probe. Safe to call on a nil or disabled engine.
+func (e *Engine) ObserveEndpoint(obs EndpointObservation) {
+	if e == nil || !e.enabled {
+		return
+	}
+	if obs.At.IsZero() {
+		obs.At = time.Now()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := endpointKey{obs.BackendID, obs.EndpointID}
+	st, ok := e.endpoints[key]
+	if !ok {
+		st = &endpointState{}
+		e.endpoints[key] = st
+	}
+	if obs.Healthy {
+		st.downSince = time.Time{}
+	} else if st.downSince.IsZero() {
+		st.downSince = obs.At
+	}
+	st.obs = obs
+
+	route, ok := e.routes[obs.BackendID]
+	if !ok {
+		route = &routeState{}
+		e.routes[obs.BackendID] = route
+	}
+	route.pathPrefix = obs.PathPrefix
+	route.lastSeen = obs.At
+	if obs.RouteHealthy {
+		route.downSince = time.Time{}
+	} else if route.downSince.IsZero() {
+		route.downSince = obs.At
+	}
+}
+
+// ObserveCertificate records the certificate an upstream presented. Safe to call on a nil or disabled engine.
+func (e *Engine) ObserveCertificate(obs CertificateObservation) {
+	if e == nil || !e.enabled {
+		return
+	}
+	if obs.At.IsZero() {
+		obs.At = time.Now()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.certs[endpointKey{obs.BackendID, obs.EndpointID}] = &obs
+}
+
+// ObserveRequest counts a proxied request for error-rate rules.
+func (e *Engine) ObserveRequest(backendID string, statusCode int) {
+	if e == nil || !e.enabled || backendID == "" {
+		return
+	}
+	slot := time.Now().Truncate(requestBucket).Unix()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	buckets, ok := e.requests[backendID]
+	if !ok {
+		buckets = make(map[int64]*requestCount)
+		e.requests[backendID] = buckets
+	}
+	count, ok := buckets[slot]
+	if !ok {
+		count = &requestCount{}
+		buckets[slot] = count
+	}
+	count.total++
+	if statusCode >= 500 {
+		count.errors++
+	}
+}
+
+// ObserveContainer records a Docker container lifecycle event.
+func (e *Engine) ObserveContainer(ev ContainerEvent) {
+	if e == nil || !e.enabled {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// docker stop sends die (143) followed by stop; a clean exit or a stop is
+	// intentional and must not leave a container_exited alert behind.
+	switch ev.Action {
+	case "die":
+		if ev.ExitCode == "0" {
+			delete(e.containers, ev.ContainerID)
+			return
+		}
+		e.containers[ev.ContainerID] = &containerState{name: ev.ContainerName, exitCode: ev.ExitCode, exitedAt: ev.At}
+	case "start", "restart", "stop", "destroy":
+		delete(e.containers, ev.ContainerID)
+	}
+}
+
+// Evaluate compares rule conditions with the active alerts and sends notifications for changes.
+func (e *Engine) Evaluate(now time.Time) {
+	e.mu.Lock()
+	e.pruneLocked(now)
+
+	desired := make(map[string]*Alert)
+	for _, rule := range e.rules {
+		for _, a := range e.conditionsLocked(rule, now) {
+			a.channels = rule.Channels
+			desired[a.Fingerprint] = a
+		}
+	}
+
+	var outgoing []*Notification
+	var targets [][]string
+	send := func(a *Alert, status string) {
+		snapshot := *a
+		snapshot.Status = status
+		outgoing = append(outgoing, buildNotification(&snapshot, now))
+		targets = append(targets, a.channels)
+	}
+
+	for fp, want := range desired {
+		a, ok := e.active[fp]
+		if !ok {
+			a = want
+			a.Status = StatusFiring
+			e.active[fp] = a
+		} else {
+			a.Summary = want.Summary
+		}
+		a.Silenced = e.silencedLocked(a, now)
+		if a.Silenced {
+			continue
+		}
+		if !a.notified || (e.repeat > 0 && now.Sub(a.lastNotifiedAt) >= e.repeat) {
+			a.notified = true
+			a.lastNotifiedAt = now
+			send(a, StatusFiring)
+		}
+	}
+	for fp, a := range e.active {
+		if _, ok := desired[fp]; ok {
+			continue
+		}
+		delete(e.active, fp)
+		endsAt := now
+		a.EndsAt = &endsAt
+		if a.notified {
+			send(a, StatusResolved)
+		}
+		log.Printf("INFO: Alert resolved: %s", a.Summary)
+	}
+	e.mu.Unlock()
+
+	for i, n := range outgoing {
+		e.dispatch(n, targets[i])
+	}
+}
+
+// conditionsLocked returns the alerts a rule wants firing right now.
+func (e *Engine) conditionsLocked(rule config.AlertRule, now time.Time) []*Alert {
+	hold := time.Duration(rule.ForSeconds) * time.Second
+	matches := func(backendID string) bool { return rule.BackendID == "" || rule.BackendID == backendID }
+
+	var alerts []*Alert
+	switch rule.Type {
+	case RuleEndpointDown:
+		for key, st := range e.endpoints {
+			if st.downSince.IsZero() || now.Sub(st.downSince) < hold || !matches(key.backendID) {
+				continue
+			}
+			summary := fmt.Sprintf("Endpoint %s of route %s has been DOWN for %s",
+				st.obs.URL, st.obs.PathPrefix, now.Sub(st.downSince).Round(time.Second))
+			if st.obs.Reason != "" {
+				summary += ": " + st.obs.Reason
+			}
+			alerts = append(alerts, &Alert{
+				Fingerprint: fmt.Sprintf("%s/%s/%d", rule.Name, key.backendID, key.endpointID),
+				BackendID:   key.backendID,
+				PathPrefix:  st.obs.PathPrefix,
+				EndpointID:  key.endpointID,
+				EndpointURL: st.obs.URL,
+				StartsAt:    st.downSince,
+				Summary:     summary,
+			})
+		}
+	case RuleRouteDown:
+		for backendID, st := range e.routes {
+			if st.downSince.IsZero() || now.Sub(st.downSince) < hold || !matches(backendID) {
+				continue
+			}
+			alerts = append(alerts, &Alert{
+				Fingerprint: fmt.Sprintf("%s/%s", rule.Name, backendID),
+				BackendID:   backendID,
+				PathPrefix:  st.pathPrefix,
+				StartsAt:    st.downSince,
+				Summary: fmt.Sprintf("All endpoints of route %s have been DOWN for %s",
+					st.pathPrefix, now.Sub(st.downSince).Round(time.Second)),
+			})
+		}
+	case RuleErrorRate:
+		window := time.Duration(rule.WindowSeconds) * time.Second
+		since := now.Add(-window).Unix()
+		for backendID, buckets := range e.requests {
+			if !matches(backendID) {
+				continue
+			}
+			var total, errors int64
+			for slot, count := range buckets {
+				if slot >= since {
+					total += count.total
+					errors += count.errors
+				}
+			}
+			if total == 0 || total < int64(rule.MinRequests) {
+				continue
+			}
+			rate := float64(errors) / float64(total) * 100
+			if rate <= rule.ThresholdPercent {
+				continue
+			}
+			prefix := ""
+			if route, ok := e.routes[backendID]; ok {
+				prefix = route.pathPrefix
+			}
+			alerts = append(alerts, &Alert{
+				Fingerprint: fmt.Sprintf("%s/%s", rule.Name, backendID),
+				BackendID:   backendID,
+				PathPrefix:  prefix,
+				StartsAt:    now,
+				Summary: fmt.Sprintf("Route %s 5xx error rate is %.1f%% (%d/%d) over %s, above %.1f%%",
+					displayRoute(prefix, backendID), rate, errors, total, window, rule.ThresholdPercent),
+			})
+		}
+	case RuleUpstreamCert:
+		days := rule.ExpiryDays
+		if days <= 0 {
+			days = defaultCertExpiryDays
+		}
+		for key, obs := range e.certs {
+			if !matches(key.backendID) {
+				continue
+			}
+			var problems []string
+			if left := obs.NotAfter.Sub(now); left < time.Duration(days)*24*time.Hour {
+				if left <= 0 {
+					problems = append(problems, "expired on "+obs.NotAfter.Format(time.RFC3339))
+				} else {
+					problems = append(problems, fmt.Sprintf("expires in %d days (%s)", int(left.Hours()/24), obs.NotAfter.Format(time.RFC3339)))
+				}
+			}
+			if obs.HostnameError != "" {
+				problems = append(problems, obs.HostnameError)
+			}
+			if len(problems) == 0 {
+				continue
+			}
+			alerts = append(alerts, &Alert{
+				Fingerprint: fmt.Sprintf("%s/%s/%d", rule.Name, key.backendID, key.endpointID),
+				BackendID:   key.backendID,
+				PathPrefix:  obs.PathPrefix,
+				EndpointID:  key.endpointID,
+				EndpointURL: obs.URL,
+				StartsAt:    now,
+				Summary: fmt.Sprintf("Certificate %s of endpoint %s on route %s %s",
+					obs.Subject, obs.URL, obs.PathPrefix, strings.Join(problems, "; ")),
+			})
+		}
+	case RuleContainerExited:
+		for id, st := range e.containers {
+			if now.Sub(st.exitedAt) < hold {
+				continue
+			}
+			alerts = append(alerts, &Alert{
+				Fingerprint:   fmt.Sprintf("%s/%s", rule.Name, id),
+				ContainerID:   id,
+				ContainerName: st.name,
+				StartsAt:      st.exitedAt,
+				Summary:       fmt.Sprintf("Container %s exited with code %s", st.name, st.exitCode),
+			})
+		}
+	}
+
+	for _, a := range alerts {
+		a.RuleName = rule.Name
+		a.RuleType = rule.Type
+	}
+	return alerts
+}
+
+// pruneLocked drops stale probe state and request buckets outside the largest window.
+func (e *Engine) pruneLocked(now time.Time) {
+	for key, st := range e.endpoints {
+		if now.Sub(st.obs.At) > staleAfter {
+			delete(e.endpoints, key)
+		}
+	}
+	for key, obs := range e.certs {
+		if now.Sub(obs.At) > staleAfter {
+			delete(e.certs, key)
+		}
+	}
+	for id, st := range e.routes {
+		if now.Sub(st.lastSeen) > staleAfter {
+			delete(e.routes, id)
+		}
+	}
+	oldest := now.Add(-maxErrorWindow).Unix()
+	for id, buckets := range e.requests {
+		for slot := range buckets {
+			if slot < oldest {
+				delete(buckets, slot)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(e.requests, id)
+		}
+	}
+}
+
+func (e *Engine) silencedLocked(a *Alert, now time.Time) bool {
+	for _, s := range e.silences {
+		if s.Matches(a, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch sends asynchronously so slow channels never block evaluation.
+func (e *Engine) dispatch(n *Notification, channels []string) {
+	if len(channels) == 0 {
+		for name := range e.notifiers {
+			channels = append(channels, name)
+		}
+	}
+	for _, name := range channels {
+		notifier, ok := e.notifiers[name]
+		if !ok {
+			continue
+		}
+		go func(name string, notifier Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := notifier.Notify(ctx, n); err != nil {
+				log.Printf("ERROR: Alert notification via %s failed: %v", name, err)
+			}
+		}(name, notifier)
+	}
+}
+
+// ActiveAlerts returns firing alerts, oldest first.
+func (e *Engine) ActiveAlerts() []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]*Alert, 0, len(e.active))
+	for _, a := range e.active {
+		snapshot := *a
+		list = append(list, &snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.Before(list[j].StartsAt) })
+	return list
+}
+
+// Rules returns the configured rules.
+func (e *Engine) Rules() []config.AlertRule {
+	return e.rules
+}
+
+// Enabled reports whether rules are being evaluated.
+func (e *Engine) Enabled() bool {
+	return e != nil && e.enabled
+}
+
+// CreateSilence persists a silence and applies it on the next evaluation.
+func (e *Engine) CreateSilence(dto *SilenceDTO) (*Silence, error) {
+	if dto.RuleName == "" && dto.BackendID == "" && dto.EndpointURL == "" && dto.ContainerName == "" {
+		return nil, fmt.Errorf("a silence needs at least one of ruleName, backendId, endpointUrl or containerName")
+	}
+	start := dto.StartsAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	silence := &Silence{
+		RuleName:      dto.RuleName,
+		BackendID:     dto.BackendID,
+		EndpointURL:   dto.EndpointURL,
+		ContainerName: dto.ContainerName,
+		Comment:       dto.Comment,
+		CreatedBy:     dto.CreatedBy,
+		StartsAt:      start,
+		EndsAt:        start.Add(time.Duration(dto.DurationMinutes) * time.Minute),
+	}
+	if err := e.repo.CreateSilence(silence); err != nil {
+		return nil, fmt.Errorf("failed to save silence: %w", err)
+	}
+	if err := e.reloadSilences(); err != nil {
+		log.Printf("ERROR: Alerting failed to reload silences: %v", err)
+	}
+	return silence, nil
+}
+
+// ListSilences returns silences that have not ended yet.
+func (e *Engine) ListSilences() ([]*Silence, error) {
+	return e.repo.ListSilences(time.Now())
+}
+
+// DeleteSilence expires a silence immediately.
+func (e *Engine) DeleteSilence(id uint) error {
+	if err := e.repo.DeleteSilence(id); err != nil {
+		return err
+	}
+	return e.reloadSilences()
+}
+
+func (e *Engine) reloadSilences() error {
+	silences, err := e.repo.ListSilences(time.Now())
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.silences = silences
+	e.mu.Unlock()
+	return nil
+}
+
+// SendMessage delivers a free-form message to the named channels (all when empty).
+func (e *Engine) SendMessage(req *SendMessageRequest) ([]string, error) {
+	var channels []string
+	for _, name := range strings.Split(req.GroupID, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := e.notifiers[name]; !ok {
+			return nil, fmt.Errorf("unknown channel %q", name)
+		}
+		channels = append(channels, name)
+	}
+	if len(e.notifiers) == 0 {
+		return nil, fmt.Errorf("no notification channels configured")
+	}
+	if len(channels) == 0 {
+		for name := range e.notifiers {
+			channels = append(channels, name)
+		}
+	}
+	e.dispatch(&Notification{
+		Status: StatusMessage,
+		Title:  "iManager message",
+		Text:   req.Message,
+		SentAt: time.Now(),
+	}, channels)
+	return channels, nil
+}
+
+func buildNotification(a *Alert, now time.Time) *Notification {
+	title := fmt.Sprintf("[%s] %s", strings.ToUpper(a.Status), a.RuleName)
+	text := a.Summary
+	if a.Status == StatusResolved {
+		text = fmt.Sprintf("Resolved after %s: %s", now.Sub(a.StartsAt).Round(time.Second), a.Summary)
+	}
+	return &Notification{
+		Status: a.Status,
+		Title:  title,
+		Text:   text,
+		Alert:  a,
+		SentAt: now,
+	}
+}
+
+func displayRoute(prefix, backendID string) string {
+	if prefix != "" {
+		return prefix
+	}
+	return backendID
+}
@@ -0,0 +1,178 @@
+package alerting
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"imanager.io/config"
+)
+
+// recordingNotifier hands every notification to a channel.
+type recordingNotifier chan *Notification
+
+func (r recordingNotifier) Notify(_ context.Context, n *Notification) error {
+	r <- n
+	return nil
+}
+
+func (r recordingNotifier) next(t *testing.T) *Notification {
+	t.Helper()
+	select {
+	case n := <-r:
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification sent")
+		return nil
+	}
+}
+
+func (r recordingNotifier) none(t *testing.T) {
+	t.Helper()
+	select {
+	case n := <-r:
+		t.Fatalf("unexpected notification %q", n.Title)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNewEngineValidatesRules(t *testing.T) {
+	channels := []config.AlertChannel{{Name: "hook", Type: "webhook", URL: "http://hooks.test"}}
+	tests := []struct {
+		name string
+		rule config.AlertRule
+		ok   bool
+	}{
+		{"endpoint down", config.AlertRule{Name: "r", Type: RuleEndpointDown, Channels: []string{"hook"}}, true},
+		{"error rate", config.AlertRule{Name: "r", Type: RuleErrorRate, ThresholdPercent: 5, WindowSeconds: 300}, true},
+		{"error rate without threshold", config.AlertRule{Name: "r", Type: RuleErrorRate, WindowSeconds: 300}, false},
+		{"error rate window too long", config.AlertRule{Name: "r", Type: RuleErrorRate, ThresholdPercent: 5, WindowSeconds: 7200}, false},
+		{"unknown type", config.AlertRule{Name: "r", Type: "disk_full"}, false},
+		{"unknown channel", config.AlertRule{Name: "r", Type: RuleRouteDown, Channels: []string{"pager"}}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewEngine(config.AlertingConfig{Enabled: true, Channels: channels, Rules: []config.AlertRule{tt.rule}}, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: NewEngine = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+	if _, err := NewEngine(config.AlertingConfig{Channels: []config.AlertChannel{{Name: "hook", Type: "webhook"}}}, nil); err == nil {
+		t.Error("webhook channel without url accepted")
+	}
+}
+
+func TestObserveContainer(t *testing.T) {
+	e, err := NewEngine(config.AlertingConfig{Enabled: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tests := []struct {
+		name   string
+		events []string // action:exitCode
+		exited bool
+	}{
+		{"crash", []string{"die:1"}, true},
+		{"clean exit", []string{"die:0"}, false},
+		{"docker stop", []string{"die:143", "stop:"}, false},
+		{"crash then restart", []string{"die:137", "start:"}, false},
+		{"restart then crash", []string{"start:", "die:2"}, true},
+	}
+	for _, tt := range tests {
+		for _, ev := range tt.events {
+			action, code, _ := strings.Cut(ev, ":")
+			e.ObserveContainer(ContainerEvent{ContainerID: tt.name, ContainerName: tt.name, Action: action, ExitCode: code, At: now})
+		}
+		if _, exited := e.containers[tt.name]; exited != tt.exited {
+			t.Errorf("%s: exited = %t, want %t", tt.name, exited, tt.exited)
+		}
+	}
+
+	var disabled *Engine
+	disabled.ObserveContainer(ContainerEvent{Action: "die", ExitCode: "1"})
+}
+
+func TestSilenceMatches(t *testing.T) {
+	now := time.Now()
+	alert := &Alert{RuleName: "down", BackendID: "b1", EndpointURL: "http://a", ContainerName: "api"}
+	tests := []struct {
+		name    string
+		silence Silence
+		want    bool
+	}{
+		{"rule", Silence{RuleName: "down"}, true},
+		{"rule and route", Silence{RuleName: "down", BackendID: "b1"}, true},
+		{"other route", Silence{RuleName: "down", BackendID: "b2"}, false},
+		{"other endpoint", Silence{EndpointURL: "http://b"}, false},
+		{"container", Silence{ContainerName: "api"}, true},
+		{"ended", Silence{RuleName: "down", StartsAt: now.Add(-time.Hour), EndsAt: now}, false},
+		{"not started", Silence{RuleName: "down", StartsAt: now.Add(time.Minute), EndsAt: now.Add(time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if tt.silence.EndsAt.IsZero() {
+			tt.silence.StartsAt, tt.silence.EndsAt = now.Add(-time.Minute), now.Add(time.Minute)
+		}
+		if got := tt.silence.Matches(alert, now); got != tt.want {
+			t.Errorf("%s: Matches = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateFiresAndResolves(t *testing.T) {
+	e, err := NewEngine(config.AlertingConfig{
+		Enabled: true,
+		Rules:   []config.AlertRule{{Name: "crashed", Type: RuleContainerExited, ForSeconds: 30}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(recordingNotifier, 4)
+	e.notifiers["test"] = sent
+
+	start := time.Now()
+	e.ObserveContainer(ContainerEvent{ContainerID: "c1", ContainerName: "api", Action: "die", ExitCode: "1", At: start})
+
+	e.Evaluate(start.Add(10 * time.Second))
+	sent.none(t) // still inside forSeconds
+
+	e.Evaluate(start.Add(time.Minute))
+	if n := sent.next(t); n.Status != StatusFiring || n.Alert.ContainerName != "api" {
+		t.Errorf("firing notification = %+v", n)
+	}
+	e.Evaluate(start.Add(2 * time.Minute))
+	sent.none(t) // repeat is off, so a firing alert is sent once
+	if alerts := e.ActiveAlerts(); len(alerts) != 1 {
+		t.Errorf("active alerts = %d, want 1", len(alerts))
+	}
+
+	e.ObserveContainer(ContainerEvent{ContainerID: "c1", Action: "start", At: start.Add(3 * time.Minute)})
+	e.Evaluate(start.Add(3 * time.Minute))
+	if n := sent.next(t); n.Status != StatusResolved {
+		t.Errorf("resolved notification = %+v", n)
+	}
+	if alerts := e.ActiveAlerts(); len(alerts) != 0 {
+		t.Errorf("active alerts after resolve = %d", len(alerts))
+	}
+}
+
+func TestEvaluateSilenced(t *testing.T) {
+	e, err := NewEngine(config.AlertingConfig{
+		Enabled: true,
+		Rules:   []config.AlertRule{{Name: "crashed", Type: RuleContainerExited}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(recordingNotifier, 4)
+	e.notifiers["test"] = sent
+
+	now := time.Now()
+	e.silences = []*Silence{{ContainerName: "api", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}}
+	e.ObserveContainer(ContainerEvent{ContainerID: "c1", ContainerName: "api", Action: "die", ExitCode: "1", At: now})
+	e.Evaluate(now)
+	sent.none(t)
+	if alerts := e.ActiveAlerts(); len(alerts) != 1 || !alerts[0].Silenced {
+		t.Errorf("active alerts = %+v, want one silenced alert", alerts)
+	}
+}
@@ -0,0 +1,100 @@
+package alerting
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"imanager.io/utils"
+)
+
+// AlertHandler exposes active alerts, rules, silences and manual messages.
+type AlertHandler struct {
+	engine *Engine
+}
+
+func NewAlertHandler(e *Engine) *AlertHandler {
+	return &AlertHandler{engine: e}
+}
+
+// Register all alerting routes
+func (h *AlertHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/config/v1/alerts", h.ListAlerts)
+	r.GET("/config/v1/alerts/rules", h.ListRules)
+	r.GET("/config/v1/alerts/silences", h.ListSilences)
+	r.POST("/config/v1/alerts/silences", h.CreateSilence)
+	r.DELETE("/config/v1/alerts/silences/:id", h.DeleteSilence)
+	r.POST("/config/v1/alerts/messages", h.SendMessage)
+}
+
+// ListAlerts returns the currently firing alerts.
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.engine.Enabled(),
+		"alerts":  h.engine.ActiveAlerts(),
+	})
+}
+
+// ListRules returns the configured alert rules.
+func (h *AlertHandler) ListRules(c *gin.Context) {
+	c.JSON(http.StatusOK, h.engine.Rules())
+}
+
+// ListSilences returns silences that have not ended yet.
+func (h *AlertHandler) ListSilences(c *gin.Context) {
+	silences, err := h.engine.ListSilences()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve silences"})
+		return
+	}
+	c.JSON(http.StatusOK, silences)
+}
+
+// CreateSilence handles POST /config/v1/alerts/silences.
+func (h *AlertHandler) CreateSilence(c *gin.Context) {
+	var dto SilenceDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	silence, err := h.engine.CreateSilence(&dto)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, silence)
+}
+
+// DeleteSilence handles DELETE /config/v1/alerts/silences/:id.
+func (h *AlertHandler) DeleteSilence(c *gin.Context) {
+	id, err := utils.ParseUintID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.engine.DeleteSilence(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete silence"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Silence removed"})
+}
+
+// SendMessage handles POST /config/v1/alerts/messages.
+func (h *AlertHandler) SendMessage(c *gin.Context) {
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	channels, err := h.engine.SendMessage(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Message queued", "channels": channels})
+}
@@ -0,0 +1,144 @@
+package alerting
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rule types supported by the engine.
+const (
+	RuleEndpointDown    = "endpoint_down"
+	RuleRouteDown       = "route_down"
+	RuleErrorRate       = "error_rate"
+	RuleContainerExited = "container_exited"
+	RuleUpstreamCert    = "upstream_cert"
+)
+
+// defaultCertExpiryDays is used by upstream_cert rules without expiryDays.
+const defaultCertExpiryDays = 14
+
+// Alert statuses carried in notifications.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusMessage  = "message"
+)
+
+// Alert is a deduplicated alert instance, identified by its Fingerprint.
+type Alert struct {
+	Fingerprint   string     `json:"fingerprint"`
+	RuleName      string     `json:"ruleName"`
+	RuleType      string     `json:"ruleType"`
+	BackendID     string     `json:"backendId,omitempty"`
+	PathPrefix    string     `json:"pathPrefix,omitempty"`
+	EndpointID    uint       `json:"endpointId,omitempty"`
+	EndpointURL   string     `json:"endpointUrl,omitempty"`
+	ContainerID   string     `json:"containerId,omitempty"`
+	ContainerName string     `json:"containerName,omitempty"`
+	Summary       string     `json:"summary"`
+	Status        string     `json:"status"`
+	StartsAt      time.Time  `json:"startsAt"`
+	EndsAt        *time.Time `json:"endsAt,omitempty"`
+	Silenced      bool       `json:"silenced"`
+
+	notified       bool
+	lastNotifiedAt time.Time
+	channels       []string
+}
+
+// Silence suppresses notifications for alerts matching all of its non-empty fields.
+type Silence struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	RuleName      string         `gorm:"type:varchar(100)" json:"ruleName"`
+	BackendID     string         `gorm:"type:varchar(64)" json:"backendId"`
+	EndpointURL   string         `gorm:"type:varchar(255)" json:"endpointUrl"`
+	ContainerName string         `gorm:"type:varchar(255)" json:"containerName"`
+	Comment       string         `gorm:"type:text" json:"comment"`
+	CreatedBy     string         `gorm:"type:varchar(100)" json:"createdBy"`
+	StartsAt      time.Time      `gorm:"not null" json:"startsAt"`
+	EndsAt        time.Time      `gorm:"not null;index" json:"endsAt"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Matches reports whether the silence is active at now and covers the alert.
+func (s *Silence) Matches(a *Alert, now time.Time) bool {
+	if now.Before(s.StartsAt) || !now.Before(s.EndsAt) {
+		return false
+	}
+	if s.RuleName != "" && s.RuleName != a.RuleName {
+		return false
+	}
+	if s.BackendID != "" && s.BackendID != a.BackendID {
+		return false
+	}
+	if s.EndpointURL != "" && s.EndpointURL != a.EndpointURL {
+		return false
+	}
+	if s.ContainerName != "" && s.ContainerName != a.ContainerName {
+		return false
+	}
+	return true
+}
+
+// SilenceDTO for API requests. At least one matcher field is required.
+type SilenceDTO struct {
+	RuleName        string    `json:"ruleName"`
+	BackendID       string    `json:"backendId"`
+	EndpointURL     string    `json:"endpointUrl"`
+	ContainerName   string    `json:"containerName"`
+	Comment         string    `json:"comment"`
+	CreatedBy       string    `json:"createdBy"`
+	StartsAt        time.Time `json:"startsAt"` // defaults to now
+	DurationMinutes int       `json:"durationMinutes" binding:"required,gt=0"`
+}
+
+// SendMessageRequest sends a free-form message through the notification channels.
+// Groups is a comma-separated list of channel names; empty means every channel.
+type SendMessageRequest struct {
+	GroupID string `json:"groups"`
+	Message string `json:"msg" binding:"required"`
+}
+
+// EndpointObservation is one health probe result reported by the gateway.
+type EndpointObservation struct {
+	BackendID    string
+	PathPrefix   string
+	EndpointID   uint
+	URL          string
+	Healthy      bool
+	Reason       string
+	RouteHealthy bool // at least one endpoint of the route is healthy
+	At           time.Time
+}
+
+// CertificateObservation is the certificate an HTTPS endpoint presented to a health probe.
+type CertificateObservation struct {
+	BackendID     string
+	PathPrefix    string
+	EndpointID    uint
+	URL           string
+	Subject       string
+	NotAfter      time.Time // earliest expiry in the presented chain
+	HostnameError string    // set when the leaf does not cover the name the gateway dials
+	At            time.Time
+}
+
+// ContainerEvent is a Docker container lifecycle change.
+type ContainerEvent struct {
+	ContainerID   string
+	ContainerName string
+	Action        string // start, die, ...
+	ExitCode      string
+	At            time.Time
+}
+
+// Notification is what notifiers deliver; webhooks receive it as JSON.
+type Notification struct {
+	Status string    `json:"status"`
+	Title  string    `json:"title"`
+	Text   string    `json:"text"`
+	Alert  *Alert    `json:"alert,omitempty"`
+	SentAt time.Time `json:"sentAt"`
+}
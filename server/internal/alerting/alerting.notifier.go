@@ -0,0 +1,220 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"imanager.io/config"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body, prefixed with "sha256=".
+const SignatureHeader = "X-Imanager-Signature"
+
+// Notifier delivers a notification to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewNotifier builds the notifier for a configured channel.
+func NewNotifier(ch config.AlertChannel) (Notifier, error) {
+	switch ch.Type {
+	case "webhook":
+		if ch.URL == "" {
+			return nil, fmt.Errorf("channel %s: webhook requires url", ch.Name)
+		}
+		return &webhookNotifier{url: ch.URL, secret: ch.Secret}, nil
+	case "slack":
+		if ch.URL == "" {
+			return nil, fmt.Errorf("channel %s: slack requires url", ch.Name)
+		}
+		return &slackNotifier{url: ch.URL}, nil
+	case "telegram":
+		if ch.BotToken == "" || ch.ChatID == "" {
+			return nil, fmt.Errorf("channel %s: telegram requires botToken and chatId", ch.Name)
+		}
+		base := ch.URL
+		if base == "" {
+			base = "https://api.telegram.org"
+		}
+		return &telegramNotifier{baseURL: strings.TrimSuffix(base, "/"), token: ch.BotToken, chatID: ch.ChatID}, nil
+	case "smtp":
+		if ch.Host == "" || ch.From == "" || len(ch.To) == 0 {
+			return nil, fmt.Errorf("channel %s: smtp requires host, from and to", ch.Name)
+		}
+		return &smtpNotifier{channel: ch}, nil
+	default:
+		return nil, fmt.Errorf("channel %s: unsupported type %q", ch.Name, ch.Type)
+	}
+}
+
+// webhookNotifier POSTs the notification as JSON, signed when a secret is set.
+type webhookNotifier struct {
+	url    string
+	secret string
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, msg *Notification) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"X-Imanager-Event": msg.Status}
+	if n.secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.secret))
+		mac.Write(body)
+		headers[SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(ctx, n.url, body, headers)
+}
+
+// slackNotifier posts to a Slack-compatible incoming webhook (Slack, Mattermost, Rocket.Chat).
+type slackNotifier struct {
+	url string
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, msg *Notification) error {
+	body, err := json.Marshal(map[string]string{"text": "*" + msg.Title + "*\n" + msg.Text})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.url, body, nil)
+}
+
+// telegramNotifier uses the Bot API sendMessage method.
+type telegramNotifier struct {
+	baseURL string
+	token   string
+	chatID  string
+}
+
+func (n *telegramNotifier) Notify(ctx context.Context, msg *Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": n.chatID,
+		"text":    msg.Title + "\n" + msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.baseURL+"/bot"+n.token+"/sendMessage", body, nil)
+}
+
+// smtpNotifier sends a plain-text email.
+type smtpNotifier struct {
+	channel config.AlertChannel
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, msg *Notification) error {
+	ch := n.channel
+	port := ch.Port
+	if port == "" {
+		port = "25"
+	}
+	var auth smtp.Auth
+	if ch.Username != "" {
+		auth = smtp.PlainAuth("", ch.Username, ch.Password, ch.Host)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", ch.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(ch.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Title)
+	fmt.Fprintf(&buf, "Date: %s\r\n", msg.SentAt.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(msg.Text)
+	buf.WriteString("\r\n")
+
+	err := sendMail(ctx, net.JoinHostPort(ch.Host, port), ch.Host, auth, ch.From, ch.To, buf.Bytes())
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err() // the connection was closed under the client
+	}
+	return err
+}
+
+// sendMail is smtp.SendMail bounded by ctx: the connection is dialed with ctx,
+// carries ctx's deadline (or notifyClient's timeout) and is closed when ctx ends.
+func sendMail(ctx context.Context, addr, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(notifyClient.Timeout)
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func postJSON(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("notification endpoint returned %s", resp.Status)
+	}
+	return nil
+}
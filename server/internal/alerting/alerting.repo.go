@@ -0,0 +1,51 @@
+package alerting
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository persists silences so they survive restarts.
+type Repository interface {
+	Migrate() error
+	CreateSilence(silence *Silence) error
+	ListSilences(activeAt time.Time) ([]*Silence, error)
+	DeleteSilence(id uint) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+func NewGormRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) Migrate() error {
+	return r.db.AutoMigrate(&Silence{})
+}
+
+func (r *gormRepository) CreateSilence(silence *Silence) error {
+	return r.db.Create(silence).Error
+}
+
+// ListSilences returns silences that have not yet ended at activeAt.
+func (r *gormRepository) ListSilences(activeAt time.Time) ([]*Silence, error) {
+	var silences []*Silence
+	if err := r.db.Where("ends_at > ?", activeAt).Order("ends_at ASC").Find(&silences).Error; err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+func (r *gormRepository) DeleteSilence(id uint) error {
+	result := r.db.Delete(&Silence{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
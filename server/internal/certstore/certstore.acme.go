@@ -0,0 +1,84 @@
+package certstore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+	"imanager.io/config"
+)
+
+// newACMEManager builds an autocert manager whose account key and certificates
+// live in the database, encrypted like uploaded keys.
+func newACMEManager(cfg config.ACMEConfig, s *Store) (*autocert.Manager, error) {
+	if len(cfg.Domains) == 0 {
+		return nil, errors.New("tls.acme.domains must list at least one host name")
+	}
+
+	httpClient := http.DefaultClient
+	if cfg.CABundle != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+		if cfg.CABundle != "" {
+			pem, err := os.ReadFile(cfg.CABundle)
+			if err != nil {
+				return nil, fmt.Errorf("read ACME CA bundle: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.CABundle)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      &dbCache{repo: s.repo, sealer: s.sealer},
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+		Client: &acme.Client{
+			DirectoryURL: cfg.DirectoryURL,
+			HTTPClient:   httpClient,
+		},
+	}, nil
+}
+
+// dbCache implements autocert.Cache on top of the repository.
+type dbCache struct {
+	repo   Repository
+	sealer *sealer
+}
+
+func (c *dbCache) Get(_ context.Context, key string) ([]byte, error) {
+	entry, err := c.repo.GetACMEEntry(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, autocert.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.sealer.open(entry.Data)
+}
+
+func (c *dbCache) Put(_ context.Context, key string, data []byte) error {
+	sealed, err := c.sealer.seal(data)
+	if err != nil {
+		return err
+	}
+	return c.repo.PutACMEEntry(&ACMECacheEntry{CacheKey: key, Data: sealed})
+}
+
+func (c *dbCache) Delete(_ context.Context, key string) error {
+	return c.repo.DeleteACMEEntry(key)
+}
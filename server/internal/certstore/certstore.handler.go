@@ -0,0 +1,100 @@
+package certstore
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"imanager.io/utils"
+)
+
+// CertHandler exposes the certificate store.
+type CertHandler struct {
+	store *Store
+}
+
+func NewCertHandler(s *Store) *CertHandler {
+	return &CertHandler{store: s}
+}
+
+// Register certificate routes
+func (h *CertHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/config/v1/certificates", h.ListCertificates)
+	r.POST("/config/v1/certificates", h.UploadCertificate)
+	r.DELETE("/config/v1/certificates/:id", h.DeleteCertificate)
+	r.POST("/config/v1/certificates/reload", h.ReloadCertificates)
+}
+
+func (h *CertHandler) ListCertificates(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	certs := h.store.List()
+	c.JSON(http.StatusOK, gin.H{"data": certs, "count": len(certs)})
+}
+
+// UploadCertificate handles POST /config/v1/certificates with PEM-encoded certificate and key.
+func (h *CertHandler) UploadCertificate(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	var dto CertificateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	cert, err := h.store.Upload(&dto)
+	if err != nil {
+		respondError(c, err, "Failed to store certificate")
+		return
+	}
+	c.JSON(http.StatusCreated, cert)
+}
+
+func (h *CertHandler) DeleteCertificate(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid certificate id"})
+		return
+	}
+	if err := h.store.Delete(uint(id)); err != nil {
+		respondError(c, err, "Failed to delete certificate")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Certificate deleted"})
+}
+
+// ReloadCertificates re-reads the store from the database immediately.
+func (h *CertHandler) ReloadCertificates(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	if err := h.store.Reload(); err != nil {
+		respondError(c, err, "Failed to reload certificates")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Certificates reloaded", "count": len(h.store.List())})
+}
+
+func (h *CertHandler) enabled(c *gin.Context) bool {
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "TLS is disabled; set tls.enabled in config.yml"})
+		return false
+	}
+	return true
+}
+
+func respondError(c *gin.Context, err error, fallback string) {
+	var svcErr *utils.ServiceError
+	if errors.As(err, &svcErr) {
+		c.JSON(svcErr.StatusCode, gin.H{"error": svcErr.Message})
+		return
+	}
+	log.Printf("ERROR: %s: %v", fallback, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
@@ -0,0 +1,35 @@
+package certstore
+
+import "time"
+
+// Certificate is an uploaded server certificate. The private key is stored
+// encrypted with the configured SecretKey and never leaves the store.
+type Certificate struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	Name         string    `gorm:"type:varchar(255);not null" json:"name"`
+	Domains      []string  `gorm:"type:text;serializer:json" json:"domains"` // SANs, may contain "*.example.com"
+	IsDefault    bool      `gorm:"not null;default:false" json:"isDefault"`  // served when no SNI name matches
+	CertPEM      string    `gorm:"type:text;not null" json:"certPem"`
+	KeyEncrypted string    `gorm:"type:text;not null" json:"-"`
+	Issuer       string    `gorm:"type:varchar(255)" json:"issuer"`
+	Fingerprint  string    `gorm:"type:varchar(64);index" json:"fingerprint"` // SHA-256 of the leaf, hex
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `gorm:"index" json:"notAfter"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// CertificateDTO is the upload payload; CertPEM may include the chain.
+type CertificateDTO struct {
+	Name      string `json:"name" binding:"required"`
+	CertPEM   string `json:"certPem" binding:"required"`
+	KeyPEM    string `json:"keyPem" binding:"required"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+// ACMECacheEntry backs autocert's cache (account key and issued certificates), encrypted.
+type ACMECacheEntry struct {
+	CacheKey  string    `gorm:"type:varchar(255);primaryKey" json:"cacheKey"`
+	Data      string    `gorm:"type:text;not null" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,81 @@
+package certstore
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Repository persists certificates and the ACME cache.
+type Repository interface {
+	Migrate() error
+	CreateCertificate(cert *Certificate) error
+	ListCertificates() ([]*Certificate, error)
+	DeleteCertificate(id uint) error
+	ClearDefault(exceptID uint) error
+	GetACMEEntry(key string) (*ACMECacheEntry, error)
+	PutACMEEntry(entry *ACMECacheEntry) error
+	DeleteACMEEntry(key string) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+func NewGormRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) Migrate() error {
+	return r.db.AutoMigrate(&Certificate{}, &ACMECacheEntry{})
+}
+
+func (r *gormRepository) CreateCertificate(cert *Certificate) error {
+	return r.db.Create(cert).Error
+}
+
+func (r *gormRepository) ListCertificates() ([]*Certificate, error) {
+	var certs []*Certificate
+	if err := r.db.Order("not_after DESC").Find(&certs).Error; err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+func (r *gormRepository) DeleteCertificate(id uint) error {
+	result := r.db.Delete(&Certificate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ClearDefault unsets IsDefault on every certificate except exceptID.
+func (r *gormRepository) ClearDefault(exceptID uint) error {
+	return r.db.Model(&Certificate{}).Where("id <> ? AND is_default = ?", exceptID, true).
+		Update("is_default", false).Error
+}
+
+// GetACMEEntry returns gorm.ErrRecordNotFound for unknown keys.
+func (r *gormRepository) GetACMEEntry(key string) (*ACMECacheEntry, error) {
+	var entry ACMECacheEntry
+	if err := r.db.Where("cache_key = ?", key).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *gormRepository) PutACMEEntry(entry *ACMECacheEntry) error {
+	return r.db.Save(entry).Error
+}
+
+func (r *gormRepository) DeleteACMEEntry(key string) error {
+	err := r.db.Where("cache_key = ?", key).Delete(&ACMECacheEntry{}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,286 @@
+package certstore
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+	"imanager.io/config"
+	"imanager.io/internal/eventbus"
+	"imanager.io/utils"
+)
+
+const (
+	defaultExpiryWarningDays = 14
+	reloadInterval           = time.Minute
+	expiryCheckInterval      = 12 * time.Hour
+)
+
+// Store serves certificates by SNI from the database and, optionally, ACME.
+type Store struct {
+	repo     Repository
+	sealer   *sealer
+	events   *eventbus.Bus
+	warnDays int
+	acme     *autocert.Manager // nil unless ACME is enabled
+
+	mu        sync.RWMutex
+	exact     map[string]*tls.Certificate // "api.example.com"
+	wildcards map[string]*tls.Certificate // "example.com" for "*.example.com"
+	fallback  *tls.Certificate
+	certs     []*Certificate
+}
+
+func NewStore(cfg config.TLSConfig, secretKey string, repo Repository, events *eventbus.Bus) (*Store, error) {
+	sealer, err := newSealer(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		repo:     repo,
+		sealer:   sealer,
+		events:   events,
+		warnDays: cfg.ExpiryWarningDays,
+	}
+	if s.warnDays <= 0 {
+		s.warnDays = defaultExpiryWarningDays
+	}
+	if cfg.ACME.Enabled {
+		if s.acme, err = newACMEManager(cfg.ACME, s); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start re-reads the store periodically, so certificates written by another
+// instance or directly in the database are picked up, and warns about expiry.
+func (s *Store) Start() {
+	s.CheckExpiry()
+	reload := time.NewTicker(reloadInterval)
+	expiry := time.NewTicker(expiryCheckInterval)
+	defer reload.Stop()
+	defer expiry.Stop()
+	for {
+		select {
+		case <-reload.C:
+			if err := s.Reload(); err != nil {
+				log.Printf("ERROR: Failed to reload certificates: %v", err)
+			}
+		case <-expiry.C:
+			s.CheckExpiry()
+		}
+	}
+}
+
+// Reload rebuilds the SNI index from the database. Certificates that fail to
+// decrypt or parse are skipped and logged.
+func (s *Store) Reload() error {
+	certs, err := s.repo.ListCertificates()
+	if err != nil {
+		return err
+	}
+
+	exact := make(map[string]*tls.Certificate)
+	wildcards := make(map[string]*tls.Certificate)
+	var fallback *tls.Certificate
+	// certs are ordered by NotAfter DESC, so the longest-lived one wins per name.
+	for _, c := range certs {
+		pair, err := s.keyPair(c)
+		if err != nil {
+			log.Printf("ERROR: Skipping certificate %d (%s): %v", c.ID, c.Name, err)
+			continue
+		}
+		for _, domain := range c.Domains {
+			domain = strings.ToLower(domain)
+			if rest, ok := strings.CutPrefix(domain, "*."); ok {
+				if _, taken := wildcards[rest]; !taken {
+					wildcards[rest] = pair
+				}
+			} else if _, taken := exact[domain]; !taken {
+				exact[domain] = pair
+			}
+		}
+		if c.IsDefault && fallback == nil {
+			fallback = pair
+		}
+	}
+
+	s.mu.Lock()
+	s.exact, s.wildcards, s.fallback, s.certs = exact, wildcards, fallback, certs
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) keyPair(c *Certificate) (*tls.Certificate, error) {
+	keyPEM, err := s.sealer.open(c.KeyEncrypted)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := tls.X509KeyPair([]byte(c.CertPEM), keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &pair, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate: exact SNI name, then
+// wildcard, then ACME for allowed hosts, then the default certificate.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if s.acme != nil {
+		for _, proto := range hello.SupportedProtos {
+			if proto == acme.ALPNProto {
+				return s.acme.GetCertificate(hello) // tls-alpn-01 challenge
+			}
+		}
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	s.mu.RLock()
+	cert := s.exact[name]
+	if cert == nil {
+		if i := strings.IndexByte(name, '.'); i > 0 {
+			cert = s.wildcards[name[i+1:]]
+		}
+	}
+	fallback := s.fallback
+	s.mu.RUnlock()
+	if cert != nil {
+		return cert, nil
+	}
+
+	if s.acme != nil && name != "" {
+		if err := s.acme.HostPolicy(hello.Context(), name); err == nil {
+			return s.acme.GetCertificate(hello)
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("no certificate for server name %q", hello.ServerName)
+}
+
+// TLSConfig returns the server configuration for the HTTPS listener.
+func (s *Store) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+	}
+}
+
+// HTTPHandler answers ACME http-01 challenges and passes everything else to
+// fallback. Nil-safe: without a store or ACME it returns fallback unchanged.
+func (s *Store) HTTPHandler(fallback http.Handler) http.Handler {
+	if s == nil || s.acme == nil {
+		return fallback
+	}
+	return s.acme.HTTPHandler(fallback)
+}
+
+// List returns certificate metadata, newest expiry first.
+func (s *Store) List() []*Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Certificate(nil), s.certs...)
+}
+
+// Upload validates a PEM pair, encrypts the key and makes it live immediately.
+func (s *Store) Upload(dto *CertificateDTO) (*Certificate, error) {
+	pair, err := tls.X509KeyPair([]byte(dto.CertPEM), []byte(dto.KeyPEM))
+	if err != nil {
+		return nil, utils.NewServiceError(err, "certificate and key do not form a valid pair: "+err.Error(), http.StatusBadRequest)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, utils.NewServiceError(err, "invalid leaf certificate: "+err.Error(), http.StatusBadRequest)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, utils.NewServiceError(nil, "certificate expired on "+leaf.NotAfter.Format(time.RFC3339), http.StatusBadRequest)
+	}
+	domains := leaf.DNSNames
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = []string{leaf.Subject.CommonName}
+	}
+	if len(domains) == 0 && !dto.IsDefault {
+		return nil, utils.NewServiceError(nil, "certificate has no DNS names; upload it as the default certificate", http.StatusBadRequest)
+	}
+
+	sealed, err := s.sealer.seal([]byte(dto.KeyPEM))
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(leaf.Raw)
+	cert := &Certificate{
+		Name:         dto.Name,
+		Domains:      domains,
+		IsDefault:    dto.IsDefault,
+		CertPEM:      dto.CertPEM,
+		KeyEncrypted: sealed,
+		Issuer:       leaf.Issuer.CommonName,
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+	}
+	if err := s.repo.CreateCertificate(cert); err != nil {
+		return nil, err
+	}
+	if cert.IsDefault {
+		if err := s.repo.ClearDefault(cert.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Certificate %d (%s) for %v loaded, expires %s", cert.ID, cert.Name, cert.Domains, cert.NotAfter.Format(time.RFC3339))
+	return cert, nil
+}
+
+// Delete removes a certificate and stops serving it.
+func (s *Store) Delete(id uint) error {
+	if err := s.repo.DeleteCertificate(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewServiceError(err, fmt.Sprintf("certificate %d not found", id), http.StatusNotFound)
+		}
+		return err
+	}
+	return s.Reload()
+}
+
+// CheckExpiry logs and publishes a warning for every certificate that expires
+// within the warning window, including ones that already expired.
+func (s *Store) CheckExpiry() {
+	now := time.Now()
+	for _, c := range s.List() {
+		left := c.NotAfter.Sub(now)
+		if left > time.Duration(s.warnDays)*24*time.Hour {
+			continue
+		}
+		daysLeft := int(left.Hours() / 24)
+		log.Printf("WARN: Certificate %d (%s) for %v expires %s (%d days left)",
+			c.ID, c.Name, c.Domains, c.NotAfter.Format(time.RFC3339), daysLeft)
+		s.events.Publish(eventbus.TypeCertExpiring, eventbus.CertificateExpiryData{
+			Source:   "store",
+			ID:       c.ID,
+			Name:     c.Name,
+			Domains:  c.Domains,
+			NotAfter: c.NotAfter,
+			DaysLeft: daysLeft,
+		})
+	}
+}
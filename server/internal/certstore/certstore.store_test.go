@@ -0,0 +1,167 @@
+package certstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryRepo keeps certificates in memory, newest expiry first like the gorm repository.
+type memoryRepo struct {
+	certs  []*Certificate
+	nextID uint
+}
+
+func (r *memoryRepo) Migrate() error { return nil }
+
+func (r *memoryRepo) CreateCertificate(cert *Certificate) error {
+	r.nextID++
+	cert.ID = r.nextID
+	r.certs = append(r.certs, cert)
+	slices.SortFunc(r.certs, func(a, b *Certificate) int { return b.NotAfter.Compare(a.NotAfter) })
+	return nil
+}
+
+func (r *memoryRepo) ListCertificates() ([]*Certificate, error) {
+	return slices.Clone(r.certs), nil
+}
+
+func (r *memoryRepo) DeleteCertificate(id uint) error {
+	i := slices.IndexFunc(r.certs, func(c *Certificate) bool { return c.ID == id })
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	r.certs = slices.Delete(r.certs, i, i+1)
+	return nil
+}
+
+func (r *memoryRepo) ClearDefault(exceptID uint) error {
+	for _, c := range r.certs {
+		if c.ID != exceptID {
+			c.IsDefault = false
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) GetACMEEntry(string) (*ACMECacheEntry, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+func (r *memoryRepo) PutACMEEntry(*ACMECacheEntry) error { return nil }
+func (r *memoryRepo) DeleteACMEEntry(string) error       { return nil }
+
+// selfSigned returns a PEM certificate and key for names, valid until notAfter.
+func selfSigned(t *testing.T, notAfter time.Time, names ...string) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestStoreUploadValidation(t *testing.T) {
+	s := &Store{repo: &memoryRepo{}}
+	s.sealer, _ = newSealer("secret")
+	later := time.Now().Add(30 * 24 * time.Hour)
+
+	certPEM, keyPEM := selfSigned(t, later, "api.example.com")
+	_, otherKey := selfSigned(t, later, "api.example.com")
+	expiredCert, expiredKey := selfSigned(t, time.Now().Add(-time.Hour), "old.example.com")
+	tests := []struct {
+		name string
+		dto  CertificateDTO
+		ok   bool
+	}{
+		{"valid", CertificateDTO{Name: "api", CertPEM: certPEM, KeyPEM: keyPEM}, true},
+		{"mismatched key", CertificateDTO{Name: "api", CertPEM: certPEM, KeyPEM: otherKey}, false},
+		{"expired", CertificateDTO{Name: "old", CertPEM: expiredCert, KeyPEM: expiredKey}, false},
+		{"garbage", CertificateDTO{Name: "x", CertPEM: "not pem", KeyPEM: "not pem"}, false},
+	}
+	for _, tt := range tests {
+		cert, err := s.Upload(&tt.dto)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Upload = %v, want ok %t", tt.name, err, tt.ok)
+			continue
+		}
+		if err == nil && (cert.KeyEncrypted == keyPEM || !slices.Equal(cert.Domains, []string{"api.example.com"})) {
+			t.Errorf("%s: stored %+v", tt.name, cert)
+		}
+	}
+}
+
+func TestStoreGetCertificate(t *testing.T) {
+	s := &Store{repo: &memoryRepo{}}
+	s.sealer, _ = newSealer("secret")
+	later := time.Now().Add(30 * 24 * time.Hour)
+	upload := func(name string, isDefault bool, notAfter time.Time, domains ...string) *Certificate {
+		certPEM, keyPEM := selfSigned(t, notAfter, domains...)
+		cert, err := s.Upload(&CertificateDTO{Name: name, CertPEM: certPEM, KeyPEM: keyPEM, IsDefault: isDefault})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	upload("exact", false, later, "api.example.com")
+	wildcard := upload("wildcard", false, later, "*.example.com")
+	longer := upload("longer", false, later.Add(24*time.Hour), "api.example.com")
+	fallback := upload("fallback", true, later, "default.test")
+
+	tests := []struct {
+		serverName string
+		want       *Certificate
+	}{
+		{"api.example.com", longer}, // the longest-lived certificate wins per name
+		{"API.Example.com.", longer},
+		{"www.example.com", wildcard},
+		{"a.b.example.com", fallback}, // wildcards cover one label only
+		{"unknown.test", fallback},
+		{"", fallback},
+	}
+	for _, tt := range tests {
+		got, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+		if err != nil {
+			t.Errorf("%q: %v", tt.serverName, err)
+			continue
+		}
+		if leaf, _ := x509.ParseCertificate(got.Certificate[0]); !slices.Equal(leaf.DNSNames, tt.want.Domains) || !leaf.NotAfter.Equal(tt.want.NotAfter) {
+			t.Errorf("%q served %v, want certificate %q", tt.serverName, leaf.DNSNames, tt.want.Name)
+		}
+	}
+
+	if err := s.Delete(fallback.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.test"}); err == nil {
+		t.Error("served a certificate for an unknown name without a default")
+	}
+	if len(s.List()) != 3 {
+		t.Errorf("List = %d certificates, want 3", len(s.List()))
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 )
 
@@ -148,3 +151,45 @@ func (d *DockerClient) StreamLogs(id string) (io.ReadCloser, error) {
 		},
 	)
 }
+
+// WatchEvents streams container and image events to the handlers, reconnecting
+// with backoff if the daemon connection drops. It blocks until ctx is cancelled.
+func (d *DockerClient) WatchEvents(ctx context.Context, handlers ...func(events.Message)) {
+	backoff := time.Second
+	for {
+		msgs, errs := d.cli.Events(ctx, types.EventsOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("type", string(events.ContainerEventType)),
+				filters.Arg("type", string(events.ImageEventType)),
+			),
+		})
+
+	stream:
+		for {
+			select {
+			case msg := <-msgs:
+				backoff = time.Second
+				for _, handle := range handlers {
+					handle(msg)
+				}
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("WARN: Docker event stream interrupted: %v (retrying in %s)", err, backoff)
+				break stream
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package eventbus
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// subscriberBuffer is how far a subscriber may fall behind before it is
+// disconnected; it can then reconnect with Last-Event-ID to catch up.
+const subscriberBuffer = 256
+
+// Bus fans events out to subscribers and keeps the most recent ones in a
+// ring buffer for Last-Event-ID replay.
+type Bus struct {
+	mu     sync.Mutex
+	nextID uint64
+	ring   []Event
+	head   int // index of the oldest event once the ring is full
+	subs   map[*Subscription]struct{}
+}
+
+// NewBus creates a bus that retains the last capacity events.
+func NewBus(capacity int) *Bus {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &Bus{
+		ring: make([]Event, 0, capacity),
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Filter selects event types. Entries match exactly or by prefix when they
+// end in "*" (e.g. "docker.*"). An empty filter matches everything.
+type Filter []string
+
+// ParseFilter splits a comma-separated list of types.
+func ParseFilter(raw string) Filter {
+	var f Filter
+	for _, t := range strings.Split(raw, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f = append(f, t)
+		}
+	}
+	return f
+}
+
+// Match reports whether the event type passes the filter.
+func (f Filter) Match(eventType string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, t := range f {
+		if t == eventType || (strings.HasSuffix(t, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives live events until closed or dropped for being too slow.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan Event
+	once   sync.Once
+}
+
+// Events returns the delivery channel; it is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unsubscribes.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	})
+}
+
+// Publish assigns the next ID and delivers the event. Safe on a nil bus.
+func (b *Bus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	ev := Event{ID: b.nextID, Type: eventType, Time: time.Now(), Data: data}
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, ev)
+	} else {
+		b.ring[b.head] = ev
+		b.head = (b.head + 1) % len(b.ring)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(eventType) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.closeLocked()
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the buffered events after
+// lastEventID (0 for none) that match the filter, without gaps or duplicates.
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		for i := 0; i < len(b.ring); i++ {
+			ev := b.ring[(b.head+i)%len(b.ring)]
+			if ev.ID > lastEventID && filter.Match(ev.Type) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	sub := &Subscription{bus: b, filter: filter, ch: make(chan Event, subscriberBuffer)}
+	b.subs[sub] = struct{}{}
+	return sub, replay
+}
+
+// HandleDockerEvent republishes container and image events from the Docker event stream.
+func (b *Bus) HandleDockerEvent(msg events.Message) {
+	switch msg.Type {
+	case events.ContainerEventType:
+		action := string(msg.Action)
+		// exec_* and health_status events are too chatty for UI consumers.
+		if strings.HasPrefix(action, "exec_") || strings.HasPrefix(action, "health_status") {
+			return
+		}
+		b.Publish(TypeDockerContainer, ContainerData{
+			ID:       msg.Actor.ID,
+			Name:     strings.TrimPrefix(msg.Actor.Attributes["name"], "/"),
+			Image:    msg.Actor.Attributes["image"],
+			Action:   action,
+			ExitCode: msg.Actor.Attributes["exitCode"],
+		})
+	case events.ImageEventType:
+		b.Publish(TypeDockerImage, ImageData{
+			ID:     msg.Actor.ID,
+			Name:   msg.Actor.Attributes["name"],
+			Action: string(msg.Action),
+		})
+	}
+}
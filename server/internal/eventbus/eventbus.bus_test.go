@@ -0,0 +1,106 @@
+package eventbus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func ids(events []Event) []uint64 {
+	var out []uint64
+	for _, ev := range events {
+		out = append(out, ev.ID)
+	}
+	return out
+}
+
+func TestFilterMatch(t *testing.T) {
+	f := ParseFilter(" docker.* , config.reload,")
+	tests := map[string]bool{
+		TypeDockerContainer: true,
+		TypeDockerImage:     true,
+		TypeConfigReload:    true,
+		TypeAccessLog:       false,
+		"docker":            false,
+	}
+	for eventType, want := range tests {
+		if got := f.Match(eventType); got != want {
+			t.Errorf("Match(%q) = %t, want %t", eventType, got, want)
+		}
+	}
+	if !ParseFilter("").Match(TypeAccessLog) {
+		t.Error("empty filter rejected an event")
+	}
+}
+
+func TestBusReplay(t *testing.T) {
+	b := NewBus(3)
+	for _, eventType := range []string{TypeConfigReload, TypeAccessLog, TypeConfigReload, TypeAccessLog, TypeConfigReload} {
+		b.Publish(eventType, nil)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		last   uint64
+		want   []uint64
+	}{
+		{"no last id", nil, 0, nil},
+		{"after 3", nil, 3, []uint64{4, 5}},
+		{"older than the ring", nil, 1, []uint64{3, 4, 5}},
+		{"filtered", Filter{TypeConfigReload}, 1, []uint64{3, 5}},
+		{"up to date", nil, 5, nil},
+	}
+	for _, tt := range tests {
+		sub, replay := b.Subscribe(tt.filter, tt.last)
+		sub.Close()
+		if got := ids(replay); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: replay %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBusDeliversAndDropsSlowSubscribers(t *testing.T) {
+	b := NewBus(10)
+	sub, _ := b.Subscribe(Filter{TypeAccessLog}, 0)
+	b.Publish(TypeConfigReload, nil)
+	b.Publish(TypeAccessLog, "hit")
+	if ev := <-sub.Events(); ev.Type != TypeAccessLog || ev.ID != 2 || ev.Data != "hit" {
+		t.Errorf("delivered %+v", ev)
+	}
+
+	for range subscriberBuffer + 1 {
+		b.Publish(TypeAccessLog, nil)
+	}
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", n, subscriberBuffer)
+	}
+	sub.Close() // closing a dropped subscription is a no-op
+
+	var nilBus *Bus
+	nilBus.Publish(TypeAccessLog, nil)
+}
+
+func TestEventWebSocketCheckOrigin(t *testing.T) {
+	h := NewEventHandler(NewBus(1), []string{"https://admin.example.com"})
+	tests := map[string]bool{
+		"":                          true,
+		"http://gateway.test:8080":  true,
+		"https://admin.example.com": true,
+		"https://evil.example.com":  false,
+	}
+	for origin, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://gateway.test:8080/api/events/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := h.upgrader.CheckOrigin(r); got != want {
+			t.Errorf("CheckOrigin(%q) = %t, want %t", origin, got, want)
+		}
+	}
+}
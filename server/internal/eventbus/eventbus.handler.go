@@ -0,0 +1,167 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"imanager.io/utils"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// EventHandler serves the bus over Server-Sent Events and WebSocket.
+type EventHandler struct {
+	bus      *Bus
+	upgrader websocket.Upgrader
+}
+
+// NewEventHandler serves b. Browsers may open the WebSocket from the gateway's
+// own host or from allowedOrigins, the management API CORS origins.
+func NewEventHandler(b *Bus, allowedOrigins []string) *EventHandler {
+	return &EventHandler{
+		bus: b,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return true // not a browser; CORS does not apply
+				}
+				if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+					return true
+				}
+				return utils.MatchOrigin(allowedOrigins, origin)
+			},
+		},
+	}
+}
+
+// Register event stream routes
+func (h *EventHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/api/events", h.Stream)
+	r.GET("/api/events/ws", h.WebSocket)
+}
+
+// subscribe reads ?types= and the Last-Event-ID header (or ?lastEventId=) and subscribes.
+func (h *EventHandler) subscribe(c *gin.Context) (*Subscription, []Event) {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("lastEventId")
+	}
+	lastID, _ := strconv.ParseUint(raw, 10, 64)
+	return h.bus.Subscribe(ParseFilter(c.Query("types")), lastID)
+}
+
+// Stream handles GET /api/events as text/event-stream.
+func (h *EventHandler) Stream(c *gin.Context) {
+	sub, replay := h.subscribe(c)
+	defer sub.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, ev := range replay {
+		if err := writeSSE(w, ev); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return // dropped as too slow; the client reconnects with Last-Event-ID
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func writeSSE(w gin.ResponseWriter, ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, payload)
+	return err
+}
+
+// WebSocket handles GET /api/events/ws; each event is sent as one JSON text message.
+func (h *EventHandler) WebSocket(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("ERROR: Failed to upgrade event stream to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, replay := h.subscribe(c)
+	defer sub.Close()
+
+	// Reader: only needed to process control frames and notice the client leaving.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, ev := range replay {
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"),
+					time.Now().Add(time.Second))
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package eventbus
+
+import "time"
+
+// Event types published on the bus.
+const (
+	TypeEndpointHealth  = "endpoint.health"
+	TypeConfigReload    = "config.reload"
+	TypeAccessLog       = "access.log"
+	TypeDockerContainer = "docker.container"
+	TypeDockerImage     = "docker.image"
+	TypeCertExpiring    = "certificate.expiring"
+)
+
+// Event is the envelope delivered to SSE and WebSocket subscribers.
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// EndpointHealthData is published when an endpoint flips UP/DOWN.
+type EndpointHealthData struct {
+	BackendID  string `json:"backendId"`
+	PathPrefix string `json:"pathPrefix"`
+	EndpointID uint   `json:"endpointId"`
+	URL        string `json:"url"`
+	IsHealthy  bool   `json:"isHealthy"`
+	Reason     string `json:"reason,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+}
+
+// ConfigReloadData is published whenever the gateway swaps its route table.
+type ConfigReloadData struct {
+	Backends   int      `json:"backends"`
+	BackendIDs []string `json:"backendIds"`
+}
+
+// AccessLogData is a sampled proxied request.
+type AccessLogData struct {
+	RequestID  string `json:"requestId"`
+	BackendID  string `json:"backendId"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	ClientIP   string `json:"clientIP"`
+	StatusCode int    `json:"statusCode"`
+	LatencyMs  int64  `json:"latencyMs"`
+}
+
+// ContainerData is a Docker container lifecycle event.
+type ContainerData struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Action   string `json:"action"`
+	ExitCode string `json:"exitCode,omitempty"`
+}
+
+// ImageData is a Docker image event such as pull, tag or delete.
+type ImageData struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// CertificateExpiryData warns that a certificate is close to (or past) expiry,
+// or, for upstreams, that it does not cover the host name the gateway dials.
+type CertificateExpiryData struct {
+	Source        string    `json:"source"`              // "store" for served certificates, "upstream" for endpoints
+	ID            uint      `json:"id,omitempty"`        // certificate ID or endpoint ID
+	BackendID     string    `json:"backendId,omitempty"` // upstream only
+	Name          string    `json:"name"`                // certificate name or endpoint URL
+	Domains       []string  `json:"domains"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysLeft      int       `json:"daysLeft"`
+	HostnameError string    `json:"hostnameError,omitempty"`
+}
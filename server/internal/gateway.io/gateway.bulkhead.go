@@ -0,0 +1,319 @@
+// gateway.bulkhead.go
+package gatewayio
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueTimeout     = time.Second
+	defaultOverloadRetry    = 1
+	adaptiveDecreaseFactor  = 0.9
+	defaultAdaptiveMinLimit = 1
+)
+
+var (
+	errQueueFull    = errors.New("queue full")
+	errQueueTimeout = errors.New("queue timeout")
+)
+
+// ConcurrencyPolicy bounds the upstream requests a route, and each of its
+// endpoints, has in flight. Requests over a limit wait in a FIFO queue and get
+// 503 with Retry-After when the queue is full or they waited QueueTimeoutMs.
+type ConcurrencyPolicy struct {
+	MaxInFlight            int            `json:"maxInFlight" binding:"omitempty,gte=0"`            // per route; 0 leaves the route unbounded
+	MaxInFlightPerEndpoint int            `json:"maxInFlightPerEndpoint" binding:"omitempty,gte=0"` // 0 leaves endpoints unbounded
+	MaxQueue               int            `json:"maxQueue" binding:"omitempty,gte=0"`               // waiting requests per limit; 0 rejects at once
+	QueueTimeoutMs         int            `json:"queueTimeoutMs" binding:"omitempty,gte=0"`         // 0 uses 1s
+	RetryAfterSeconds      int            `json:"retryAfterSeconds" binding:"omitempty,gte=0"`      // 0 uses 1
+	Adaptive               *AdaptiveLimit `json:"adaptive,omitempty"`
+}
+
+// AdaptiveLimit moves the route limit between MinLimit and MaxInFlight: it
+// grows while a saturated route answers within TargetLatencyMs and shrinks by
+// 10% when responses are slower or time out.
+type AdaptiveLimit struct {
+	TargetLatencyMs int `json:"targetLatencyMs" binding:"required,gt=0"`
+	MinLimit        int `json:"minLimit" binding:"omitempty,gte=0"` // 0 uses 1
+}
+
+func (p *ConcurrencyPolicy) validate() error {
+	if p.MaxInFlight == 0 && p.MaxInFlightPerEndpoint == 0 {
+		return fmt.Errorf("set maxInFlight, maxInFlightPerEndpoint or both")
+	}
+	if p.Adaptive != nil {
+		if p.MaxInFlight == 0 {
+			return fmt.Errorf("adaptive needs maxInFlight as its ceiling")
+		}
+		if p.Adaptive.MinLimit > p.MaxInFlight {
+			return fmt.Errorf("adaptive.minLimit must not exceed maxInFlight")
+		}
+	}
+	return nil
+}
+
+func (p *ConcurrencyPolicy) queueTimeout() time.Duration {
+	if p.QueueTimeoutMs > 0 {
+		return time.Duration(p.QueueTimeoutMs) * time.Millisecond
+	}
+	return defaultQueueTimeout
+}
+
+func (p *ConcurrencyPolicy) retryAfter() int {
+	if p.RetryAfterSeconds > 0 {
+		return p.RetryAfterSeconds
+	}
+	return defaultOverloadRetry
+}
+
+// routeLimits is the route-wide limit, adaptive when configured.
+func (p *ConcurrencyPolicy) routeLimits() bulkheadLimits {
+	limits := bulkheadLimits{max: p.MaxInFlight}
+	if a := p.Adaptive; a != nil {
+		limits.min = max(a.MinLimit, defaultAdaptiveMinLimit)
+		limits.target = time.Duration(a.TargetLatencyMs) * time.Millisecond
+	}
+	return limits
+}
+
+// bulkheadLimits bounds one bulkhead.
+type bulkheadLimits struct {
+	max    int           // hard ceiling
+	min    int           // adaptive floor
+	target time.Duration // adaptive latency target; 0 keeps the limit at max
+}
+
+// bulkhead counts in-flight requests against a limit and queues the rest.
+// Slots freed by release go to the longest waiting request first.
+type bulkhead struct {
+	mu           sync.Mutex
+	limits       bulkheadLimits
+	inFlight     int
+	limit        float64 // adaptive limit; fractional so it can grow by 1/limit per response
+	waiters      list.List
+	lastDecrease time.Time
+}
+
+// current returns the limit in force; the caller holds b.mu.
+func (b *bulkhead) current() int {
+	if b.limits.target == 0 {
+		return b.limits.max
+	}
+	if b.limit == 0 {
+		b.limit = float64(b.limits.max)
+	}
+	b.limit = min(max(b.limit, float64(b.limits.min)), float64(b.limits.max))
+	return int(b.limit)
+}
+
+// tryAcquire takes a slot if one is free and nobody is queued ahead.
+func (b *bulkhead) tryAcquire(limits bulkheadLimits) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limits = limits
+	if b.waiters.Len() == 0 && b.inFlight < b.current() {
+		b.inFlight++
+		return true
+	}
+	return false
+}
+
+// acquire takes a slot, waiting up to timeout behind at most maxQueue others.
+func (b *bulkhead) acquire(ctx context.Context, limits bulkheadLimits, maxQueue int, timeout time.Duration, metrics *routeMetrics) error {
+	if b.tryAcquire(limits) {
+		return nil
+	}
+	b.mu.Lock()
+	if b.waiters.Len() >= maxQueue {
+		b.mu.Unlock()
+		return errQueueFull
+	}
+	ready := make(chan struct{})
+	elem := b.waiters.PushBack(ready)
+	b.mu.Unlock()
+
+	metrics.queued.Add(1)
+	defer metrics.queued.Add(-1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-ready:
+		return nil // handed a slot just as we gave up; use it
+	default:
+		b.waiters.Remove(elem)
+		return err
+	}
+}
+
+// release frees a slot and hands it to the next waiter when the limit allows.
+// latency of the finished request feeds the adaptive limit; zero skips it.
+func (b *bulkhead) release(latency time.Duration, timedOut bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if latency > 0 || timedOut {
+		b.observe(latency, timedOut)
+	}
+	b.inFlight--
+	for b.waiters.Len() > 0 && b.inFlight < b.current() {
+		ready := b.waiters.Remove(b.waiters.Front()).(chan struct{})
+		b.inFlight++
+		close(ready)
+	}
+}
+
+// observe adjusts the adaptive limit; the caller holds b.mu. Decreases are
+// spaced by the target latency so one slow burst does not collapse the limit.
+func (b *bulkhead) observe(latency time.Duration, timedOut bool) {
+	target := b.limits.target
+	if target == 0 {
+		return
+	}
+	limit := float64(b.current())
+	now := time.Now()
+	switch {
+	case timedOut || latency > target:
+		if now.Sub(b.lastDecrease) >= target {
+			b.limit = max(b.limit*adaptiveDecreaseFactor, float64(b.limits.min))
+			b.lastDecrease = now
+		}
+	case b.inFlight >= int(limit):
+		b.limit = min(b.limit+1/b.limit, float64(b.limits.max))
+	}
+}
+
+// snapshot returns the limit in force and the queue length.
+func (b *bulkhead) snapshot() (limit, queued int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits.max == 0 {
+		return 0, b.waiters.Len()
+	}
+	return b.current(), b.waiters.Len()
+}
+
+// bulkheadTicket is a held slot; a nil ticket holds nothing.
+type bulkheadTicket struct {
+	b *bulkhead
+}
+
+func (t *bulkheadTicket) release(latency time.Duration, timedOut bool) {
+	if t != nil {
+		t.b.release(latency, timedOut)
+	}
+}
+
+// bulkheads keeps limiter state across config reloads, keyed by backend and
+// endpoint ID; the zero value is ready to use.
+type bulkheads struct {
+	mu        sync.Mutex
+	routes    map[string]*bulkhead
+	endpoints map[uint]*bulkhead
+}
+
+func (reg *bulkheads) route(backendID string) *bulkhead {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.routes == nil {
+		reg.routes = make(map[string]*bulkhead)
+	}
+	b, ok := reg.routes[backendID]
+	if !ok {
+		b = &bulkhead{}
+		reg.routes[backendID] = b
+	}
+	return b
+}
+
+func (reg *bulkheads) endpoint(endpointID uint) *bulkhead {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.endpoints == nil {
+		reg.endpoints = make(map[uint]*bulkhead)
+	}
+	b, ok := reg.endpoints[endpointID]
+	if !ok {
+		b = &bulkhead{}
+		reg.endpoints[endpointID] = b
+	}
+	return b
+}
+
+// routeSnapshot reports a route's limit and queue, or zeros when it has none.
+func (reg *bulkheads) routeSnapshot(backendID string) (limit, queued int) {
+	reg.mu.Lock()
+	b, ok := reg.routes[backendID]
+	reg.mu.Unlock()
+	if !ok {
+		return 0, 0
+	}
+	return b.snapshot()
+}
+
+// admitRoute takes a slot of the route's limit. It returns a nil ticket when
+// the route has none.
+func (g *Gateway) admitRoute(r *http.Request, cfg *BackendConfig, metrics *routeMetrics) (*bulkheadTicket, error) {
+	policy := cfg.Concurrency
+	if policy == nil || policy.MaxInFlight == 0 {
+		return nil, nil
+	}
+	b := g.bulkheads.route(cfg.ID)
+	if err := b.acquire(r.Context(), policy.routeLimits(), policy.MaxQueue, policy.queueTimeout(), metrics); err != nil {
+		return nil, err
+	}
+	return &bulkheadTicket{b: b}, nil
+}
+
+// pickEndpointWithSlot picks an endpoint with a free slot, trying each healthy
+// endpoint once in balancer order. When all are busy it queues for the first.
+func (g *Gateway) pickEndpointWithSlot(r *http.Request, cfg *BackendConfig, metrics *routeMetrics) (*BackendEndpoint, *bulkheadTicket, error) {
+	first := pickEndpoint(r.Context(), cfg)
+	policy := cfg.Concurrency
+	if first == nil || policy == nil || policy.MaxInFlightPerEndpoint == 0 {
+		return first, nil, nil
+	}
+	limits := bulkheadLimits{max: policy.MaxInFlightPerEndpoint}
+	for candidate, tries := first, 0; candidate != nil && tries < len(cfg.Endpoints); tries++ {
+		if b := g.bulkheads.endpoint(candidate.ID); b.tryAcquire(limits) {
+			return candidate, &bulkheadTicket{b: b}, nil
+		}
+		if candidate = cfg.GetNextHealthyEndpoint(); candidate == first {
+			break
+		}
+	}
+	b := g.bulkheads.endpoint(first.ID)
+	if err := b.acquire(r.Context(), limits, policy.MaxQueue, policy.queueTimeout(), metrics); err != nil {
+		return nil, nil, err
+	}
+	return first, &bulkheadTicket{b: b}, nil
+}
+
+// rejectOverload answers a request that could not get a slot.
+func (g *Gateway) rejectOverload(w http.ResponseWriter, r *http.Request, cfg *BackendConfig, metrics *routeMetrics, err error) {
+	metrics.concurrencyRejected.Add(1)
+	noteReason(w, "concurrency limit: "+err.Error())
+	w.Header().Set("Retry-After", strconv.Itoa(cfg.Concurrency.retryAfter()))
+	if isGRPCRequest(r) {
+		writeGRPCError(w, grpcStatusUnavailable, "concurrency limit reached")
+		return
+	}
+	g.writeError(w, r, cfg, http.StatusServiceUnavailable, "Too many requests in flight.")
+}
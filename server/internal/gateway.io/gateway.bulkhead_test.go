@@ -0,0 +1,134 @@
+package gatewayio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBulkheadQueue(t *testing.T) {
+	b := &bulkhead{}
+	limits := bulkheadLimits{max: 1}
+	metrics := &routeMetrics{}
+	ctx := context.Background()
+
+	if err := b.acquire(ctx, limits, 0, time.Second, metrics); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	if err := b.acquire(ctx, limits, 0, time.Second, metrics); !errors.Is(err, errQueueFull) {
+		t.Fatalf("acquire with no queue = %v, want errQueueFull", err)
+	}
+	if err := b.acquire(ctx, limits, 1, 20*time.Millisecond, metrics); !errors.Is(err, errQueueTimeout) {
+		t.Fatalf("queued acquire = %v, want errQueueTimeout", err)
+	}
+
+	// Waiters are served in arrival order as slots free up.
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func() {
+			if err := b.acquire(ctx, limits, 2, time.Second, metrics); err == nil {
+				order <- i
+			}
+		}()
+		waitFor(t, func() bool { return metrics.queued.Load() == int64(i) })
+	}
+	if b.tryAcquire(limits) {
+		t.Fatal("tryAcquire jumped the queue")
+	}
+	b.release(0, false)
+	if got := <-order; got != 1 {
+		t.Fatalf("first admitted waiter = %d, want 1", got)
+	}
+	b.release(0, false)
+	if got := <-order; got != 2 {
+		t.Fatalf("second admitted waiter = %d, want 2", got)
+	}
+	b.release(0, false)
+	if limit, queued := b.snapshot(); limit != 1 || queued != 0 || b.inFlight != 0 {
+		t.Errorf("snapshot = %d/%d, inFlight %d; want 1/0, 0", limit, queued, b.inFlight)
+	}
+}
+
+func TestBulkheadAcquireCancelled(t *testing.T) {
+	b := &bulkhead{}
+	limits := bulkheadLimits{max: 1}
+	b.tryAcquire(limits)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.acquire(ctx, limits, 1, time.Second, &routeMetrics{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire = %v, want context.Canceled", err)
+	}
+	if _, queued := b.snapshot(); queued != 0 {
+		t.Errorf("cancelled waiter left in the queue")
+	}
+}
+
+func TestBulkheadAdaptiveLimit(t *testing.T) {
+	policy := &ConcurrencyPolicy{MaxInFlight: 10, Adaptive: &AdaptiveLimit{TargetLatencyMs: 1, MinLimit: 8}}
+	limits := policy.routeLimits()
+	b := &bulkhead{}
+	b.tryAcquire(limits)
+
+	// A slow response shrinks the limit by 10%; another within the target
+	// latency of the last decrease is ignored.
+	b.release(time.Second, false)
+	if limit, _ := b.snapshot(); limit != 9 {
+		t.Fatalf("limit after slow response = %d, want 9", limit)
+	}
+	b.tryAcquire(limits)
+	b.release(0, true)
+	if limit, _ := b.snapshot(); limit != 9 {
+		t.Fatalf("limit after back-to-back timeout = %d, want 9", limit)
+	}
+
+	// Never below MinLimit.
+	for range 20 {
+		time.Sleep(2 * time.Millisecond)
+		b.tryAcquire(limits)
+		b.release(time.Second, false)
+	}
+	if limit, _ := b.snapshot(); limit != 8 {
+		t.Fatalf("limit after repeated slow responses = %d, want 8", limit)
+	}
+
+	// Fast responses on a saturated route grow it back up to MaxInFlight.
+	for range 200 {
+		for b.tryAcquire(limits) {
+		}
+		b.release(time.Microsecond, false)
+	}
+	if limit, _ := b.snapshot(); limit != 10 {
+		t.Fatalf("limit after fast responses = %d, want 10", limit)
+	}
+}
+
+func TestConcurrencyPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ConcurrencyPolicy
+		ok     bool
+	}{
+		{"no limit", ConcurrencyPolicy{}, false},
+		{"route limit", ConcurrencyPolicy{MaxInFlight: 5}, true},
+		{"endpoint limit", ConcurrencyPolicy{MaxInFlightPerEndpoint: 5}, true},
+		{"adaptive without ceiling", ConcurrencyPolicy{MaxInFlightPerEndpoint: 5, Adaptive: &AdaptiveLimit{TargetLatencyMs: 100}}, false},
+		{"adaptive floor above ceiling", ConcurrencyPolicy{MaxInFlight: 5, Adaptive: &AdaptiveLimit{TargetLatencyMs: 100, MinLimit: 6}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met within 1s")
+}
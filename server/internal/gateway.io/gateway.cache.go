@@ -0,0 +1,517 @@
+// gateway.cache.go
+package gatewayio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CacheStatusHeader tells clients how the response cache handled the request.
+	CacheStatusHeader = "X-Cache"
+
+	defaultCacheMaxBodyBytes = 1 << 20
+	// cacheRevalidateGrace keeps entries with validators past their stale window
+	// so a conditional request can still turn a 304 into a full response.
+	cacheRevalidateGrace = 10 * time.Minute
+	cacheStoreTimeout    = 2 * time.Second
+)
+
+// X-Cache values.
+const (
+	cacheHit         = "HIT"
+	cacheStale       = "STALE"       // served stale while a background refresh runs
+	cacheRevalidated = "REVALIDATED" // upstream answered 304 to a conditional request
+	cacheMiss        = "MISS"
+	cacheBypass      = "BYPASS" // the request or response may not be cached
+)
+
+// CachePolicy enables the response cache for GET and HEAD requests on a route.
+// Upstream Cache-Control, Expires and Vary headers take precedence over the defaults.
+type CachePolicy struct {
+	DefaultTTLSeconds           int      `json:"defaultTtlSeconds" binding:"omitempty,gte=0"`           // for responses without freshness headers; 0 leaves them uncached
+	MaxTTLSeconds               int      `json:"maxTtlSeconds" binding:"omitempty,gte=0"`               // caps upstream max-age; 0 means no cap
+	StaleWhileRevalidateSeconds int      `json:"staleWhileRevalidateSeconds" binding:"omitempty,gte=0"` // used when the upstream sends none
+	MaxBodyBytes                int64    `json:"maxBodyBytes" binding:"omitempty,gte=0"`                // larger responses are not stored; 0 uses 1 MiB
+	Tags                        []string `json:"tags,omitempty"`                                        // added to every entry, for purges
+}
+
+func (p *CachePolicy) maxBodyBytes() int64 {
+	if p.MaxBodyBytes > 0 {
+		return p.MaxBodyBytes
+	}
+	return defaultCacheMaxBodyBytes
+}
+
+// cacheControl is a parsed Cache-Control header; directives map to their
+// value, or "" when they have none.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns a delta-seconds directive, or -1 when it is absent or malformed.
+func (cc cacheControl) seconds(directive string) time.Duration {
+	v, ok := cc[directive]
+	if !ok {
+		return -1
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return time.Duration(n) * time.Second
+}
+
+// cacheLookup carries the cache state of one request through the proxy.
+type cacheLookup struct {
+	cache   CacheStore
+	policy  *CachePolicy
+	baseKey string
+	entry   *cacheEntry // stale entry being revalidated; nil on a miss
+	head    bool
+}
+
+// cacheKey identifies a response by route, path and query.
+func cacheKey(backendID string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(backendID + "|" + r.URL.RequestURI()))
+	return hex.EncodeToString(sum[:])
+}
+
+// variantKey extends the base key with the request's values of the Vary headers.
+func variantKey(baseKey string, vary []string, r *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(baseKey))
+	for _, name := range vary {
+		h.Write([]byte("|" + name + "=" + strings.Join(r.Header.Values(name), ",")))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheableRequest reports whether r may be answered from, or stored in, the cache.
+func cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Range") != "" || isGRPCRequest(r) {
+		return false
+	}
+	return !parseCacheControl(r.Header).has("no-store")
+}
+
+// lookupCache fetches the entry for r, following a Vary marker to the variant.
+// It returns a nil lookup when the route has no cache or r bypasses it.
+func (g *Gateway) lookupCache(r *http.Request, cfg *BackendConfig) (*cacheLookup, *cacheEntry) {
+	if g.Cache == nil || cfg.Cache == nil || !cacheableRequest(r) {
+		return nil, nil
+	}
+	lookup := &cacheLookup{cache: g.Cache, policy: cfg.Cache, baseKey: cacheKey(cfg.ID, r), head: r.Method == http.MethodHead}
+	if parseCacheControl(r.Header).has("no-cache") {
+		return lookup, nil // the client wants a fresh copy; store it, don't serve ours
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cacheStoreTimeout)
+	defer cancel()
+	entry, err := g.Cache.Get(ctx, lookup.baseKey)
+	if err == nil && entry != nil && entry.Status == 0 {
+		entry, err = g.Cache.Get(ctx, variantKey(lookup.baseKey, entry.Vary, r))
+	}
+	if err != nil {
+		log.Printf("ERROR: Response cache lookup for backend %s failed: %v", cfg.ID, err)
+		return lookup, nil
+	}
+	return lookup, entry
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	return e.Age + now.Sub(e.StoredAt)
+}
+
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return e.age(now) < e.FreshFor
+}
+
+// staleUsable reports whether the entry is inside its stale-while-revalidate window.
+func (e *cacheEntry) staleUsable(now time.Time) bool {
+	return e.age(now) < e.FreshFor+e.StaleFor
+}
+
+func (e *cacheEntry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// notModified reports whether the client's conditional headers match the entry.
+func (e *cacheEntry) notModified(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(e.Header.Get("ETag"), "W/")
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || (etag != "" && candidate == etag) {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err1 := http.ParseTime(ims)
+		modified, err2 := http.ParseTime(e.Header.Get("Last-Modified"))
+		return err1 == nil && err2 == nil && !modified.After(since)
+	}
+	return false
+}
+
+// serveCached writes entry to w with the given X-Cache status.
+func serveCached(w http.ResponseWriter, r *http.Request, entry *cacheEntry, status string) {
+	header := w.Header()
+	for k, vs := range entry.Header {
+		header[k] = slices.Clone(vs)
+	}
+	header.Set("Age", strconv.Itoa(int(entry.age(time.Now()).Seconds())))
+	header.Set(CacheStatusHeader, status)
+	if entry.Status == http.StatusOK && entry.notModified(r) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+// conditionalHeaders adds the entry's validators to a revalidation request
+// unless the client sent conditions of its own.
+func (e *cacheEntry) conditionalHeaders(h http.Header) bool {
+	if h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		return false
+	}
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		h.Set("If-Modified-Since", lm)
+	}
+	return true
+}
+
+// heuristicallyCacheable lists the statuses RFC 9111 allows caching by default.
+var heuristicallyCacheable = map[int]bool{
+	http.StatusOK: true, http.StatusNonAuthoritativeInfo: true, http.StatusNoContent: true,
+	http.StatusMultipleChoices: true, http.StatusMovedPermanently: true, http.StatusPermanentRedirect: true,
+	http.StatusNotFound: true, http.StatusGone: true,
+}
+
+// newCacheEntry builds an entry from an upstream response, or returns nil when
+// the response may not be stored. The body is filled in later.
+func (l *cacheLookup) newCacheEntry(backendID, path string, resp *http.Response) *cacheEntry {
+	if !heuristicallyCacheable[resp.StatusCode] || isStreamContentType(resp.Header) {
+		return nil
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || cc.has("private") || resp.Header.Get("Set-Cookie") != "" {
+		return nil
+	}
+	if resp.ContentLength > l.policy.maxBodyBytes() {
+		return nil
+	}
+	vary := varyHeaders(resp.Header)
+	if slices.Contains(vary, "*") {
+		return nil
+	}
+
+	now := time.Now()
+	freshFor := l.freshness(cc, resp.Header, now)
+	staleFor := cc.seconds("stale-while-revalidate")
+	if staleFor < 0 {
+		staleFor = time.Duration(l.policy.StaleWhileRevalidateSeconds) * time.Second
+	}
+	entry := &cacheEntry{
+		BackendID: backendID,
+		Path:      path,
+		Status:    resp.StatusCode,
+		Header:    cacheableHeaders(resp.Header),
+		Tags:      responseTags(resp.Header, l.policy.Tags),
+		Vary:      vary,
+		StoredAt:  now,
+		FreshFor:  freshFor,
+		StaleFor:  staleFor,
+	}
+	if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil && age > 0 {
+		entry.Age = time.Duration(age) * time.Second
+	}
+	entry.KeepFor = freshFor + staleFor - entry.Age
+	if entry.hasValidators() {
+		entry.KeepFor += cacheRevalidateGrace
+	}
+	if entry.KeepFor <= 0 {
+		return nil
+	}
+	return entry
+}
+
+// freshness picks s-maxage, max-age, Expires and then the policy default, capped
+// by MaxTTLSeconds. no-cache responses are stored but always revalidated.
+func (l *cacheLookup) freshness(cc cacheControl, h http.Header, now time.Time) time.Duration {
+	if cc.has("no-cache") {
+		return 0
+	}
+	ttl := cc.seconds("s-maxage")
+	if ttl < 0 {
+		ttl = cc.seconds("max-age")
+	}
+	if ttl < 0 && h.Get("Expires") != "" {
+		ttl = 0 // malformed or past Expires means already stale
+		if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+			date, err := http.ParseTime(h.Get("Date"))
+			if err != nil {
+				date = now
+			}
+			ttl = max(expires.Sub(date), 0)
+		}
+	}
+	if ttl < 0 {
+		ttl = time.Duration(l.policy.DefaultTTLSeconds) * time.Second
+	}
+	if maxTTL := time.Duration(l.policy.MaxTTLSeconds) * time.Second; maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl
+}
+
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// cacheableHeaders copies the response headers worth replaying from the cache.
+func cacheableHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range []string{"Age", CacheStatusHeader, "Content-Length", RequestIDHeader, TraceParentHeader, "Tracestate"} {
+		out.Del(name)
+	}
+	return out
+}
+
+// responseTags merges the Cache-Tag and Surrogate-Key headers with the policy tags.
+func responseTags(h http.Header, policyTags []string) []string {
+	tags := slices.Clone(policyTags)
+	for _, name := range []string{"Cache-Tag", "Surrogate-Key"} {
+		for _, line := range h.Values(name) {
+			tags = append(tags, strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' })...)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// save stores entry under the base key, or under its variant key behind a Vary marker.
+func (l *cacheLookup) save(r *http.Request, entry *cacheEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheStoreTimeout)
+	defer cancel()
+	key := l.baseKey
+	if len(entry.Vary) > 0 {
+		marker := &cacheEntry{BackendID: entry.BackendID, Path: entry.Path, Tags: entry.Tags, Vary: entry.Vary, StoredAt: entry.StoredAt, KeepFor: entry.KeepFor}
+		if err := l.cache.Set(ctx, key, marker); err != nil {
+			log.Printf("ERROR: Storing cached response for backend %s failed: %v", entry.BackendID, err)
+			return
+		}
+		key = variantKey(l.baseKey, entry.Vary, r)
+	}
+	if err := l.cache.Set(ctx, key, entry); err != nil {
+		log.Printf("ERROR: Storing cached response for backend %s failed: %v", entry.BackendID, err)
+	}
+}
+
+// refreshed merges the headers of a 304 into a copy of the entry and restarts its clock.
+func (l *cacheLookup) refreshed(resp *http.Response) *cacheEntry {
+	header := l.entry.Header.Clone()
+	for k, vs := range cacheableHeaders(resp.Header) {
+		header[k] = vs
+	}
+	merged := &http.Response{StatusCode: l.entry.Status, Header: header, ContentLength: int64(len(l.entry.Body))}
+	entry := l.newCacheEntry(l.entry.BackendID, l.entry.Path, merged)
+	if entry != nil {
+		entry.Body = l.entry.Body
+	}
+	return entry
+}
+
+// handleResponse applies the cache to an upstream response inside ModifyResponse:
+// a 304 to our conditional request becomes the cached response, and storable
+// responses are captured as they stream to the client.
+func (l *cacheLookup) handleResponse(r *http.Request, resp *http.Response, backendID string, metrics *routeMetrics) {
+	if l.entry != nil && resp.StatusCode == http.StatusNotModified {
+		entry := l.refreshed(resp)
+		if entry == nil {
+			entry = l.entry // no longer storable; answer once from the old copy
+		} else {
+			go l.save(r, entry)
+		}
+		resp.Body.Close()
+		resp.StatusCode = entry.Status
+		resp.Status = strconv.Itoa(entry.Status) + " " + http.StatusText(entry.Status)
+		resp.Header = entry.Header.Clone()
+		resp.Header.Set(CacheStatusHeader, cacheRevalidated)
+		resp.ContentLength = int64(len(entry.Body))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		metrics.cacheHits.Add(1)
+		return
+	}
+
+	entry := l.newCacheEntry(backendID, r.URL.Path, resp)
+	if entry == nil {
+		resp.Header.Set(CacheStatusHeader, cacheBypass)
+		return
+	}
+	metrics.cacheMisses.Add(1)
+	resp.Header.Set(CacheStatusHeader, cacheMiss)
+	if l.head {
+		return // HEAD responses have no body to store
+	}
+	resp.Body = &cacheCaptureBody{ReadCloser: resp.Body, limit: l.policy.maxBodyBytes(), done: func(body []byte) {
+		entry.Body = body
+		go l.save(r, entry)
+	}}
+}
+
+// cacheCaptureBody copies what the client reads and hands it to done at EOF,
+// unless the body outgrew limit.
+type cacheCaptureBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	finished bool
+	done     func([]byte)
+}
+
+func (b *cacheCaptureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.overflow {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.overflow && !b.finished {
+		b.finished = true
+		b.done(b.buf.Bytes())
+	}
+	return n, err
+}
+
+// revalidateInBackground refreshes a stale entry served under stale-while-revalidate.
+func (g *Gateway) revalidateInBackground(r *http.Request, cfg *BackendConfig, lookup *cacheLookup) {
+	key := variantKey(lookup.baseKey, lookup.entry.Vary, r)
+	if _, running := g.revalidations.LoadOrStore(key, true); running {
+		return
+	}
+	endpoint := cfg.GetNextHealthyEndpoint()
+	if endpoint == nil {
+		g.revalidations.Delete(key)
+		return
+	}
+	req := r.Clone(context.Background())
+	go func() {
+		defer g.revalidations.Delete(key)
+		timeout := cfg.responseTimeout()
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		target := *endpoint.URLParsed
+		target.Path = upstreamPath(endpoint.URLParsed, cfg.PathPrefix, r.URL.Path)
+		target.RawQuery = r.URL.RawQuery
+		upstream, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			return
+		}
+		upstream.Header = req.Header.Clone()
+		for _, name := range []string{"Connection", "Upgrade", "Te", "Keep-Alive", "If-None-Match", "If-Modified-Since"} {
+			upstream.Header.Del(name)
+		}
+		lookup.entry.conditionalHeaders(upstream.Header)
+		injectTraceContext(ctx, upstream.Header)
+
+		client := &http.Client{
+			Transport:     cfg.upstreamTransport(endpoint),
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		resp, err := client.Do(upstream)
+		if err != nil {
+			log.Printf("WARN: Background cache refresh of %s on backend %s failed: %v", r.URL.Path, cfg.ID, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotModified {
+			if entry := lookup.refreshed(resp); entry != nil {
+				lookup.save(req, entry)
+			}
+			return
+		}
+		entry := lookup.newCacheEntry(cfg.ID, r.URL.Path, resp)
+		if entry == nil {
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, lookup.policy.maxBodyBytes()+1))
+		if err != nil || int64(len(body)) > lookup.policy.maxBodyBytes() {
+			return
+		}
+		entry.Body = body
+		lookup.save(req, entry)
+	}()
+}
+
+// PurgeCache drops cached responses matching purge and returns how many went.
+func (g *Gateway) PurgeCache(ctx context.Context, purge CachePurge) (int, error) {
+	if g.Cache == nil {
+		return 0, nil
+	}
+	if purge.Prefix != "" {
+		if u, err := url.Parse(purge.Prefix); err == nil {
+			purge.Prefix = u.Path
+		}
+	}
+	return g.Cache.Purge(ctx, purge)
+}
@@ -0,0 +1,179 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	h := http.Header{}
+	h.Add("Cache-Control", `public, Max-Age=60`)
+	h.Add("Cache-Control", `stale-while-revalidate="30", s-maxage=abc`)
+	cc := parseCacheControl(h)
+
+	if !cc.has("public") || cc.has("private") {
+		t.Fatalf("directives = %v", cc)
+	}
+	tests := []struct {
+		directive string
+		want      time.Duration
+	}{
+		{"max-age", 60 * time.Second},
+		{"stale-while-revalidate", 30 * time.Second},
+		{"s-maxage", -1}, // malformed
+		{"no-cache", -1}, // absent
+	}
+	for _, tt := range tests {
+		if got := cc.seconds(tt.directive); got != tt.want {
+			t.Errorf("seconds(%q) = %v, want %v", tt.directive, got, tt.want)
+		}
+	}
+}
+
+func TestCacheFreshness(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		header map[string]string
+		want   time.Duration
+	}{
+		{"s-maxage wins", CachePolicy{}, map[string]string{"Cache-Control": "max-age=10, s-maxage=20"}, 20 * time.Second},
+		{"max-age", CachePolicy{}, map[string]string{"Cache-Control": "max-age=10"}, 10 * time.Second},
+		{"no-cache is stale", CachePolicy{DefaultTTLSeconds: 60}, map[string]string{"Cache-Control": "no-cache, max-age=10"}, 0},
+		{"expires relative to date", CachePolicy{}, map[string]string{
+			"Expires": now.Add(time.Hour).Format(http.TimeFormat),
+			"Date":    now.Add(-time.Hour).Format(http.TimeFormat),
+		}, 2 * time.Hour},
+		{"past expires", CachePolicy{}, map[string]string{"Expires": now.Add(-time.Hour).Format(http.TimeFormat)}, 0},
+		{"malformed expires", CachePolicy{DefaultTTLSeconds: 60}, map[string]string{"Expires": "0"}, 0},
+		{"policy default", CachePolicy{DefaultTTLSeconds: 60}, nil, 60 * time.Second},
+		{"max ttl caps upstream", CachePolicy{MaxTTLSeconds: 30}, map[string]string{"Cache-Control": "max-age=3600"}, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			l := &cacheLookup{policy: &tt.policy}
+			if got := l.freshness(parseCacheControl(h), h, now); got != tt.want {
+				t.Errorf("freshness = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheEntryLifetime(t *testing.T) {
+	stored := time.Now()
+	e := &cacheEntry{StoredAt: stored, Age: 5 * time.Second, FreshFor: 10 * time.Second, StaleFor: 20 * time.Second}
+
+	tests := []struct {
+		after             time.Duration
+		fresh, staleUsage bool
+	}{
+		{0, true, true},
+		{4 * time.Second, true, true},
+		{5 * time.Second, false, true},
+		{24 * time.Second, false, true},
+		{25 * time.Second, false, false},
+	}
+	for _, tt := range tests {
+		now := stored.Add(tt.after)
+		if got := e.fresh(now); got != tt.fresh {
+			t.Errorf("fresh after %v = %t, want %t", tt.after, got, tt.fresh)
+		}
+		if got := e.staleUsable(now); got != tt.staleUsage {
+			t.Errorf("staleUsable after %v = %t, want %t", tt.after, got, tt.staleUsage)
+		}
+	}
+}
+
+func TestCacheEntryNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := &cacheEntry{Header: http.Header{
+		"Etag":          {`W/"v1"`},
+		"Last-Modified": {modified.Format(http.TimeFormat)},
+	}}
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   bool
+	}{
+		{"weak etag match", map[string]string{"If-None-Match": `"v0", "v1"`}, true},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, true},
+		{"etag mismatch beats date", map[string]string{"If-None-Match": `"v2"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"modified since", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, false},
+		{"unconditional", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := e.notModified(r); got != tt.want {
+				t.Errorf("notModified = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheableRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{"get", http.MethodGet, nil, true},
+		{"head", http.MethodHead, nil, true},
+		{"post", http.MethodPost, nil, false},
+		{"authorization", http.MethodGet, map[string]string{"Authorization": "Bearer x"}, false},
+		{"range", http.MethodGet, map[string]string{"Range": "bytes=0-1"}, false},
+		{"no-store", http.MethodGet, map[string]string{"Cache-Control": "no-store"}, false},
+		{"no-cache still cacheable", http.MethodGet, map[string]string{"Cache-Control": "no-cache"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := cacheableRequest(r); got != tt.want {
+				t.Errorf("cacheableRequest = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCacheEntry(t *testing.T) {
+	l := &cacheLookup{policy: &CachePolicy{DefaultTTLSeconds: 60}}
+	tests := []struct {
+		name   string
+		header map[string]string
+		stored bool
+	}{
+		{"default ttl", nil, true},
+		{"private", map[string]string{"Cache-Control": "private, max-age=60"}, false},
+		{"no-store", map[string]string{"Cache-Control": "no-store"}, false},
+		{"set-cookie", map[string]string{"Set-Cookie": "a=b"}, false},
+		{"vary star", map[string]string{"Vary": "*"}, false},
+		{"already expired by age", map[string]string{"Cache-Control": "max-age=10", "Age": "20"}, false},
+		{"validators keep an expired entry for revalidation", map[string]string{"Cache-Control": "max-age=0", "ETag": `"a"`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+			for k, v := range tt.header {
+				resp.Header.Set(k, v)
+			}
+			if got := l.newCacheEntry("b", "/", resp) != nil; got != tt.stored {
+				t.Errorf("stored = %t, want %t", got, tt.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// gateway.cachestore.go
+package gatewayio
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"imanager.io/config"
+)
+
+const (
+	defaultCacheMemoryMB = 256
+	redisCachePrefix     = "imanager:cache:"
+)
+
+// cacheEntry is one stored response, or, when Vary is set, the marker that
+// tells lookups which request headers select the stored variant.
+type cacheEntry struct {
+	BackendID string
+	Path      string // request path, for prefix purges
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Tags      []string
+	Vary      []string
+	StoredAt  time.Time
+	Age       time.Duration // Age the upstream reported when the entry was stored
+	FreshFor  time.Duration
+	StaleFor  time.Duration // stale-while-revalidate window after FreshFor
+	KeepFor   time.Duration // how long the store keeps the entry
+}
+
+func (e *cacheEntry) size() int64 {
+	n := int64(len(e.Body)) + 256
+	for k, vs := range e.Header {
+		for _, v := range vs {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// CachePurge selects entries to drop; every non-empty field must match.
+type CachePurge struct {
+	BackendID string   `json:"backendId"`
+	Prefix    string   `json:"prefix"`
+	Tags      []string `json:"tags"`
+}
+
+func (p CachePurge) matches(e *cacheEntry) bool {
+	if p.BackendID != "" && e.BackendID != p.BackendID {
+		return false
+	}
+	if p.Prefix != "" && !strings.HasPrefix(e.Path, p.Prefix) {
+		return false
+	}
+	for _, tag := range p.Tags {
+		found := false
+		for _, t := range e.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// CacheStore holds cached responses. Get returns nil without error on a miss.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*cacheEntry, error)
+	Set(ctx context.Context, key string, entry *cacheEntry) error
+	Purge(ctx context.Context, purge CachePurge) (int, error)
+}
+
+// NewCacheStore builds the store named in cfg: "memory" (the default) or "redis".
+func NewCacheStore(cfg config.CacheConfig, redisCfg config.ConfigRedis) (CacheStore, error) {
+	switch cfg.Store {
+	case "", "memory":
+		mb := cfg.MaxMemoryMB
+		if mb <= 0 {
+			mb = defaultCacheMemoryMB
+		}
+		return newMemoryCacheStore(int64(mb) << 20), nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     redisCfg.Host + ":" + redisCfg.Port,
+			Password: redisCfg.Password,
+			DB:       cfg.RedisDB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, fmt.Errorf("connect to redis at %s:%s: %w", redisCfg.Host, redisCfg.Port, err)
+		}
+		return &redisCacheStore{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q (want memory or redis)", cfg.Store)
+	}
+}
+
+// memoryCacheStore is an LRU bounded by the approximate byte size of its entries.
+type memoryCacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type memoryItem struct {
+	key       string
+	entry     *cacheEntry
+	size      int64
+	expiresAt time.Time
+}
+
+func newMemoryCacheStore(maxBytes int64) *memoryCacheStore {
+	return &memoryCacheStore{maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (s *memoryCacheStore) Get(_ context.Context, key string) (*cacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		s.removeLocked(el)
+		return nil, nil
+	}
+	s.order.MoveToFront(el)
+	return item.entry, nil
+}
+
+func (s *memoryCacheStore) Set(_ context.Context, key string, entry *cacheEntry) error {
+	item := &memoryItem{key: key, entry: entry, size: entry.size(), expiresAt: entry.StoredAt.Add(entry.KeepFor)}
+	if item.size > s.maxBytes {
+		return nil // larger than the whole cache
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.removeLocked(el)
+	}
+	s.items[key] = s.order.PushFront(item)
+	s.used += item.size
+	for s.used > s.maxBytes {
+		s.removeLocked(s.order.Back())
+	}
+	return nil
+}
+
+func (s *memoryCacheStore) Purge(_ context.Context, purge CachePurge) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purged := 0
+	for el := s.order.Front(); el != nil; {
+		next := el.Next()
+		if purge.matches(el.Value.(*memoryItem).entry) {
+			s.removeLocked(el)
+			purged++
+		}
+		el = next
+	}
+	return purged, nil
+}
+
+func (s *memoryCacheStore) removeLocked(el *list.Element) {
+	item := el.Value.(*memoryItem)
+	s.order.Remove(el)
+	delete(s.items, item.key)
+	s.used -= item.size
+}
+
+// redisCacheStore keeps gob-encoded entries in Redis so every gateway instance
+// shares them. Route and tag sets index the keys for purges.
+type redisCacheStore struct {
+	client *redis.Client
+}
+
+func (s *redisCacheStore) entryKey(key string) string { return redisCachePrefix + "e:" + key }
+func (s *redisCacheStore) routeKey(id string) string  { return redisCachePrefix + "route:" + id }
+func (s *redisCacheStore) tagKey(tag string) string   { return redisCachePrefix + "tag:" + tag }
+
+func (s *redisCacheStore) Get(ctx context.Context, key string) (*cacheEntry, error) {
+	data, err := s.client.Get(ctx, s.entryKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *redisCacheStore) Set(ctx context.Context, key string, entry *cacheEntry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	entryKey := s.entryKey(key)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, entryKey, buf.Bytes(), entry.KeepFor)
+	for _, index := range s.indexKeys(entry) {
+		pipe.SAdd(ctx, index, entryKey)
+		pipe.Expire(ctx, index, entry.KeepFor) // extends the set while it keeps receiving entries
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisCacheStore) indexKeys(entry *cacheEntry) []string {
+	keys := []string{s.routeKey(entry.BackendID)}
+	for _, tag := range entry.Tags {
+		keys = append(keys, s.tagKey(tag))
+	}
+	return keys
+}
+
+// Purge narrows candidates with the tag or route index, then checks each entry.
+func (s *redisCacheStore) Purge(ctx context.Context, purge CachePurge) (int, error) {
+	var candidates []string
+	var err error
+	switch {
+	case len(purge.Tags) > 0:
+		candidates, err = s.client.SMembers(ctx, s.tagKey(purge.Tags[0])).Result()
+	case purge.BackendID != "":
+		candidates, err = s.client.SMembers(ctx, s.routeKey(purge.BackendID)).Result()
+	default:
+		iter := s.client.Scan(ctx, 0, redisCachePrefix+"e:*", 500).Iterator()
+		for iter.Next(ctx) {
+			candidates = append(candidates, iter.Val())
+		}
+		err = iter.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, entryKey := range candidates {
+		entry, err := s.Get(ctx, strings.TrimPrefix(entryKey, redisCachePrefix+"e:"))
+		if err != nil {
+			return purged, err
+		}
+		if entry == nil || !purge.matches(entry) {
+			continue
+		}
+		if err := s.client.Del(ctx, entryKey).Err(); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,158 @@
+// gateway.certmonitor.go
+package gatewayio
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultCertWarningDays is how close to expiry an upstream certificate gets
+// before the gateway warns, unless Gateway.CertWarningDays says otherwise.
+const DefaultCertWarningDays = 14
+
+// PeerCertificate summarises one certificate an upstream presented.
+type PeerCertificate struct {
+	Subject      string    `json:"subject"`
+	DNSNames     []string  `json:"dnsNames,omitempty"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+// EndpointTLSInfo is what the latest health probe saw of an HTTPS endpoint's certificate.
+type EndpointTLSInfo struct {
+	ServerName    string            `json:"serverName"` // name the leaf was checked against
+	Chain         []PeerCertificate `json:"chain"`      // leaf first, as presented
+	NotAfter      time.Time         `json:"notAfter"`   // earliest expiry in Chain
+	HostnameError string            `json:"hostnameError,omitempty"`
+	CheckedAt     time.Time         `json:"checkedAt"`
+}
+
+// DaysLeft is the number of whole days until NotAfter; negative once expired.
+func (i *EndpointTLSInfo) DaysLeft(now time.Time) int {
+	return int(i.NotAfter.Sub(now).Hours() / 24)
+}
+
+// sameChain reports whether other describes the same certificates and hostname result.
+func (i *EndpointTLSInfo) sameChain(other *EndpointTLSInfo) bool {
+	if i == nil || other == nil || len(i.Chain) != len(other.Chain) ||
+		i.ServerName != other.ServerName || i.HostnameError != other.HostnameError {
+		return false
+	}
+	for n := range i.Chain {
+		a, b := i.Chain[n], other.Chain[n]
+		if a.SerialNumber != b.SerialNumber || a.Issuer != b.Issuer || !a.NotAfter.Equal(b.NotAfter) {
+			return false
+		}
+	}
+	return true
+}
+
+// newEndpointTLSInfo summarises chain and checks its leaf against serverName.
+// nil when the upstream presented nothing.
+func newEndpointTLSInfo(chain []*x509.Certificate, serverName string) *EndpointTLSInfo {
+	if len(chain) == 0 {
+		return nil
+	}
+	info := &EndpointTLSInfo{ServerName: serverName, CheckedAt: time.Now()}
+	for _, c := range chain {
+		info.Chain = append(info.Chain, PeerCertificate{
+			Subject:      c.Subject.String(),
+			DNSNames:     c.DNSNames,
+			Issuer:       c.Issuer.String(),
+			SerialNumber: c.SerialNumber.String(),
+			NotBefore:    c.NotBefore,
+			NotAfter:     c.NotAfter,
+		})
+		if info.NotAfter.IsZero() || c.NotAfter.Before(info.NotAfter) {
+			info.NotAfter = c.NotAfter
+		}
+	}
+	if err := chain[0].VerifyHostname(serverName); err != nil {
+		info.HostnameError = err.Error()
+	}
+	return info
+}
+
+// peerCertificates returns the chain seen by a finished probe: the connection
+// state on success, or the unverified chain when verification failed, so expired
+// and mismatched certificates are still recorded.
+func peerCertificates(resp *http.Response, err error) []*x509.Certificate {
+	if resp != nil && resp.TLS != nil {
+		return resp.TLS.PeerCertificates
+	}
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return verifyErr.UnverifiedCertificates
+	}
+	return nil
+}
+
+// upstreamServerName is the name endpoint certificates must cover.
+func (b *BackendConfig) upstreamServerName(endpoint *BackendEndpoint) string {
+	if b.UpstreamTLS != nil && b.UpstreamTLS.ServerName != "" {
+		return b.UpstreamTLS.ServerName
+	}
+	return endpoint.URLParsed.Hostname()
+}
+
+func (g *Gateway) certWarningDays() int {
+	if g.CertWarningDays > 0 {
+		return g.CertWarningDays
+	}
+	return DefaultCertWarningDays
+}
+
+// UpstreamCertificate is one row of the upstream certificate report.
+type UpstreamCertificate struct {
+	BackendID  string           `json:"backendId"`
+	PathPrefix string           `json:"pathPrefix"`
+	EndpointID uint             `json:"endpointId"`
+	URL        string           `json:"url"`
+	DaysLeft   int              `json:"daysLeft"`
+	TLS        *EndpointTLSInfo `json:"tls"`
+}
+
+// UpstreamCertificates lists the certificates seen on HTTPS endpoints, soonest
+// expiry first. withinDays > 0 keeps only those expiring within that many days
+// or failing the hostname check.
+func (g *Gateway) UpstreamCertificates(withinDays int) []UpstreamCertificate {
+	now := time.Now()
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := []UpstreamCertificate{}
+	for _, cfg := range g.backends {
+		cfg.mu.RLock()
+		endpoints := make([]BackendEndpoint, 0, len(cfg.Endpoints))
+		for _, ep := range cfg.Endpoints {
+			endpoints = append(endpoints, BackendEndpoint{ID: ep.ID, URL: ep.URL, TLS: ep.TLS})
+		}
+		cfg.mu.RUnlock()
+		for _, ep := range endpoints {
+			info := ep.TLS
+			if info == nil {
+				continue
+			}
+			daysLeft := info.DaysLeft(now)
+			if withinDays > 0 && daysLeft >= withinDays && info.HostnameError == "" {
+				continue
+			}
+			out = append(out, UpstreamCertificate{
+				BackendID:  cfg.ID,
+				PathPrefix: cfg.PathPrefix,
+				EndpointID: ep.ID,
+				URL:        ep.URL,
+				DaysLeft:   daysLeft,
+				TLS:        info,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TLS.NotAfter.Before(out[j].TLS.NotAfter) })
+	return out
+}
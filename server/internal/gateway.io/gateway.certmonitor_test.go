@@ -0,0 +1,166 @@
+package gatewayio
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"imanager.io/internal/eventbus"
+)
+
+// testCertificate returns a self-signed certificate for names. The monitor only
+// summarises chains, so the certificates need not chain to each other.
+func testCertificate(t *testing.T, serial int64, notAfter time.Time, names ...string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "cert-" + big.NewInt(serial).String()},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestNewEndpointTLSInfo(t *testing.T) {
+	if newEndpointTLSInfo(nil, "api.test") != nil {
+		t.Error("empty chain produced info")
+	}
+	now := time.Now()
+	ca := testCertificate(t, 1, now.Add(20*24*time.Hour))
+	leaf := testCertificate(t, 2, now.Add(90*24*time.Hour), "api.test")
+
+	info := newEndpointTLSInfo([]*x509.Certificate{leaf, ca}, "api.test")
+	if len(info.Chain) != 2 || info.Chain[0].SerialNumber != "2" || info.HostnameError != "" {
+		t.Errorf("info = %+v", info)
+	}
+	if !info.NotAfter.Equal(ca.NotAfter) || info.DaysLeft(now) != 19 {
+		t.Errorf("NotAfter %v (%d days), want the CA's %v", info.NotAfter, info.DaysLeft(now), ca.NotAfter)
+	}
+	if mismatch := newEndpointTLSInfo([]*x509.Certificate{leaf, ca}, "other.test"); mismatch.HostnameError == "" || mismatch.sameChain(info) {
+		t.Errorf("hostname mismatch = %+v", mismatch)
+	}
+	if again := newEndpointTLSInfo([]*x509.Certificate{leaf, ca}, "api.test"); !again.sameChain(info) {
+		t.Error("the same chain compared as changed")
+	}
+	if renewed := newEndpointTLSInfo([]*x509.Certificate{testCertificate(t, 3, now.Add(90*24*time.Hour), "api.test"), ca}, "api.test"); renewed.sameChain(info) {
+		t.Error("a renewed leaf compared as unchanged")
+	}
+}
+
+func TestPeerCertificates(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The default client does not trust the test server, but the chain is still reported.
+	resp, err := http.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("untrusted server verified")
+	}
+	if chain := peerCertificates(resp, err); len(chain) != 1 || !chain[0].Equal(srv.Certificate()) {
+		t.Errorf("unverified chain = %v", chain)
+	}
+
+	resp, err = srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if chain := peerCertificates(resp, err); len(chain) != 1 || !chain[0].Equal(srv.Certificate()) {
+		t.Errorf("verified chain = %v", chain)
+	}
+	if chain := peerCertificates(nil, http.ErrHandlerTimeout); chain != nil {
+		t.Errorf("non-TLS error gave %v", chain)
+	}
+}
+
+// tlsRepo counts certificate writes.
+type tlsRepo struct {
+	BackendRepository
+	writes int
+}
+
+func (r *tlsRepo) UpdateEndpointTLS(uint, *EndpointTLSInfo) error {
+	r.writes++
+	return nil
+}
+
+func TestSetEndpointTLS(t *testing.T) {
+	now := time.Now()
+	ca := testCertificate(t, 1, now.Add(365*24*time.Hour))
+	healthy := []*x509.Certificate{testCertificate(t, 2, now.Add(90*24*time.Hour), "api.test"), ca}
+	expiring := []*x509.Certificate{testCertificate(t, 3, now.Add(5*24*time.Hour), "api.test"), ca}
+
+	repo := &tlsRepo{}
+	g := &Gateway{Events: eventbus.NewBus(10)}
+	sub, _ := g.Events.Subscribe(eventbus.Filter{eventbus.TypeCertExpiring}, 0)
+	defer sub.Close()
+	cfg := &BackendConfig{ID: "b1", PathPrefix: "/api", Endpoints: []*BackendEndpoint{{ID: 7, URL: "https://api.test"}}}
+	s := &backendService{repo: repo, gateway: g, runtimeCache: map[string]*BackendConfig{"b1": cfg}}
+
+	steps := []struct {
+		name   string
+		chain  []*x509.Certificate
+		server string
+		writes int
+		events int
+	}{
+		{"first probe", healthy, "api.test", 1, 0},
+		{"unchanged", healthy, "api.test", 1, 0},
+		{"renewed close to expiry", expiring, "api.test", 2, 1},
+		{"still expiring, same day", expiring, "api.test", 2, 1},
+		{"hostname mismatch", healthy, "other.test", 3, 2},
+	}
+	events := 0
+	for _, step := range steps {
+		s.SetEndpointTLS("b1", "https://api.test", newEndpointTLSInfo(step.chain, step.server))
+		for len(sub.Events()) > 0 {
+			ev := <-sub.Events()
+			if data := ev.Data.(eventbus.CertificateExpiryData); data.ID != 7 || data.BackendID != "b1" {
+				t.Errorf("%s: event %+v", step.name, data)
+			}
+			events++
+		}
+		if repo.writes != step.writes || events != step.events {
+			t.Errorf("%s: %d writes, %d events, want %d and %d", step.name, repo.writes, events, step.writes, step.events)
+		}
+	}
+	s.SetEndpointTLS("b1", "https://unknown.test", newEndpointTLSInfo(healthy, "api.test"))
+	s.SetEndpointTLS("missing", "https://api.test", newEndpointTLSInfo(healthy, "api.test"))
+	if repo.writes != 3 {
+		t.Errorf("unknown endpoint or route was written")
+	}
+
+	g.backends = map[string]*BackendConfig{"b1": cfg}
+	if list := g.UpstreamCertificates(0); len(list) != 1 || list[0].EndpointID != 7 || list[0].PathPrefix != "/api" {
+		t.Errorf("UpstreamCertificates(0) = %+v", list)
+	}
+	// A hostname mismatch is reported whatever the expiry.
+	if list := g.UpstreamCertificates(14); len(list) != 1 {
+		t.Errorf("UpstreamCertificates(14) = %+v, want the mismatched endpoint", list)
+	}
+	s.SetEndpointTLS("b1", "https://api.test", newEndpointTLSInfo(healthy, "api.test"))
+	if list := g.UpstreamCertificates(14); len(list) != 0 {
+		t.Errorf("UpstreamCertificates(14) = %+v, want none", list)
+	}
+}
@@ -0,0 +1,246 @@
+// gateway.coalesce.go
+package gatewayio
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCoalesceMaxWait      = 5 * time.Second
+	defaultCoalesceMaxBodyBytes = 1 << 20
+)
+
+// CoalescePolicy lets concurrent identical GET requests on a route share one
+// upstream call. Requests are identical when method, path, query and the
+// listed headers match.
+type CoalescePolicy struct {
+	Headers      []string `json:"headers,omitempty"`                      // request headers that distinguish responses, e.g. Accept-Language
+	MaxWaitMs    int      `json:"maxWaitMs" binding:"omitempty,gte=0"`    // followers give up and call upstream themselves after this; 0 uses 5s
+	MaxBodyBytes int64    `json:"maxBodyBytes" binding:"omitempty,gte=0"` // larger responses are not shared; 0 uses 1 MiB
+}
+
+func (p *CoalescePolicy) maxWait() time.Duration {
+	if p.MaxWaitMs > 0 {
+		return time.Duration(p.MaxWaitMs) * time.Millisecond
+	}
+	return defaultCoalesceMaxWait
+}
+
+func (p *CoalescePolicy) maxBodyBytes() int64 {
+	if p.MaxBodyBytes > 0 {
+		return p.MaxBodyBytes
+	}
+	return defaultCoalesceMaxBodyBytes
+}
+
+// flight is one upstream call that identical requests wait on.
+type flight struct {
+	leaderID string
+	done     chan struct{} // closed once result is final
+	mu       sync.Mutex
+	waiters  int
+	result   *flightResult // nil when the response could not be shared
+}
+
+type flightResult struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// coalescer maps request keys to their flight in progress; the zero value is ready to use.
+type coalescer struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// coalesceKey identifies identical requests, or returns "" when r may not be
+// shared: only plain GETs without credentials outside the policy's headers.
+// Routes with a CORS policy also key on Origin, since the leader's response
+// carries the Access-Control headers for its own origin.
+func coalesceKey(cfg *BackendConfig, r *http.Request) string {
+	policy := cfg.Coalesce
+	if r.Method != http.MethodGet || isGRPCRequest(r) || r.Header.Get("Range") != "" {
+		return ""
+	}
+	for _, credential := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(credential) != "" && !slices.ContainsFunc(policy.Headers, func(h string) bool {
+			return strings.EqualFold(h, credential)
+		}) {
+			return ""
+		}
+	}
+	var b strings.Builder
+	b.WriteString(cfg.ID + "|" + r.Method + "|" + r.URL.RequestURI())
+	for _, name := range policy.Headers {
+		b.WriteString("|" + strings.Join(r.Header.Values(name), ","))
+	}
+	if cfg.CORS != nil {
+		b.WriteString("|origin=" + r.Header.Get("Origin"))
+	}
+	return b.String()
+}
+
+// join returns the flight for key and whether the caller leads it.
+func (c *coalescer) join(key, requestID string) (*flight, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.flights[key]; ok {
+		f.mu.Lock()
+		f.waiters++
+		f.mu.Unlock()
+		return f, false
+	}
+	if c.flights == nil {
+		c.flights = make(map[string]*flight)
+	}
+	f := &flight{leaderID: requestID, done: make(chan struct{})}
+	c.flights[key] = f
+	return f, true
+}
+
+// finish publishes the leader's result, releases the followers and returns how
+// many were still waiting to take it.
+func (c *coalescer) finish(key string, f *flight, result *flightResult) int {
+	c.mu.Lock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	c.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return 0 // already finished
+	default:
+	}
+	f.result = result
+	close(f.done)
+	if result == nil {
+		return 0
+	}
+	return f.waiters
+}
+
+// wait blocks until the leader finishes or maxWait passes, returning the shared
+// result or nil when the follower has to call upstream itself.
+func (f *flight) wait(r *http.Request, maxWait time.Duration) *flightResult {
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case <-f.done:
+		return f.result
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return f.result
+	default:
+		f.waiters--
+		return nil
+	}
+}
+
+// write replays the shared response; the follower keeps its own correlation headers.
+func (res *flightResult) write(w http.ResponseWriter, method string) {
+	header := w.Header()
+	for k, vs := range res.header {
+		switch k {
+		case RequestIDHeader, TraceParentHeader, "Tracestate":
+			continue
+		}
+		header[k] = slices.Clone(vs)
+	}
+	w.WriteHeader(res.status)
+	if method != http.MethodHead {
+		w.Write(res.body)
+	}
+}
+
+// coalesceWriter passes the leader's response through to its client while
+// keeping a copy for the followers, and abandons the flight if the response
+// grows past the limit.
+type coalesceWriter struct {
+	http.ResponseWriter
+	coalescer *coalescer
+	key       string
+	flight    *flight
+	limit     int64
+	status    int
+	header    http.Header // as the proxy set it, before the leader's compression
+	body      []byte
+	abandoned bool
+}
+
+// WriteHeader snapshots the headers before delegating: a compressionWriter
+// below rewrites the shared map for the leader's encoding, while the captured
+// body stays plain.
+func (w *coalesceWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *coalesceWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.abandoned {
+		if int64(len(w.body)+len(p)) > w.limit {
+			w.abandon()
+		} else {
+			w.body = append(w.body, p...)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *coalesceWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *coalesceWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// abandon releases the followers to call upstream themselves; it is a no-op
+// once the flight has finished.
+func (w *coalesceWriter) abandon() {
+	if !w.abandoned {
+		w.abandoned = true
+		w.body = nil
+		w.coalescer.finish(w.key, w.flight, nil)
+	}
+}
+
+// complete shares the captured response and returns how many followers take it.
+func (w *coalesceWriter) complete() int {
+	if w.abandoned {
+		return 0
+	}
+	w.abandoned = true
+	header := w.header
+	if header == nil {
+		header = w.Header().Clone()
+	}
+	if header.Get("Set-Cookie") != "" {
+		return w.coalescer.finish(w.key, w.flight, nil) // never hand one client's cookies to another
+	}
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return w.coalescer.finish(w.key, w.flight, &flightResult{status: status, header: header, body: w.body})
+}
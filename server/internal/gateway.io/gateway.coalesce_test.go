@@ -0,0 +1,102 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCoalesceKey(t *testing.T) {
+	cfg := &BackendConfig{ID: "b", Coalesce: &CoalescePolicy{Headers: []string{"Accept", "Authorization"}}}
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		shared bool
+	}{
+		{"plain get", http.MethodGet, nil, true},
+		{"head", http.MethodHead, nil, false},
+		{"range", http.MethodGet, map[string]string{"Range": "bytes=0-1"}, false},
+		{"cookie outside the policy", http.MethodGet, map[string]string{"Cookie": "s=1"}, false},
+		{"authorization in the policy", http.MethodGet, map[string]string{"Authorization": "Bearer x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/items?page=1", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := coalesceKey(cfg, r) != ""; got != tt.shared {
+				t.Errorf("shared = %t, want %t", got, tt.shared)
+			}
+		})
+	}
+
+	key := func(accept, auth string) string {
+		r := httptest.NewRequest(http.MethodGet, "/items?page=1", nil)
+		r.Header.Set("Accept", accept)
+		r.Header.Set("Authorization", auth)
+		return coalesceKey(cfg, r)
+	}
+	if key("json", "a") != key("json", "a") {
+		t.Error("identical requests got different keys")
+	}
+	if key("json", "a") == key("xml", "a") || key("json", "a") == key("json", "b") {
+		t.Error("requests differing in a policy header share a key")
+	}
+
+	// Origin only splits flights on routes whose CORS headers depend on it.
+	originKey := func(origin string) string {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		r.Header.Set("Origin", origin)
+		return coalesceKey(cfg, r)
+	}
+	if originKey("https://a.test") != originKey("https://b.test") {
+		t.Error("origin split flights on a route without CORS")
+	}
+	cfg.CORS = &CORSPolicy{AllowOrigins: []string{"https://a.test", "https://b.test"}}
+	if originKey("https://a.test") == originKey("https://b.test") {
+		t.Error("requests from different origins share a flight on a CORS route")
+	}
+}
+
+func TestCoalescerFlight(t *testing.T) {
+	var c coalescer
+	leader, leads := c.join("k", "r1")
+	if !leads {
+		t.Fatal("first request does not lead")
+	}
+	for _, id := range []string{"r2", "r3"} {
+		if f, leads := c.join("k", id); leads || f != leader {
+			t.Fatalf("%s did not join the leader's flight", id)
+		}
+	}
+
+	result := &flightResult{status: http.StatusOK}
+	if n := c.finish("k", leader, result); n != 2 {
+		t.Errorf("finish served %d followers, want 2", n)
+	}
+	if n := c.finish("k", leader, result); n != 0 {
+		t.Errorf("second finish served %d followers, want 0", n)
+	}
+	select {
+	case <-leader.done:
+	default:
+		t.Fatal("flight not marked done")
+	}
+	if _, leads := c.join("k", "r4"); !leads {
+		t.Error("request after finish joined a finished flight")
+	}
+}
+
+func TestCoalescerUnsharedResult(t *testing.T) {
+	var c coalescer
+	f, _ := c.join("k", "r1")
+	c.join("k", "r2")
+	if n := c.finish("k", f, nil); n != 0 {
+		t.Errorf("finish without a result served %d followers", n)
+	}
+	if f.result != nil {
+		t.Error("followers were handed a result")
+	}
+}
@@ -0,0 +1,261 @@
+// gateway.compress.go
+package gatewayio
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings the gateway can produce.
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+const defaultCompressMinSize = 1024
+
+var (
+	defaultEncodings    = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+	defaultContentTypes = []string{
+		"text/*", "application/json", "application/javascript", "application/xml",
+		"application/problem+json", "application/x-ndjson", "image/svg+xml",
+	}
+)
+
+// CompressionPolicy compresses upstream responses for clients that accept it.
+// Responses that are already encoded, event or gRPC streams, known to be
+// smaller than MinSizeBytes, or of unknown length (chunked) pass through
+// unchanged unless their type is listed in StreamContentTypes.
+type CompressionPolicy struct {
+	Encodings          []string `json:"encodings,omitempty" binding:"omitempty,dive,oneof=gzip br zstd"` // preference order; empty uses zstd, br, gzip
+	MinSizeBytes       int64    `json:"minSizeBytes" binding:"omitempty,gte=0"`                          // 0 uses 1024
+	ContentTypes       []string `json:"contentTypes,omitempty"`                                          // media types or "type/*"; empty uses a text/JSON/XML list
+	StreamContentTypes []string `json:"streamContentTypes,omitempty"`                                    // types compressed even without a Content-Length; empty compresses none
+}
+
+func (p *CompressionPolicy) encodings() []string {
+	if len(p.Encodings) > 0 {
+		return p.Encodings
+	}
+	return defaultEncodings
+}
+
+func (p *CompressionPolicy) minSize() int64 {
+	if p.MinSizeBytes > 0 {
+		return p.MinSizeBytes
+	}
+	return defaultCompressMinSize
+}
+
+func (p *CompressionPolicy) allowsContentType(contentType string) bool {
+	types := p.ContentTypes
+	if len(types) == 0 {
+		types = defaultContentTypes
+	}
+	return matchMediaType(types, contentType)
+}
+
+// matchMediaType reports whether contentType is one of types, which may hold
+// "type/*" wildcards.
+func matchMediaType(types []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range types {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the coding with the highest client q-value, breaking ties by
+// the policy's order. It returns "" when the client accepts none of them.
+func (p *CompressionPolicy) negotiate(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range p.encodings() {
+		q, ok := weights[encoding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressionWriter decides at WriteHeader whether to compress, based on the
+// response headers the proxy (or the cache) has set by then.
+type compressionWriter struct {
+	http.ResponseWriter
+	policy   *CompressionPolicy
+	encoding string
+	encoder  io.WriteCloser
+	decided  bool
+}
+
+// wrapCompression returns a writer that compresses responses to r, or nil when
+// the route has no policy or the request does not qualify.
+func (b *BackendConfig) wrapCompression(w http.ResponseWriter, r *http.Request) *compressionWriter {
+	if b.Compression == nil || r.Method == http.MethodHead || isGRPCRequest(r) {
+		return nil
+	}
+	encoding := b.Compression.negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+	return &compressionWriter{ResponseWriter: w, policy: b.Compression, encoding: encoding}
+}
+
+func (w *compressionWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decided = true
+		if w.shouldCompress(status) {
+			h := w.Header()
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", w.encoding)
+			h.Add("Vary", "Accept-Encoding")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag) // the bytes differ from the upstream's representation
+			}
+			w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressionWriter) shouldCompress(status int) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if parseCacheControl(h).has("no-transform") || isStreamContentType(h) {
+		return false
+	}
+	// Without a length the body is chunked and may be a long poll or NDJSON
+	// stream, which an encoder would hold back between flushes.
+	length, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return matchMediaType(w.policy.StreamContentTypes, h.Get("Content-Type"))
+	}
+	if length < w.policy.minSize() {
+		return false
+	}
+	return w.policy.allowsContentType(h.Get("Content-Type"))
+}
+
+func (w *compressionWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.encoder.Write(p)
+}
+
+// Flush pushes buffered compressed bytes to the client first.
+func (w *compressionWriter) Flush() {
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (w *compressionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close finishes the compressed stream; ServeHTTP defers it.
+func (w *compressionWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	releaseEncoder(w.encoding, w.encoder)
+	w.encoder = nil
+	return err
+}
+
+var (
+	gzipPool   = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	brotliPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }}
+	zstdPool   = sync.Pool{New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}}
+)
+
+func newEncoder(encoding string, dst io.Writer) io.WriteCloser {
+	switch encoding {
+	case EncodingBrotli:
+		enc := brotliPool.Get().(*brotli.Writer)
+		enc.Reset(dst)
+		return enc
+	case EncodingZstd:
+		enc := zstdPool.Get().(*zstd.Encoder)
+		enc.Reset(dst)
+		return enc
+	default:
+		enc := gzipPool.Get().(*gzip.Writer)
+		enc.Reset(dst)
+		return enc
+	}
+}
+
+func releaseEncoder(encoding string, enc io.WriteCloser) {
+	switch encoding {
+	case EncodingBrotli:
+		brotliPool.Put(enc)
+	case EncodingZstd:
+		zstdPool.Put(enc)
+	default:
+		gzipPool.Put(enc)
+	}
+}
@@ -0,0 +1,168 @@
+package gatewayio
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompressionNegotiate(t *testing.T) {
+	policy := &CompressionPolicy{}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, br", EncodingBrotli},
+		{"gzip, br, zstd", EncodingZstd},
+		{"gzip;q=1, br;q=0.5", EncodingGzip},
+		{"br;q=0, gzip;q=0.1", EncodingGzip},
+		{"*", EncodingZstd},
+		{"*;q=0.5, gzip", EncodingGzip},
+		{"GZIP", EncodingGzip},
+	}
+	for _, tt := range tests {
+		if got := policy.negotiate(tt.accept); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+	if got := (&CompressionPolicy{Encodings: []string{EncodingGzip}}).negotiate("br, zstd"); got != "" {
+		t.Errorf("negotiate outside the policy's encodings = %q", got)
+	}
+}
+
+func TestCompressionShouldCompress(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CompressionPolicy
+		status int
+		header map[string]string
+		want   bool
+	}{
+		{"large json", CompressionPolicy{}, 200, map[string]string{"Content-Type": "application/json", "Content-Length": "2048"}, true},
+		{"small", CompressionPolicy{}, 200, map[string]string{"Content-Type": "text/html", "Content-Length": "100"}, false},
+		{"image", CompressionPolicy{}, 200, map[string]string{"Content-Type": "image/png", "Content-Length": "2048"}, false},
+		{"already encoded", CompressionPolicy{}, 200, map[string]string{"Content-Type": "text/html", "Content-Length": "2048", "Content-Encoding": "br"}, false},
+		{"no-transform", CompressionPolicy{}, 200, map[string]string{"Content-Type": "text/html", "Content-Length": "2048", "Cache-Control": "no-transform"}, false},
+		{"partial content", CompressionPolicy{}, 206, map[string]string{"Content-Type": "text/html", "Content-Length": "2048"}, false},
+		{"not modified", CompressionPolicy{}, 304, map[string]string{"Content-Type": "text/html"}, false},
+		{"event stream", CompressionPolicy{StreamContentTypes: []string{"text/*"}}, 200, map[string]string{"Content-Type": "text/event-stream"}, false},
+		{"chunked", CompressionPolicy{}, 200, map[string]string{"Content-Type": "application/x-ndjson"}, false},
+		{"chunked on the stream list", CompressionPolicy{StreamContentTypes: []string{"text/html"}}, 200, map[string]string{"Content-Type": "text/html; charset=utf-8"}, true},
+		{"chunked off the stream list", CompressionPolicy{StreamContentTypes: []string{"text/html"}}, 200, map[string]string{"Content-Type": "application/x-ndjson"}, false},
+		{"custom types", CompressionPolicy{ContentTypes: []string{"application/wasm"}}, 200, map[string]string{"Content-Type": "application/wasm", "Content-Length": "2048"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := &compressionWriter{ResponseWriter: rec, policy: &tt.policy, encoding: EncodingGzip}
+			for k, v := range tt.header {
+				w.Header().Set(k, v)
+			}
+			if got := w.shouldCompress(tt.status); got != tt.want {
+				t.Errorf("shouldCompress = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressionWriter(t *testing.T) {
+	body := strings.Repeat("hello compression ", 200)
+	cfg := &BackendConfig{Compression: &CompressionPolicy{}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	w := cfg.wrapCompression(rec, r)
+	if w == nil {
+		t.Fatal("request accepting gzip was not wrapped")
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", `"v1"`)
+	io.WriteString(w, body)
+	w.Close()
+
+	h := rec.Header()
+	if h.Get("Content-Encoding") != EncodingGzip || h.Get("Content-Length") != "" || h.Get("Vary") != "Accept-Encoding" || h.Get("ETag") != `W/"v1"` {
+		t.Fatalf("headers = %v", h)
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != body {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(body))
+	}
+
+	if cfg.wrapCompression(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)) != nil {
+		t.Error("request without Accept-Encoding was wrapped")
+	}
+	head := httptest.NewRequest(http.MethodHead, "/", nil)
+	head.Header.Set("Accept-Encoding", "gzip")
+	if cfg.wrapCompression(httptest.NewRecorder(), head) != nil {
+		t.Error("HEAD request was wrapped")
+	}
+}
+
+// A leader compressing for its own client must still share the plain
+// representation, so each follower is encoded for what it accepts.
+func TestCoalescedFollowersWithCompression(t *testing.T) {
+	body := strings.Repeat(`{"item":"value"}`, 200)
+	cfg := &BackendConfig{ID: "b", Compression: &CompressionPolicy{}, Coalesce: &CoalescePolicy{}}
+	var c coalescer
+	flight, _ := c.join("k", "leader")
+	c.join("k", "follower")
+
+	leaderReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	leaderReq.Header.Set("Accept-Encoding", "gzip")
+	leaderRec := httptest.NewRecorder()
+	cw := cfg.wrapCompression(leaderRec, leaderReq)
+	leader := &coalesceWriter{ResponseWriter: cw, coalescer: &c, key: "k", flight: flight, limit: cfg.Coalesce.maxBodyBytes()}
+	leader.Header().Set("Content-Type", "application/json")
+	leader.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	leader.WriteHeader(http.StatusOK)
+	io.WriteString(leader, body)
+	cw.Close()
+	if n := leader.complete(); n != 1 {
+		t.Fatalf("complete shared with %d followers, want 1", n)
+	}
+	if got := gunzip(t, leaderRec.Body.Bytes()); got != body {
+		t.Fatal("leader body does not decode")
+	}
+
+	result := flight.result
+	if result.header.Get("Content-Encoding") != "" {
+		t.Fatalf("shared headers carry the leader's encoding: %v", result.header)
+	}
+
+	plain := httptest.NewRecorder()
+	result.write(plain, http.MethodGet)
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.String() != body {
+		t.Errorf("follower without Accept-Encoding got %v and %d bytes", plain.Header(), plain.Body.Len())
+	}
+
+	followerReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	followerReq.Header.Set("Accept-Encoding", "gzip")
+	gzipped := httptest.NewRecorder()
+	fw := cfg.wrapCompression(gzipped, followerReq)
+	result.write(fw, http.MethodGet)
+	fw.Close()
+	if gzipped.Header().Get("Content-Encoding") != EncodingGzip || gunzip(t, gzipped.Body.Bytes()) != body {
+		t.Errorf("gzip follower got %v", gzipped.Header())
+	}
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatalf("not gzip: %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
@@ -2,8 +2,13 @@
 package gatewayio
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -13,6 +18,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"imanager.io/internal/alerting"
+	"imanager.io/internal/eventbus"
+	"imanager.io/internal/tracing"
 )
 
 // Gateway is the core component that manages routing and policies.
@@ -21,6 +32,40 @@ type Gateway struct {
 	backends       map[string]*BackendConfig
 	mu             sync.RWMutex
 	BackendService BackendService
+	Alerts         *alerting.Engine // optional; nil disables alert observations
+	Events         *eventbus.Bus    // optional; nil disables event publishing
+	Cache          CacheStore       // optional; nil disables response caching on every route
+	// revalidations dedupes background refreshes of stale entries by variant key.
+	revalidations sync.Map
+	// SecretKey signs waiting room passes and positions; empty uses a per-process key.
+	SecretKey string
+	// StaticDir holds the unpacked archives of STATIC routes; empty uses DefaultStaticDir.
+	StaticDir string
+	// StaticRootDir is the only directory STATIC routes may serve host files from; empty allows uploads only.
+	StaticRootDir string
+	// StaticMaxArchiveBytes and StaticMaxSiteBytes bound uploads and their unpacked size; 0 uses 100 MiB and 500 MiB.
+	StaticMaxArchiveBytes int64
+	StaticMaxSiteBytes    int64
+	// AccessLogSampleRate is the fraction (0..1) of access logs published on Events.
+	AccessLogSampleRate float64
+	// HTTPSPort is where RedirectHTTPS routes send plain-HTTP clients; empty means 443.
+	HTTPSPort string
+	// CertWarningDays is how early upstream certificate expiry is warned about; 0 uses DefaultCertWarningDays.
+	CertWarningDays int
+	// wsConns tracks live WebSocket sessions and per-backend slots for MaxConnections.
+	wsConns    wsRegistry
+	metrics    metricsRegistry
+	l4         l4Manager
+	ipPolicy   ipPolicy
+	coalescer  coalescer
+	errorPages errorPages
+	bulkheads  bulkheads
+	rooms      waitingRooms
+	// staticUploads holds a *sync.Mutex per STATIC route serialising archive swaps.
+	staticUploads sync.Map
+	// endpointLoads and drains back endpoint drain mode.
+	endpointLoads endpointLoads
+	drains        drains
 }
 
 // NewGateway initializes the Gateway instance.
@@ -38,14 +83,6 @@ func NewGateway(svc BackendService) *Gateway {
 	}
 }
 
-var wsUpgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allowing all origins for simplicity. Restrict this in production!
-	},
-}
-
 // ReloadBackends replaces the current runtime map with the latest configs from the service.
 func (g *Gateway) ReloadBackends(configs []*BackendConfig) {
 	g.mu.Lock()
@@ -58,149 +95,227 @@ func (g *Gateway) ReloadBackends(configs []*BackendConfig) {
 
 	g.backends = newMap
 	log.Println("INFO: Gateway backend list reloaded. Total backends:", len(g.backends))
+	g.syncL4Listeners(configs)
+
+	ids := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		ids = append(ids, cfg.ID)
+	}
+	g.Events.Publish(eventbus.TypeConfigReload, eventbus.ConfigReloadData{
+		Backends:   len(newMap),
+		BackendIDs: ids,
+	})
 }
 
-func (g *Gateway) proxyWebSocket(w http.ResponseWriter, r *http.Request, matchedConfig *BackendConfig) {
-	// 1. Load Balancing & Target Selection
-	targetEndpoint := matchedConfig.GetNextHealthyEndpoint()
+// matchRoute returns the config with the longest PathPrefix matching path.
+func (g *Gateway) matchRoute(path string) *BackendConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 
-	if targetEndpoint == nil {
-		log.Printf("ERROR: Backend [%s] has no healthy WS targets for path %s", matchedConfig.ID, r.URL.Path)
-		http.Error(w, "503 Service Unavailable: No healthy WS targets found.", http.StatusServiceUnavailable)
-		return
+	var matchedConfig *BackendConfig
+	longestMatchLen := 0
+	for _, config := range g.backends {
+		if config.IsL4() {
+			continue // served by its own listener
+		}
+		if strings.HasPrefix(path, config.PathPrefix) {
+			if len(config.PathPrefix) > longestMatchLen {
+				longestMatchLen = len(config.PathPrefix)
+				matchedConfig = config
+			}
+		}
 	}
+	return matchedConfig
+}
 
-	// 2. Prepare Target URL
-	// Create the full destination URL (ws://host:port/path...)
-	backendURL := targetEndpoint.URLParsed
+// ServeHTTP is the handler for Gin's r.NoRoute. It performs routing, load balancing, and proxying.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 0. Correlation: request ID and W3C trace context
+	requestID := ensureRequestID(r)
+	ctx := extractTraceContext(r.Context(), r.Header)
+	ctx, span := tracing.Tracer().Start(ctx, "gateway.request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("gateway.request_id", requestID),
+		))
+	defer span.End()
+	r = r.WithContext(ctx)
+	echoTraceHeaders(ctx, w.Header(), requestID)
 
-	// The backend URL scheme must be ws or wss for the client dialer.
-	proxyScheme := "ws"
-	if backendURL.Scheme == "https" {
-		proxyScheme = "wss"
+	// 1. Route Lookup
+	_, matchSpan := tracing.Tracer().Start(ctx, "gateway.route_match")
+	matchedConfig := g.matchRoute(r.URL.Path)
+	if matchedConfig != nil {
+		matchSpan.SetAttributes(
+			attribute.String("gateway.backend_id", matchedConfig.ID),
+			attribute.String("gateway.path_prefix", matchedConfig.PathPrefix),
+		)
 	}
-
-	// CRITICAL: Construct the full URL for the backend connection.
-	// The path was already stripped of the PathPrefix in ServeHTTP.
-	targetWSURL := url.URL{
-		Scheme:   proxyScheme,
-		Host:     backendURL.Host,
-		Path:     r.URL.Path, // Use the pre-modified path (e.g., /socket.io/)
-		RawQuery: r.URL.RawQuery,
+	matchSpan.End()
+
+	// IP policy runs before anything else touches the request, including the 404.
+	ip := g.realClientIP(r)
+	if reason := g.checkIP(ip, matchedConfig); reason != "" {
+		span.SetStatus(codes.Error, "client address denied")
+		if matchedConfig != nil {
+			g.metrics.route(matchedConfig.ID).ipDenied.Add(1)
+		}
+		log.Printf("WARN: Denied %s %s from %s: %s", r.Method, r.URL.Path, ip, reason)
+		denyIP(w, r, reason)
+		return
 	}
 
-	log.Printf("INFO: Upgrading and proxying WS from %s to %s", r.RemoteAddr, targetWSURL.String())
-
-	// 3. Upgrade Client Connection (Hijacking)
-	clientConn, err := wsUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("ERROR: Failed to upgrade client to WebSocket: %v", err)
-		return // Upgrade failure already sends a 4xx response
+	if matchedConfig == nil {
+		span.SetStatus(codes.Error, "no matching route")
+		g.writeError(w, r, nil, http.StatusNotFound, "No matching backend route.")
+		return
 	}
-	defer clientConn.Close()
-
-	// 4. Dial Backend WebSocket Server
-	backendConn, _, err := websocket.DefaultDialer.Dial(targetWSURL.String(), r.Header)
-	if err != nil {
-		log.Printf("ERROR: Failed to dial backend WS %s: %v", targetWSURL.String(), err)
-		// If backend dial fails, close the client connection gracefully.
-		clientConn.CloseHandler()(websocket.CloseInternalServerErr, "Backend connection failed")
+	span.SetAttributes(attribute.String("gateway.backend_id", matchedConfig.ID))
+	metrics := g.metrics.route(matchedConfig.ID)
+	metrics.requests.Add(1)
+	metrics.activeRequests.Add(1)
+	defer metrics.activeRequests.Add(-1)
+
+	if matchedConfig.RedirectHTTPS && !isHTTPS(r) {
+		g.redirectToHTTPS(w, r)
+		return
+	}
+	if matchedConfig.applyCORS(w, r) {
+		return // preflight answered by the route's CORS policy
+	}
+	if g.serveMaintenance(w, r, matchedConfig) {
+		return
+	}
+	if g.serveWaitingRoom(w, r, matchedConfig) {
+		return
+	}
+	if g.injectFault(w, r, matchedConfig, metrics) {
 		return
 	}
-	defer backendConn.Close()
-
-	// 5. Bidirectional Pumping (Proxying Data)
-	// The core of the proxy: two goroutines to copy data concurrently.
-
-	// Channel to signal when one side closes
-	done := make(chan struct{})
-
-	// Client -> Backend (Read from client, write to backend)
-	go func() {
-		defer close(done) // Signal shutdown on exit
-		if err := proxyData(clientConn, backendConn); err != nil {
-			log.Printf("WS Proxy Error (Client->Backend): %v", err)
-		}
-	}()
-
-	// Backend -> Client (Read from backend, write to client)
-	go func() {
-		defer close(done) // Signal shutdown on exit
-		if err := proxyData(backendConn, clientConn); err != nil {
-			log.Printf("WS Proxy Error (Backend->Client): %v", err)
-		}
-	}()
-
-	// Wait for either the client or the backend to close the connection.
-	<-done
-
-	// Ensure both connections are closed gracefully.
-	clientConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "Gateway closing"), time.Now().Add(5*time.Second))
-	backendConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "Gateway closing"), time.Now().Add(5*time.Second))
-
-	log.Printf("INFO: WebSocket connection closed for route %s", matchedConfig.PathPrefix)
-}
 
-// proxyData is a helper function to read data from source and write to destination.
-func proxyData(src, dst *websocket.Conn) error {
-	for {
-		// Read message from the source connection
-		messageType, p, err := src.ReadMessage()
-		if err != nil {
-			// Check if error is due to normal closure
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				return err
-			}
-			return nil // Normal closure or expected error
-		}
+	// 2. WebSocket upgrades are detected on every route, not only ProtocolWS.
+	if websocket.IsWebSocketUpgrade(r) {
+		g.proxyWebSocket(w, r, matchedConfig)
+		return
+	}
+	// Compression wraps everything below, so cached and coalesced responses
+	// are encoded per client. The cache captures in ModifyResponse and the
+	// coalescer snapshots headers before this writer adds Content-Encoding,
+	// so both store the plain representation.
+	if cw := matchedConfig.wrapCompression(w, r); cw != nil {
+		defer cw.Close()
+		w = cw
+	}
+	switch matchedConfig.Protocol {
+	case ProtocolMock:
+		serveMock(w, r, matchedConfig.Mock)
+		return
+	case ProtocolStatic:
+		g.serveStatic(w, r, matchedConfig)
+		return
+	case ProtocolRedirect:
+		g.serveRedirect(w, r, matchedConfig)
+		return
+	}
 
-		// Write the same message type and payload to the destination connection
-		if err := dst.WriteMessage(messageType, p); err != nil {
-			return err
+	// 3. Response cache: fresh entries and stale-while-revalidate are served
+	// here; other stale entries with validators are revalidated by the proxy.
+	cache, cached := g.lookupCache(r, matchedConfig)
+	if cached != nil {
+		now := time.Now()
+		switch {
+		case cached.fresh(now):
+			metrics.cacheHits.Add(1)
+			serveCached(w, r, cached, cacheHit)
+			return
+		case cached.staleUsable(now):
+			metrics.cacheStale.Add(1)
+			cache.entry = cached
+			g.revalidateInBackground(r, matchedConfig, cache)
+			serveCached(w, r, cached, cacheStale)
+			return
+		case cached.hasValidators():
+			cache.entry = cached
 		}
 	}
-}
 
-// ServeHTTP is the handler for Gin's r.NoRoute. It performs routing, load balancing, and proxying.
-func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-
-	// 1. Route Lookup
-	var matchedConfig *BackendConfig
-	longestMatchLen := 0
-	for _, config := range g.backends {
-		if strings.HasPrefix(r.URL.Path, config.PathPrefix) {
-			if len(config.PathPrefix) > longestMatchLen {
-				longestMatchLen = len(config.PathPrefix)
-				matchedConfig = config
+	// Identical concurrent GETs wait for one leader and share its response.
+	var coalesced *coalesceWriter
+	if policy := matchedConfig.Coalesce; policy != nil {
+		if key := coalesceKey(matchedConfig, r); key != "" {
+			flight, leader := g.coalescer.join(key, requestID)
+			if !leader {
+				if result := flight.wait(r, policy.maxWait()); result != nil {
+					metrics.coalesced.Add(1)
+					noteReason(w, "coalesced with request "+flight.leaderID)
+					result.write(w, r.Method)
+					return
+				}
+			} else {
+				coalesced = &coalesceWriter{ResponseWriter: w, coalescer: &g.coalescer, key: key, flight: flight, limit: policy.maxBodyBytes()}
+				defer coalesced.abandon() // releases followers if the proxy panics
 			}
 		}
 	}
 
-	if matchedConfig == nil {
-		http.Error(w, "404 Not Found: No matching backend route.", http.StatusNotFound)
+	// 4. Concurrency limits: route slot first, then an endpoint with room.
+	// The slots are held until the response body has been copied.
+	var upstreamLatency time.Duration
+	var upstreamTimedOut bool
+	routeTicket, err := g.admitRoute(r, matchedConfig, metrics)
+	if err != nil {
+		g.rejectOverload(w, r, matchedConfig, metrics, err)
 		return
 	}
+	defer func() { routeTicket.release(upstreamLatency, upstreamTimedOut) }()
 
-	isWebSocket := r.Header.Get("Connection") == "Upgrade" && r.Header.Get("Upgrade") == "websocket"
-
-	if isWebSocket && matchedConfig.Protocol == "WS" {
-		// ... Path trimming logic ...
-		g.proxyWebSocket(w, r, matchedConfig)
+	// 5. LOAD BALANCING (HTTP/S Path)
+	targetEndpoint, endpointTicket, err := g.pickEndpointWithSlot(r.WithContext(ctx), matchedConfig, metrics)
+	if err != nil {
+		g.rejectOverload(w, r, matchedConfig, metrics, err)
 		return
 	}
-	// 3. LOAD BALANCING (HTTP/S Path)
-	targetEndpoint := matchedConfig.GetNextHealthyEndpoint()
+	defer func() { endpointTicket.release(upstreamLatency, upstreamTimedOut) }()
 
 	if targetEndpoint == nil {
 		log.Printf("ERROR: Backend [%s] has no healthy endpoints for path %s", matchedConfig.ID, r.URL.Path)
-		http.Error(w, "503 Service Unavailable: No healthy targets found.", http.StatusServiceUnavailable)
+		span.SetStatus(codes.Error, "no healthy endpoints")
+		if isGRPCRequest(r) {
+			writeGRPCError(w, grpcStatusUnavailable, "no healthy targets found")
+			return
+		}
+		g.writeError(w, r, matchedConfig, http.StatusServiceUnavailable, "No healthy targets found.")
 		return
 	}
+	defer g.endpointLoads.begin(targetEndpoint.ID)()
+	if coalesced != nil {
+		w = coalesced
+	}
+	mirror := g.startMirror(r, matchedConfig, requestID)
+	defer finishMirror(mirror, w, time.Now())
+
+	// 6. Proxy the Request (HTTP/S)
+	upstreamCtx, upstreamSpan := tracing.Tracer().Start(ctx, "gateway.upstream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", targetEndpoint.URLParsed.Host)))
+	defer upstreamSpan.End()
+
+	// The response timeout covers headers and body; it is lifted once the
+	// response turns out to be a stream, which gets the idle timeout instead.
+	upstreamCtx, cancelUpstream := context.WithCancelCause(upstreamCtx)
+	defer cancelUpstream(nil)
+	var responseTimer *time.Timer
+	if timeout := matchedConfig.responseTimeout(); timeout > 0 {
+		responseTimer = time.AfterFunc(timeout, func() { cancelUpstream(errResponseTimeout) })
+		defer responseTimer.Stop()
+	}
 
-	// 4. Proxy the Request (HTTP/S)
 	proxy := httputil.NewSingleHostReverseProxy(targetEndpoint.URLParsed)
+	if transport := matchedConfig.upstreamTransport(targetEndpoint); transport != nil {
+		proxy.Transport = transport
+	}
 
 	proxy.Director = func(req *http.Request) {
 		// Standard Reverse Proxy Configuration
@@ -208,21 +323,114 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		req.URL.Host = targetEndpoint.URLParsed.Host
 		req.Host = targetEndpoint.URLParsed.Host
 
-		// 🛑 FIX: The correct approach is to combine the backend's base path
-		// with the remaining client path.
-		// Get the path segment after the matched PathPrefix.
-		remainingPath := strings.TrimPrefix(r.URL.Path, matchedConfig.PathPrefix)
+		// Combine the backend's base path with the path after the matched PathPrefix.
+		// gRPC paths name the service method (/pkg.Service/Method) and pass through unchanged.
+		if matchedConfig.Protocol != ProtocolGRPC {
+			req.URL.Path = upstreamPath(targetEndpoint.URLParsed, matchedConfig.PathPrefix, r.URL.Path)
+		}
+
+		// Forward correlation headers; traceparent now points at the upstream span.
+		req.Header.Set(RequestIDHeader, requestID)
+		injectTraceContext(req.Context(), req.Header)
 
-		// Append it to the target endpoint's base path.
-		// This handles cases where the backend expects path segments.
-		targetPath := targetEndpoint.URLParsed.Path
-		if !strings.HasSuffix(targetPath, "/") && !strings.HasPrefix(remainingPath, "/") {
-			targetPath += "/"
+		if cache != nil && cache.entry != nil && !cache.entry.conditionalHeaders(req.Header) {
+			cache.entry = nil // the client's own conditions decide; pass its 304 through
 		}
-		req.URL.Path = targetPath + remainingPath
 	}
+	upstreamStart := time.Now()
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		upstreamLatency = time.Since(upstreamStart)
+		upstreamSpan.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			upstreamSpan.SetStatus(codes.Error, resp.Status)
+		}
+		// The gateway's values are already on the response; drop upstream echoes.
+		resp.Header.Del(RequestIDHeader)
+		resp.Header.Del(TraceParentHeader)
+		resp.Header.Del("Tracestate")
+		if matchedConfig.CORS != nil {
+			dropUpstreamCORS(resp.Header)
+		}
+
+		if cache != nil {
+			cache.handleResponse(r, resp, matchedConfig.ID, metrics)
+		} else if g.Cache != nil && matchedConfig.Cache != nil {
+			resp.Header.Set(CacheStatusHeader, cacheBypass)
+		}
 
-	proxy.ServeHTTP(w, r)
+		if coalesced != nil && isStreamContentType(resp.Header) {
+			coalesced.abandon() // followers should not wait for a stream to end
+		}
+		if isStreamingResponse(resp) {
+			if responseTimer != nil && !responseTimer.Stop() {
+				return errResponseTimeout // fired while the headers were in flight
+			}
+			upstreamSpan.SetAttributes(attribute.Bool("gateway.stream", true))
+			resp.Body = newStreamBody(resp.Body, metrics, matchedConfig.streamIdleTimeout(), cancelUpstream)
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		status, grpcCode := http.StatusBadGateway, grpcStatusUnavailable
+		if errors.Is(err, errResponseTimeout) || errors.Is(context.Cause(upstreamCtx), errResponseTimeout) {
+			status, grpcCode = http.StatusGatewayTimeout, grpcStatusDeadlineExceeded
+			metrics.upstreamTimeouts.Add(1)
+			upstreamTimedOut = true
+		}
+		log.Printf("ERROR: Upstream %s failed for request %s: %v", targetEndpoint.URL, requestID, err)
+		upstreamSpan.RecordError(err)
+		upstreamSpan.SetStatus(codes.Error, err.Error())
+		if isGRPCRequest(req) {
+			writeGRPCError(rw, grpcCode, http.StatusText(status))
+			return
+		}
+		g.writeError(rw, req, matchedConfig, status, "")
+	}
+
+	proxy.ServeHTTP(w, r.WithContext(upstreamCtx))
+	if coalesced != nil {
+		if r.Context().Err() != nil {
+			coalesced.abandon() // the leader's client left; its response may be cut short
+		}
+		noteCoalesced(coalesced.ResponseWriter, coalesced.complete())
+	}
+}
+
+// upstreamPath strips prefix from requestPath and appends the rest to the
+// endpoint's base path, so the backend sees the path it expects.
+func upstreamPath(base *url.URL, prefix, requestPath string) string {
+	remainingPath := strings.TrimPrefix(requestPath, prefix)
+	targetPath := base.Path
+	if !strings.HasSuffix(targetPath, "/") && !strings.HasPrefix(remainingPath, "/") {
+		targetPath += "/"
+	}
+	return targetPath + remainingPath
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// pickEndpoint wraps the balancer choice in its own span.
+func pickEndpoint(ctx context.Context, b *BackendConfig) *BackendEndpoint {
+	_, span := tracing.Tracer().Start(ctx, "gateway.balancer_pick")
+	defer span.End()
+
+	endpoint := b.GetNextHealthyEndpoint()
+	if endpoint == nil {
+		span.SetStatus(codes.Error, "no healthy endpoints")
+		return nil
+	}
+	span.SetAttributes(
+		attribute.Int("gateway.endpoint_id", int(endpoint.ID)),
+		attribute.String("gateway.endpoint_url", endpoint.URL),
+	)
+	return endpoint
 }
 
 // StartHealthChecks runs the periodic health check in a goroutine.
@@ -269,36 +477,60 @@ func (g *Gateway) checkBackend(b *BackendConfig) {
 			statusText string = "DOWN"
 			isHealthy  bool   = false
 			logMessage string
+			statusCode int
+			peerChain  []*x509.Certificate // certificates an https endpoint presented
 		)
 
 		start := time.Now()
-		resp, err := client.Get(targetURL)
-		latency := time.Since(start)
-
-		if err != nil {
-			logMessage = fmt.Sprintf("Network Error: %v", err)
+		var probeLatency time.Duration // set by probes that time only part of their work
+		if b.IsL4() {
+			isHealthy, logMessage, probeLatency = probeL4(b.Protocol, endpoint, client.Timeout)
+		} else if b.Protocol == ProtocolGRPC {
+			// gRPC services answer grpc.health.v1 instead of plain GETs.
+			if tlsConfig, err := b.upstreamTLSConfig(); err != nil {
+				logMessage = fmt.Sprintf("TLS Config Error: %v", err)
+			} else {
+				isHealthy, statusCode, logMessage, peerChain = checkGRPCHealth(endpoint, tlsConfig, client.Timeout)
+			}
 		} else {
-			defer resp.Body.Close()
-			statusCode := resp.StatusCode
-
-			if statusCode == http.StatusOK || statusCode == http.StatusUnauthorized {
-				if latency <= maxLatency {
-					isHealthy = true
-					statusText = "UP"
+			probe := client
+			if transport := b.upstreamTransport(endpoint); transport != nil {
+				probe.Transport = transport
+			}
+			resp, err := probe.Get(targetURL)
+			peerChain = peerCertificates(resp, err)
+			if err != nil {
+				logMessage = fmt.Sprintf("Network Error: %v", err)
+			} else {
+				resp.Body.Close()
+				statusCode = resp.StatusCode
+				isHealthy = statusCode == http.StatusOK || statusCode == http.StatusUnauthorized
+				if isHealthy {
 					logMessage = "HTTP OK/Unauthorized"
 				} else {
-					logMessage = fmt.Sprintf("High latency: %s (> %s)", latency, maxLatency)
+					logMessage = fmt.Sprintf("HTTP Status Code: %d", statusCode)
 				}
-			} else {
-				logMessage = fmt.Sprintf("HTTP Status Code: %d", statusCode)
 			}
 		}
+		latency := time.Since(start)
+		if probeLatency > 0 {
+			latency = probeLatency
+		}
 
-		// 1. Update the health status on the specific endpoint instance (IN MEMORY)
-		endpoint.IsHealthy = isHealthy
+		if isHealthy && latency > maxLatency {
+			isHealthy = false
+			logMessage = fmt.Sprintf("High latency: %s (> %s)", latency, maxLatency)
+		}
+		if isHealthy {
+			statusText = "UP"
+		}
 
-		// 2. 🛑 CRITICAL FIX: Call the service to persist the status and record history.
-		g.BackendService.SetHealthStatus(b.ID, endpoint.URL, isHealthy, latency)
+		// Persist the status, record history and detect UP/DOWN flips. The service
+		// updates the in-memory endpoint itself; doing it here first would hide flips.
+		g.BackendService.SetHealthStatus(b.ID, endpoint.URL, isHealthy, latency, statusCode, logMessage)
+		if info := newEndpointTLSInfo(peerChain, b.upstreamServerName(endpoint)); info != nil {
+			g.BackendService.SetEndpointTLS(b.ID, endpoint.URL, info)
+		}
 
 		log.Printf("  -> Health Check: [%s - Endpoint %d] (%s) is %s. Latency: %s. Reason: %s",
 			b.ID, endpoint.ID, targetURL, statusText, latency, logMessage)
@@ -327,39 +559,49 @@ func AccessLoggingHandler(g *Gateway) gin.HandlerFunc {
 		// 4. Determine BackendID by re-running the lookup logic
 		// The lookup logic must match the one used in g.ServeHTTP.
 		var backendID string
-		longestMatchLen := 0
-
-		g.mu.RLock() // Lock the map for reading
-		for _, config := range g.backends {
-			if strings.HasPrefix(r.URL.Path, config.PathPrefix) {
-				if len(config.PathPrefix) > longestMatchLen {
-					longestMatchLen = len(config.PathPrefix)
-					backendID = config.ID // Found the matching ID
-				}
-			}
+		if matched := g.matchRoute(r.URL.Path); matched != nil {
+			backendID = matched.ID
 		}
-		g.mu.RUnlock()
 
 		if backendID == "" {
 			backendID = "NO_MATCH"
 		}
 
 		// 5. Record the log in the service layer
-		err := g.BackendService.RecordAccessLog(
-			backendID,
-			latency,
-			r.Method,
-			r.URL.Path,
-			r.RemoteAddr,
-			finalStatus,
-		)
+		clientAddr := g.realClientIP(r)
+		err := g.BackendService.RecordAccessLog(&AccessLog{
+			BackendID:  backendID,
+			Latency:    latency.Nanoseconds(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			ClientIP:   clientAddr,
+			StatusCode: finalStatus,
+			RequestID:  r.Header.Get(RequestIDHeader),
+			GRPCStatus: GRPCStatus(recorder.Header()),
+			Reason:     recorder.Reason,
+			Coalesced:  recorder.Coalesced,
+		})
 
 		if err != nil {
 			log.Printf("ERROR: Failed to record access log for %s: %v", r.URL.Path, err)
 		}
+		if backendID != "NO_MATCH" {
+			g.Alerts.ObserveRequest(backendID, finalStatus)
+		}
+		if g.AccessLogSampleRate > 0 && rand.Float64() < g.AccessLogSampleRate {
+			g.Events.Publish(eventbus.TypeAccessLog, eventbus.AccessLogData{
+				RequestID:  r.Header.Get(RequestIDHeader),
+				BackendID:  backendID,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				ClientIP:   clientAddr,
+				StatusCode: finalStatus,
+				LatencyMs:  latency.Milliseconds(),
+			})
+		}
 
-		log.Printf("  -> ACCESS LOGGED: [%s] %s %s from %s. Status: %d. Latency: %s",
-			backendID, r.Method, r.URL.Path, r.RemoteAddr, finalStatus, latency.String())
+		log.Printf("  -> ACCESS LOGGED: [%s] %s %s from %s. Status: %d. Latency: %s. Request ID: %s",
+			backendID, r.Method, r.URL.Path, clientAddr, finalStatus, latency.String(), r.Header.Get(RequestIDHeader))
 	}
 }
 
@@ -380,20 +622,35 @@ func (b *BackendConfig) GetNextHealthyEndpoint() *BackendEndpoint {
 	}
 
 	// Iterate up to N times (where N is numEndpoints) to find the next HEALTHY endpoint
+	now := time.Now()
+	fallback := -1
 	for i := 0; i < numEndpoints; i++ {
 		// Calculate the index for the current attempt
 		// b.currentLBIndex is the non-persisted int field in BackendConfig
 		index := (b.currentLBIndex + i) % numEndpoints
 		endpoint := b.Endpoints[index]
 
-		// Check health status and ensure the URL was parsed (URLParsed != nil)
-		if endpoint.IsHealthy && endpoint.URLParsed != nil {
-			// Update the index for the next request
-			b.currentLBIndex = (index + 1) % numEndpoints
-			return endpoint
+		// Check health status, admin state and ensure the URL was parsed (URLParsed != nil)
+		if !endpoint.IsHealthy || endpoint.URLParsed == nil || endpoint.adminState() != EndpointActive {
+			continue
 		}
+		if fallback < 0 {
+			fallback = index
+		}
+		// Endpoints in slow start take their turn only with probability = weight
+		if weight := b.slowStartWeight(endpoint, now); weight < 1 && rand.Float64() >= weight {
+			continue
+		}
+		// Update the index for the next request
+		b.currentLBIndex = (index + 1) % numEndpoints
+		return endpoint
 	}
 
+	// Every eligible endpoint is warming up and skipped its turn; use the first one
+	if fallback >= 0 {
+		b.currentLBIndex = (fallback + 1) % numEndpoints
+		return b.Endpoints[fallback]
+	}
 	// Fallback: No healthy endpoint found after one full cycle
 	return nil
 }
@@ -0,0 +1,128 @@
+// gateway.cors.go
+package gatewayio
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"imanager.io/utils"
+)
+
+// defaultCORSMethods are allowed when a CORSPolicy lists no methods.
+var defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORSPolicy makes the gateway answer CORS for a route instead of the upstream:
+// preflights are answered directly and upstream Access-Control-* headers are replaced.
+type CORSPolicy struct {
+	AllowOrigins     []string `json:"allowOrigins" binding:"required,min=1"` // exact origins, "*" or "https://*.example.com"
+	AllowMethods     []string `json:"allowMethods,omitempty"`                // empty allows defaultCORSMethods
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`                // empty echoes Access-Control-Request-Headers
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	MaxAgeSeconds    int      `json:"maxAgeSeconds,omitempty" binding:"omitempty,gte=0"`
+}
+
+// validate rejects credentials with a "*" origin, which would let any site make
+// credentialed reads through the route.
+func (p *CORSPolicy) validate() error {
+	if p.AllowCredentials && slices.Contains(p.AllowOrigins, "*") {
+		return fmt.Errorf("allowCredentials cannot be combined with \"*\" in allowOrigins")
+	}
+	return nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or ""
+// when it is not allowed. Credentialed responses must name the origin.
+func (p *CORSPolicy) allowOrigin(origin string) string {
+	if !utils.MatchOrigin(p.AllowOrigins, origin) {
+		return ""
+	}
+	if !p.AllowCredentials && slices.Contains(p.AllowOrigins, "*") {
+		return "*"
+	}
+	return origin
+}
+
+func (p *CORSPolicy) methods() []string {
+	if len(p.AllowMethods) == 0 {
+		return defaultCORSMethods
+	}
+	return p.AllowMethods
+}
+
+func (p *CORSPolicy) allowsMethod(method string) bool {
+	return slices.ContainsFunc(p.methods(), func(m string) bool { return strings.EqualFold(m, method) })
+}
+
+// isPreflight reports whether r is a CORS preflight rather than a plain OPTIONS call.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// applyCORS adds the route's CORS headers to w. It returns true when r was a
+// preflight and has been answered; other requests continue to the upstream.
+func (b *BackendConfig) applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	p := b.CORS
+	if p == nil {
+		return false
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	allowed := p.allowOrigin(origin)
+
+	if !isPreflight(r) {
+		if allowed == "" {
+			return false // no CORS headers: the browser withholds the response
+		}
+		h.Set("Access-Control-Allow-Origin", allowed)
+		if p.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if len(p.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if allowed == "" || !p.allowsMethod(method) {
+		noteReason(w, "cors preflight rejected for "+method+" from "+origin)
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	h.Set("Access-Control-Allow-Origin", allowed)
+	h.Set("Access-Control-Allow-Methods", strings.Join(p.methods(), ", "))
+	if len(p.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.MaxAgeSeconds > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAgeSeconds))
+	}
+	noteReason(w, "cors preflight")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// dropUpstreamCORS removes Access-Control-* headers so the route policy's values stand alone.
+func dropUpstreamCORS(h http.Header) {
+	for key := range h {
+		if strings.HasPrefix(key, "Access-Control-") {
+			delete(h, key)
+		}
+	}
+}
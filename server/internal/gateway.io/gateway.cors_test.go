@@ -0,0 +1,82 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CORSPolicy
+		origin string
+		want   string
+	}{
+		{"exact", CORSPolicy{AllowOrigins: []string{"https://app.example.com"}}, "https://app.example.com", "https://app.example.com"},
+		{"not listed", CORSPolicy{AllowOrigins: []string{"https://app.example.com"}}, "https://evil.com", ""},
+		{"star", CORSPolicy{AllowOrigins: []string{"*"}}, "https://any.com", "*"},
+		{"subdomain wildcard", CORSPolicy{AllowOrigins: []string{"https://*.example.com"}}, "https://a.example.com", "https://a.example.com"},
+		{"wildcard needs a subdomain", CORSPolicy{AllowOrigins: []string{"https://*.example.com"}}, "https://example.com", ""},
+		{"wildcard keeps the scheme", CORSPolicy{AllowOrigins: []string{"https://*.example.com"}}, "http://a.example.com", ""},
+		{"credentials name the origin", CORSPolicy{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, "https://app.example.com", "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %q, want %q", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	if err := (&CORSPolicy{AllowOrigins: []string{"*"}, AllowCredentials: true}).validate(); err == nil {
+		t.Error("credentials with a wildcard origin were accepted")
+	}
+	if err := (&CORSPolicy{AllowOrigins: []string{"*"}}).validate(); err != nil {
+		t.Errorf("wildcard without credentials: %v", err)
+	}
+	if err := (&CORSPolicy{AllowOrigins: []string{"https://a.example.com"}, AllowCredentials: true}).validate(); err != nil {
+		t.Errorf("credentials with a listed origin: %v", err)
+	}
+}
+
+func TestApplyCORSPreflight(t *testing.T) {
+	cfg := &BackendConfig{CORS: &CORSPolicy{
+		AllowOrigins:  []string{"https://app.example.com"},
+		AllowMethods:  []string{"GET", "PUT"},
+		MaxAgeSeconds: 600,
+	}}
+	tests := []struct {
+		name, origin, method string
+		wantStatus           int
+		wantOrigin           string
+	}{
+		{"allowed", "https://app.example.com", "PUT", http.StatusNoContent, "https://app.example.com"},
+		{"method not allowed", "https://app.example.com", "DELETE", http.StatusForbidden, ""},
+		{"origin not allowed", "https://evil.com", "PUT", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, "/", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			w := httptest.NewRecorder()
+			if !cfg.applyCORS(w, r) {
+				t.Fatal("preflight was passed to the upstream")
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	if cfg.applyCORS(httptest.NewRecorder(), r) {
+		t.Error("plain OPTIONS was answered as a preflight")
+	}
+}
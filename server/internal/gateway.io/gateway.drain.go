@@ -0,0 +1,270 @@
+// gateway.drain.go
+package gatewayio
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Admin states of an endpoint. Only active endpoints get new traffic; a
+// draining endpoint finishes what it has, a disabled one has its WebSockets
+// closed right away.
+const (
+	EndpointActive   = "active"
+	EndpointDraining = "draining"
+	EndpointDisabled = "disabled"
+)
+
+const (
+	defaultDrainTimeout = 5 * time.Minute
+	drainPollInterval   = 500 * time.Millisecond
+	slowStartMinWeight  = 0.1 // share of its normal traffic an endpoint gets when its ramp starts
+)
+
+// EndpointStateDTO changes the admin state of one endpoint.
+type EndpointStateDTO struct {
+	State               string `json:"state" binding:"required,oneof=active draining disabled"`
+	DrainTimeoutSeconds int    `json:"drainTimeoutSeconds" binding:"omitempty,gte=0"` // 0 uses 300; WebSockets still open then are closed
+}
+
+func (d *EndpointStateDTO) drainTimeout() time.Duration {
+	if d.DrainTimeoutSeconds > 0 {
+		return time.Duration(d.DrainTimeoutSeconds) * time.Second
+	}
+	return defaultDrainTimeout
+}
+
+// EndpointDrainStatus reports what an endpoint still has in flight.
+type EndpointDrainStatus struct {
+	BackendID      string     `json:"backendId"`
+	EndpointID     uint       `json:"endpointId"`
+	URL            string     `json:"url"`
+	AdminState     string     `json:"adminState"`
+	InFlight       int64      `json:"inFlight"` // proxied requests and TCP connections
+	WebSockets     int        `json:"webSockets"`
+	Drained        bool       `json:"drained"` // takes no new traffic and has nothing left in flight
+	DrainStartedAt *time.Time `json:"drainStartedAt,omitempty"`
+	Deadline       *time.Time `json:"deadline,omitempty"`
+	ForcedAt       *time.Time `json:"forcedAt,omitempty"` // when WebSockets left at the deadline were closed
+}
+
+// adminState treats rows written before admin states existed as active.
+func (e *BackendEndpoint) adminState() string {
+	if e.AdminState == "" {
+		return EndpointActive
+	}
+	return e.AdminState
+}
+
+// slowStartWeight is the share of its normal traffic e gets at now, from
+// slowStartMinWeight right after it came UP to 1 after SlowStartSeconds.
+// The caller holds b.mu.
+func (b *BackendConfig) slowStartWeight(e *BackendEndpoint, now time.Time) float64 {
+	if b.SlowStartSeconds <= 0 || e.warmSince.IsZero() {
+		return 1
+	}
+	window := time.Duration(b.SlowStartSeconds) * time.Second
+	elapsed := now.Sub(e.warmSince)
+	if elapsed >= window {
+		return 1
+	}
+	return max(float64(elapsed)/float64(window), slowStartMinWeight)
+}
+
+// startSlowStart begins the traffic ramp of an endpoint that just became eligible.
+func (b *BackendConfig) startSlowStart(e *BackendEndpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.warmSince = time.Now()
+}
+
+// setAdminState changes the admin state of e and reports the previous one.
+func (b *BackendConfig) setAdminState(e *BackendEndpoint, state string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous := e.adminState()
+	e.AdminState = state
+	if state == EndpointActive && previous != EndpointActive {
+		e.warmSince = time.Now()
+	}
+	return previous
+}
+
+// endpointLoads counts the proxied requests and TCP connections each endpoint
+// has in flight, keyed by endpoint ID; the zero value is ready to use.
+type endpointLoads struct {
+	mu     sync.Mutex
+	counts map[uint]*atomic.Int64
+}
+
+func (reg *endpointLoads) counter(endpointID uint) *atomic.Int64 {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.counts == nil {
+		reg.counts = make(map[uint]*atomic.Int64)
+	}
+	c, ok := reg.counts[endpointID]
+	if !ok {
+		c = &atomic.Int64{}
+		reg.counts[endpointID] = c
+	}
+	return c
+}
+
+// begin counts one unit of work on the endpoint until the returned func is called.
+func (reg *endpointLoads) begin(endpointID uint) func() {
+	c := reg.counter(endpointID)
+	c.Add(1)
+	return func() { c.Add(-1) }
+}
+
+func (reg *endpointLoads) get(endpointID uint) int64 {
+	return reg.counter(endpointID).Load()
+}
+
+// drainState is a running drain of one endpoint.
+type drainState struct {
+	startedAt time.Time
+	deadline  time.Time
+	forcedAt  time.Time
+	stop      chan struct{}
+}
+
+// drains tracks running drains by endpoint ID; the zero value is ready to use.
+type drains struct {
+	mu         sync.Mutex
+	byEndpoint map[uint]*drainState
+}
+
+// start replaces any drain of endpointID with a new one.
+func (reg *drains) start(endpointID uint, timeout time.Duration) *drainState {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.byEndpoint == nil {
+		reg.byEndpoint = make(map[uint]*drainState)
+	}
+	if previous, ok := reg.byEndpoint[endpointID]; ok {
+		close(previous.stop)
+	}
+	now := time.Now()
+	state := &drainState{startedAt: now, deadline: now.Add(timeout), stop: make(chan struct{})}
+	reg.byEndpoint[endpointID] = state
+	return state
+}
+
+// cancel stops the drain of endpointID, if any.
+func (reg *drains) cancel(endpointID uint) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if state, ok := reg.byEndpoint[endpointID]; ok {
+		close(state.stop)
+		delete(reg.byEndpoint, endpointID)
+	}
+}
+
+// finish forgets state once its endpoint has drained, unless a newer drain
+// has replaced it.
+func (reg *drains) finish(endpointID uint, state *drainState) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.byEndpoint[endpointID] == state {
+		delete(reg.byEndpoint, endpointID)
+	}
+}
+
+// get returns a copy of the drain of endpointID, if any.
+func (reg *drains) get(endpointID uint) (drainState, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	state, ok := reg.byEndpoint[endpointID]
+	if !ok {
+		return drainState{}, false
+	}
+	return *state, true
+}
+
+func (reg *drains) markForced(state *drainState, at time.Time) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	state.forcedAt = at
+}
+
+// endpointWebSockets returns the live WebSocket sessions proxied to an endpoint.
+func (g *Gateway) endpointWebSockets(backendID string, endpointID uint) []*wsSession {
+	var sessions []*wsSession
+	for _, s := range g.wsConns.find(backendID) {
+		if s.info.EndpointID == endpointID {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// ApplyEndpointState starts or stops the drain that goes with the admin state
+// an endpoint was just given. Disabling closes its WebSockets at once.
+func (g *Gateway) ApplyEndpointState(backendID string, endpoint *BackendEndpoint, state string, timeout time.Duration) {
+	switch state {
+	case EndpointActive:
+		g.drains.cancel(endpoint.ID)
+	case EndpointDraining:
+		go g.watchDrain(backendID, endpoint, g.drains.start(endpoint.ID, timeout))
+	case EndpointDisabled:
+		go g.watchDrain(backendID, endpoint, g.drains.start(endpoint.ID, 0))
+	}
+}
+
+// watchDrain waits for an endpoint to go idle. WebSockets still open at the
+// deadline are closed with 1001; requests in flight run to their own timeouts.
+func (g *Gateway) watchDrain(backendID string, endpoint *BackendEndpoint, state *drainState) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		webSockets := g.endpointWebSockets(backendID, endpoint.ID)
+		inFlight := g.endpointLoads.get(endpoint.ID)
+		if inFlight == 0 && len(webSockets) == 0 {
+			log.Printf("INFO: Endpoint %s (Config %s) drained in %s", endpoint.URL, backendID, time.Since(state.startedAt).Round(time.Millisecond))
+			g.drains.finish(endpoint.ID, state)
+			return
+		}
+		if now := time.Now(); state.forcedAt.IsZero() && !now.Before(state.deadline) {
+			for _, s := range webSockets {
+				s.finish(websocket.CloseGoingAway, "endpoint draining", "endpoint drained by admin")
+			}
+			g.drains.markForced(state, now)
+			if len(webSockets) > 0 {
+				log.Printf("WARN: Drain deadline of endpoint %s (Config %s) passed; closed %d WebSockets, %d requests still in flight", endpoint.URL, backendID, len(webSockets), inFlight)
+			}
+		}
+		select {
+		case <-state.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EndpointDrainStatus reports what an endpoint still has in flight and whether
+// it is drained.
+func (g *Gateway) EndpointDrainStatus(backendID string, endpoint *BackendEndpoint, adminState string) *EndpointDrainStatus {
+	status := &EndpointDrainStatus{
+		BackendID:  backendID,
+		EndpointID: endpoint.ID,
+		URL:        endpoint.URL,
+		AdminState: adminState,
+		InFlight:   g.endpointLoads.get(endpoint.ID),
+		WebSockets: len(g.endpointWebSockets(backendID, endpoint.ID)),
+	}
+	status.Drained = adminState != EndpointActive && status.InFlight == 0 && status.WebSockets == 0
+	if state, ok := g.drains.get(endpoint.ID); ok && adminState != EndpointActive {
+		status.DrainStartedAt = &state.startedAt
+		status.Deadline = &state.deadline
+		if !state.forcedAt.IsZero() {
+			status.ForcedAt = &state.forcedAt
+		}
+	}
+	return status
+}
@@ -0,0 +1,131 @@
+package gatewayio
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func testEndpoints(n int) []*BackendEndpoint {
+	u, _ := url.Parse("http://upstream")
+	endpoints := make([]*BackendEndpoint, n)
+	for i := range endpoints {
+		endpoints[i] = &BackendEndpoint{ID: uint(i + 1), IsHealthy: true, URLParsed: u}
+	}
+	return endpoints
+}
+
+func TestBalancerSkipsInactiveEndpoints(t *testing.T) {
+	endpoints := testEndpoints(3)
+	cfg := &BackendConfig{Endpoints: endpoints}
+	cfg.setAdminState(endpoints[0], EndpointDraining)
+	cfg.setAdminState(endpoints[2], EndpointDisabled)
+	for range 6 {
+		if got := cfg.GetNextHealthyEndpoint(); got != endpoints[1] {
+			t.Fatalf("picked endpoint %d, want 2", got.ID)
+		}
+	}
+	if !cfg.HasHealthyEndpoint() {
+		t.Fatal("route with an active endpoint reported unhealthy")
+	}
+
+	cfg.setAdminState(endpoints[1], EndpointDraining)
+	if got := cfg.GetNextHealthyEndpoint(); got != nil {
+		t.Fatalf("picked endpoint %d with every endpoint out of rotation", got.ID)
+	}
+	if cfg.HasHealthyEndpoint() {
+		t.Fatal("route without active endpoints reported healthy")
+	}
+}
+
+func TestSlowStartWeight(t *testing.T) {
+	now := time.Now()
+	cfg := &BackendConfig{SlowStartSeconds: 10}
+	tests := []struct {
+		warmSince time.Time
+		want      float64
+	}{
+		{time.Time{}, 1},
+		{now, slowStartMinWeight},
+		{now.Add(-5 * time.Second), 0.5},
+		{now.Add(-10 * time.Second), 1},
+	}
+	for _, tt := range tests {
+		ep := &BackendEndpoint{warmSince: tt.warmSince}
+		if got := cfg.slowStartWeight(ep, now); got != tt.want {
+			t.Errorf("weight %v into the ramp = %v, want %v", now.Sub(tt.warmSince), got, tt.want)
+		}
+	}
+	if got := (&BackendConfig{}).slowStartWeight(&BackendEndpoint{warmSince: now}, now); got != 1 {
+		t.Errorf("weight without slow start = %v, want 1", got)
+	}
+}
+
+func TestSlowStartRamp(t *testing.T) {
+	endpoints := testEndpoints(2)
+	cfg := &BackendConfig{Endpoints: endpoints, SlowStartSeconds: 60}
+	cfg.startSlowStart(endpoints[1])
+
+	picks := map[uint]int{}
+	for range 10000 {
+		picks[cfg.GetNextHealthyEndpoint().ID]++
+	}
+	if share := float64(picks[2]) / 10000; share < 0.05 || share > 0.15 {
+		t.Errorf("warming endpoint got %.2f of traffic, want about 0.09", share)
+	}
+
+	// Reactivating an endpoint restarts its ramp; re-setting active does not.
+	cfg.setAdminState(endpoints[0], EndpointDisabled)
+	cfg.setAdminState(endpoints[0], EndpointActive)
+	warm := endpoints[0].warmSince
+	if warm.IsZero() {
+		t.Fatal("reactivated endpoint skipped slow start")
+	}
+	cfg.setAdminState(endpoints[0], EndpointActive)
+	if endpoints[0].warmSince != warm {
+		t.Error("active endpoint restarted its ramp")
+	}
+}
+
+func TestDrainStatus(t *testing.T) {
+	g := &Gateway{}
+	ep := &BackendEndpoint{ID: 7, URL: "http://upstream"}
+	done := g.endpointLoads.begin(ep.ID)
+	g.ApplyEndpointState("b1", ep, EndpointDraining, time.Minute)
+
+	status := g.EndpointDrainStatus("b1", ep, EndpointDraining)
+	if status.Drained || status.InFlight != 1 || status.Deadline == nil || status.ForcedAt != nil {
+		t.Fatalf("status with a request in flight = %+v", status)
+	}
+	done()
+	if status := g.EndpointDrainStatus("b1", ep, EndpointDraining); !status.Drained || status.InFlight != 0 {
+		t.Fatalf("status after the request finished = %+v", status)
+	}
+	if status := g.EndpointDrainStatus("b1", ep, EndpointActive); status.Drained || status.DrainStartedAt != nil {
+		t.Fatalf("active endpoint reported as draining: %+v", status)
+	}
+	g.ApplyEndpointState("b1", ep, EndpointActive, 0)
+	if _, ok := g.drains.get(ep.ID); ok {
+		t.Error("reactivation left the drain running")
+	}
+}
+
+func TestDrainForgottenOnceDrained(t *testing.T) {
+	g := &Gateway{}
+	for _, state := range []string{EndpointDraining, EndpointDisabled} {
+		ep := &BackendEndpoint{ID: 9, URL: "http://upstream"}
+		done := g.endpointLoads.begin(ep.ID)
+		g.ApplyEndpointState("b1", ep, state, time.Minute)
+		if _, ok := g.drains.get(ep.ID); !ok {
+			t.Fatalf("%s: no drain while a request is in flight", state)
+		}
+		done()
+		waitFor(t, func() bool {
+			_, ok := g.drains.get(ep.ID)
+			return !ok
+		})
+		if status := g.EndpointDrainStatus("b1", ep, state); !status.Drained {
+			t.Errorf("%s: status after the drain finished = %+v", state, status)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// gateway.errorpage.go
+package gatewayio
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validate renders the JSON template with sample values to catch broken JSON early.
+func (dto *ErrorPageDTO) validate() error {
+	if dto.HTML == "" && dto.JSON == "" {
+		return fmt.Errorf("set html, json or both")
+	}
+	if dto.JSON != "" {
+		sample := renderErrorTemplate(dto.JSON, dto.Status, "sample message", "sample-request-id", jsonEscape)
+		if !json.Valid([]byte(sample)) {
+			return fmt.Errorf("json template does not render to valid JSON")
+		}
+	}
+	return nil
+}
+
+// errorPageKey scopes a page to a route ("" for the gateway-wide default).
+type errorPageKey struct {
+	backendID string
+	status    int
+}
+
+// errorPages holds the pages currently in force; the zero value is ready to use.
+type errorPages struct {
+	mu    sync.RWMutex
+	pages map[errorPageKey]*ErrorPage
+}
+
+// ReloadErrorPages replaces the custom error pages; called by the service after changes.
+func (g *Gateway) ReloadErrorPages(pages []*ErrorPage) {
+	m := make(map[errorPageKey]*ErrorPage, len(pages))
+	for _, p := range pages {
+		m[errorPageKey{p.BackendID, p.Status}] = p
+	}
+	g.errorPages.mu.Lock()
+	g.errorPages.pages = m
+	g.errorPages.mu.Unlock()
+}
+
+// errorPage returns the route's page for status, else the gateway-wide one.
+func (g *Gateway) errorPage(cfg *BackendConfig, status int) *ErrorPage {
+	g.errorPages.mu.RLock()
+	defer g.errorPages.mu.RUnlock()
+	if cfg != nil {
+		if p, ok := g.errorPages.pages[errorPageKey{cfg.ID, status}]; ok {
+			return p
+		}
+	}
+	return g.errorPages.pages[errorPageKey{"", status}]
+}
+
+// writeError answers with status using the custom page for it when one exists,
+// and otherwise with "<status> <text>: message" as plain text (an empty body
+// when message is empty), as before custom pages. cfg may be nil for requests
+// that matched no route.
+func (g *Gateway) writeError(w http.ResponseWriter, r *http.Request, cfg *BackendConfig, status int, message string) {
+	page := g.errorPage(cfg, status)
+	if page == nil {
+		if message == "" {
+			w.WriteHeader(status)
+			return
+		}
+		http.Error(w, fmt.Sprintf("%d %s: %s", status, http.StatusText(status), message), status)
+		return
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	requestID := r.Header.Get(RequestIDHeader)
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Add("Vary", "Accept")
+	if page.HTML != "" && (page.JSON == "" || prefersHTML(r.Header.Get("Accept"))) {
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(renderErrorTemplate(page.HTML, status, message, requestID, html.EscapeString)))
+		return
+	}
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(renderErrorTemplate(page.JSON, status, message, requestID, jsonEscape)))
+}
+
+func renderErrorTemplate(tmpl string, status int, message, requestID string, escape func(string) string) string {
+	return strings.NewReplacer(
+		"{{status}}", strconv.Itoa(status),
+		"{{statusText}}", escape(http.StatusText(status)),
+		"{{message}}", escape(message),
+		"{{requestId}}", escape(requestID),
+	).Replace(tmpl)
+}
+
+// jsonEscape escapes s for use inside a JSON string literal.
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// prefersHTML reports whether the Accept header ranks text/html above JSON.
+func prefersHTML(accept string) bool {
+	htmlQ, jsonQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		case "application/json", "application/problem+json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return htmlQ > 0 && htmlQ > jsonQ
+}
@@ -0,0 +1,106 @@
+package gatewayio
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefersHTML(t *testing.T) {
+	tests := map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"text/html":                         true,
+		"application/json":                  false,
+		"text/html,application/xhtml+xml":   true,
+		"application/json, text/html;q=0.9": false,
+		"application/json;q=0.5, text/html": true,
+		"text/html;q=0":                     false,
+		"application/xhtml+xml;q=0.8, application/problem+json;q=0.7": true,
+		"text/html;q=0.5, application/json;q=0.5":                     false,
+		"not a media type, text/html":                                 true,
+	}
+	for accept, want := range tests {
+		if got := prefersHTML(accept); got != want {
+			t.Errorf("prefersHTML(%q) = %t, want %t", accept, got, want)
+		}
+	}
+}
+
+func TestRenderErrorTemplate(t *testing.T) {
+	message := `<b>"down"</b>`
+	got := renderErrorTemplate("<h1>{{status}} {{statusText}}</h1><p>{{message}}</p><i>{{requestId}}</i>", 503, message, "r-1", html.EscapeString)
+	want := "<h1>503 Service Unavailable</h1><p>&lt;b&gt;&#34;down&#34;&lt;/b&gt;</p><i>r-1</i>"
+	if got != want {
+		t.Errorf("html = %s, want %s", got, want)
+	}
+
+	got = renderErrorTemplate(`{"code":{{status}},"error":"{{message}}","id":"{{requestId}}"}`, 404, message, `a"b`, jsonEscape)
+	var body struct {
+		Code  int    `json:"code"`
+		Error string `json:"error"`
+		ID    string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(got), &body); err != nil {
+		t.Fatalf("json template rendered invalid JSON %s: %v", got, err)
+	}
+	if body.Code != 404 || body.Error != message || body.ID != `a"b` {
+		t.Errorf("json = %+v", body)
+	}
+}
+
+func TestErrorPageDTOValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		dto  ErrorPageDTO
+		ok   bool
+	}{
+		{"html", ErrorPageDTO{Status: 502, HTML: "<p>{{message}}</p>"}, true},
+		{"json", ErrorPageDTO{Status: 502, JSON: `{"error":"{{message}}"}`}, true},
+		{"neither", ErrorPageDTO{Status: 502}, false},
+		{"unquoted placeholder", ErrorPageDTO{Status: 502, JSON: `{"error":{{message}}}`}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.dto.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	g := &Gateway{}
+	cfg := &BackendConfig{ID: "b1"}
+	serve := func(accept string, cfg *BackendConfig, status int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		r.Header.Set(RequestIDHeader, "req-7")
+		w := httptest.NewRecorder()
+		g.writeError(w, r, cfg, status, "upstream down")
+		return w
+	}
+
+	if w := serve("text/html", cfg, 502); w.Body.String() != "502 Bad Gateway: upstream down\n" {
+		t.Errorf("default body = %q", w.Body.String())
+	}
+
+	g.ReloadErrorPages([]*ErrorPage{
+		{Status: 502, HTML: "<p>global {{message}}</p>", JSON: `{"error":"{{message}}","id":"{{requestId}}"}`},
+		{BackendID: "b1", Status: 503, HTML: "<p>route {{statusText}}</p>"},
+	})
+	if w := serve("text/html", cfg, 502); w.Body.String() != "<p>global upstream down</p>" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("html page = %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+	if w := serve("application/json", nil, 502); w.Code != 502 || w.Body.String() != `{"error":"upstream down","id":"req-7"}` {
+		t.Errorf("json page for an unmatched path = %d %q", w.Code, w.Body.String())
+	}
+	// A route's own page wins, and an HTML-only page is served whatever the client accepts.
+	if w := serve("application/json", cfg, 503); w.Body.String() != "<p>route Service Unavailable</p>" {
+		t.Errorf("route page = %q", w.Body.String())
+	}
+	if w := serve("text/html", &BackendConfig{ID: "other"}, 503); w.Body.String() != "503 Service Unavailable: upstream down\n" {
+		t.Errorf("other route got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,206 @@
+// gateway.fault.go
+package gatewayio
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxFaultDuration caps how far ahead ExpiresAt may be set, so a forgotten
+// experiment switches itself off.
+const maxFaultDuration = 7 * 24 * time.Hour
+
+// statusClosedWithoutResponse is logged for reset connections (nginx's 444).
+const statusClosedWithoutResponse = 444
+
+// Latency distributions accepted in FaultDelay.Distribution.
+const (
+	DelayFixed       = "fixed"
+	DelayUniform     = "uniform"
+	DelayNormal      = "normal"
+	DelayExponential = "exponential"
+)
+
+// FaultPolicy injects failures into a route's requests for resilience testing.
+// Faults apply only until ExpiresAt and, when Header is set, only to requests
+// carrying it (with HeaderValue, if given).
+type FaultPolicy struct {
+	ExpiresAt   time.Time   `json:"expiresAt" binding:"required"`
+	Header      string      `json:"header,omitempty"`      // e.g. X-Chaos-Test; empty targets all traffic
+	HeaderValue string      `json:"headerValue,omitempty"` // empty matches any value of Header
+	Delay       *FaultDelay `json:"delay,omitempty"`
+	Abort       *FaultAbort `json:"abort,omitempty"`
+	Reset       *FaultReset `json:"reset,omitempty"`
+}
+
+// FaultDelay holds requests before they are proxied.
+type FaultDelay struct {
+	Percent      float64 `json:"percent" binding:"required,gt=0,lte=100"`
+	Distribution string  `json:"distribution" binding:"omitempty,oneof=fixed uniform normal exponential"` // empty means fixed
+	FixedMs      int     `json:"fixedMs" binding:"omitempty,gte=0"`
+	MinMs        int     `json:"minMs" binding:"omitempty,gte=0"`    // uniform
+	MaxMs        int     `json:"maxMs" binding:"omitempty,gte=0"`    // uniform
+	MeanMs       int     `json:"meanMs" binding:"omitempty,gte=0"`   // normal and exponential
+	StdDevMs     int     `json:"stdDevMs" binding:"omitempty,gte=0"` // normal
+}
+
+// FaultAbort answers requests with Status instead of proxying them.
+type FaultAbort struct {
+	Percent float64 `json:"percent" binding:"required,gt=0,lte=100"`
+	Status  int     `json:"status" binding:"required,min=400,max=599"`
+}
+
+// FaultReset drops the client connection without a response. HTTP/2 streams
+// cannot be taken over, so they get a 502 abort instead.
+type FaultReset struct {
+	Percent float64 `json:"percent" binding:"required,gt=0,lte=100"`
+}
+
+// validate checks the parts the binding tags cannot express.
+func (f *FaultPolicy) validate(now time.Time) error {
+	if !f.ExpiresAt.After(now) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+	if f.ExpiresAt.Sub(now) > maxFaultDuration {
+		return fmt.Errorf("expiresAt must be within %s", maxFaultDuration)
+	}
+	if f.Delay == nil && f.Abort == nil && f.Reset == nil {
+		return fmt.Errorf("set at least one of delay, abort or reset")
+	}
+	if f.HeaderValue != "" && f.Header == "" {
+		return fmt.Errorf("headerValue needs header")
+	}
+	if d := f.Delay; d != nil {
+		switch d.Distribution {
+		case "", DelayFixed:
+			if d.FixedMs <= 0 {
+				return fmt.Errorf("delay: fixedMs must be positive")
+			}
+		case DelayUniform:
+			if d.MaxMs <= d.MinMs {
+				return fmt.Errorf("delay: maxMs must be greater than minMs")
+			}
+		case DelayNormal, DelayExponential:
+			if d.MeanMs <= 0 {
+				return fmt.Errorf("delay: meanMs must be positive")
+			}
+		}
+	}
+	return nil
+}
+
+// applies reports whether the policy is live and r is in scope.
+func (f *FaultPolicy) applies(r *http.Request, now time.Time) bool {
+	if f == nil || now.After(f.ExpiresAt) {
+		return false
+	}
+	if f.Header == "" {
+		return true
+	}
+	values := r.Header.Values(f.Header)
+	if f.HeaderValue == "" {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if v == f.HeaderValue {
+			return true
+		}
+	}
+	return false
+}
+
+func chance(percent float64) bool {
+	return rand.Float64()*100 < percent
+}
+
+// sample draws one delay from the configured distribution.
+func (d *FaultDelay) sample() time.Duration {
+	var ms float64
+	switch d.Distribution {
+	case DelayUniform:
+		ms = float64(d.MinMs) + rand.Float64()*float64(d.MaxMs-d.MinMs)
+	case DelayNormal:
+		ms = max(rand.NormFloat64()*float64(d.StdDevMs)+float64(d.MeanMs), 0)
+	case DelayExponential:
+		ms = rand.ExpFloat64() * float64(d.MeanMs)
+	default:
+		ms = float64(d.FixedMs)
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// injectFault applies the route's fault policy. It returns true when the
+// request was answered (aborted or reset) and must not be proxied.
+func (g *Gateway) injectFault(w http.ResponseWriter, r *http.Request, cfg *BackendConfig, metrics *routeMetrics) bool {
+	f := cfg.Fault
+	if !f.applies(r, time.Now()) {
+		return false
+	}
+
+	if f.Reset != nil && chance(f.Reset.Percent) {
+		metrics.faults.Add(1)
+		if resetConnection(w, r) {
+			noteReason(w, "fault injected: connection reset")
+			return true
+		}
+		writeFaultAbort(w, r, http.StatusBadGateway, "fault injected: abort 502 (HTTP/2 streams cannot be reset)")
+		return true
+	}
+	if f.Abort != nil && chance(f.Abort.Percent) {
+		metrics.faults.Add(1)
+		writeFaultAbort(w, r, f.Abort.Status, fmt.Sprintf("fault injected: abort %d", f.Abort.Status))
+		return true
+	}
+	if f.Delay != nil && chance(f.Delay.Percent) {
+		metrics.faults.Add(1)
+		delay := f.Delay.sample()
+		noteReason(w, fmt.Sprintf("fault injected: delay %s", delay.Round(time.Millisecond)))
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return true // the client gave up while held
+		}
+	}
+	return false
+}
+
+// writeFaultAbort answers with status, as a gRPC status for gRPC calls.
+func writeFaultAbort(w http.ResponseWriter, r *http.Request, status int, reason string) {
+	noteReason(w, reason)
+	if isGRPCRequest(r) {
+		writeGRPCError(w, grpcStatusUnavailable, "fault injected")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": "fault injected: " + http.StatusText(status)})
+}
+
+// resetConnection takes over an HTTP/1 connection and closes it with a TCP RST.
+func resetConnection(w http.ResponseWriter, r *http.Request) bool {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || r.ProtoMajor != 1 { // gin's writer panics when asked to hijack HTTP/2
+		return false
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return false
+	}
+	if rec := findRecorder(w); rec != nil {
+		rec.Status = statusClosedWithoutResponse
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0) // discard unsent data and send RST instead of FIN
+	}
+	if err := conn.Close(); err != nil {
+		log.Printf("WARN: Closing connection for injected reset failed: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package gatewayio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFaultPolicyValidate(t *testing.T) {
+	now := time.Now()
+	abort := &FaultAbort{Percent: 10, Status: 503}
+	tests := []struct {
+		name   string
+		policy FaultPolicy
+		ok     bool
+	}{
+		{"abort", FaultPolicy{ExpiresAt: now.Add(time.Hour), Abort: abort}, true},
+		{"expired", FaultPolicy{ExpiresAt: now.Add(-time.Second), Abort: abort}, false},
+		{"too far ahead", FaultPolicy{ExpiresAt: now.Add(maxFaultDuration + time.Hour), Abort: abort}, false},
+		{"no fault", FaultPolicy{ExpiresAt: now.Add(time.Hour)}, false},
+		{"header value without header", FaultPolicy{ExpiresAt: now.Add(time.Hour), HeaderValue: "1", Abort: abort}, false},
+		{"fixed delay without duration", FaultPolicy{ExpiresAt: now.Add(time.Hour), Delay: &FaultDelay{Percent: 5}}, false},
+		{"uniform delay", FaultPolicy{ExpiresAt: now.Add(time.Hour), Delay: &FaultDelay{Percent: 5, Distribution: DelayUniform, MinMs: 10, MaxMs: 20}}, true},
+		{"uniform delay with empty range", FaultPolicy{ExpiresAt: now.Add(time.Hour), Delay: &FaultDelay{Percent: 5, Distribution: DelayUniform, MinMs: 20, MaxMs: 20}}, false},
+		{"normal delay without mean", FaultPolicy{ExpiresAt: now.Add(time.Hour), Delay: &FaultDelay{Percent: 5, Distribution: DelayNormal}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(now); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestFaultApplies(t *testing.T) {
+	now := time.Now()
+	live := now.Add(time.Minute)
+	tests := []struct {
+		name   string
+		policy *FaultPolicy
+		header map[string]string
+		want   bool
+	}{
+		{"no policy", nil, nil, false},
+		{"all traffic", &FaultPolicy{ExpiresAt: live}, nil, true},
+		{"expired", &FaultPolicy{ExpiresAt: now.Add(-time.Millisecond)}, nil, false},
+		{"header missing", &FaultPolicy{ExpiresAt: live, Header: "X-Chaos-Test"}, nil, false},
+		{"header present", &FaultPolicy{ExpiresAt: live, Header: "X-Chaos-Test"}, map[string]string{"X-Chaos-Test": "anything"}, true},
+		{"header value matches", &FaultPolicy{ExpiresAt: live, Header: "X-Chaos-Test", HeaderValue: "on"}, map[string]string{"x-chaos-test": "on"}, true},
+		{"header value differs", &FaultPolicy{ExpiresAt: live, Header: "X-Chaos-Test", HeaderValue: "on"}, map[string]string{"X-Chaos-Test": "off"}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if got := tt.policy.applies(r, now); got != tt.want {
+			t.Errorf("%s: applies = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFaultDelaySample(t *testing.T) {
+	if got := (&FaultDelay{FixedMs: 25}).sample(); got != 25*time.Millisecond {
+		t.Errorf("fixed sample = %v", got)
+	}
+	uniform := &FaultDelay{Distribution: DelayUniform, MinMs: 10, MaxMs: 20}
+	normal := &FaultDelay{Distribution: DelayNormal, MeanMs: 5, StdDevMs: 50}
+	for range 1000 {
+		if d := uniform.sample(); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("uniform sample %v outside [10ms, 20ms]", d)
+		}
+		if d := normal.sample(); d < 0 {
+			t.Fatalf("normal sample %v is negative", d)
+		}
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	g := &Gateway{}
+	live := time.Now().Add(time.Minute)
+	run := func(policy *FaultPolicy, r *http.Request) (*StatusRecorder, *httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		rec := &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+		answered := g.injectFault(rec, r, &BackendConfig{Fault: policy}, &routeMetrics{})
+		return rec, w, answered
+	}
+
+	rec, w, answered := run(&FaultPolicy{ExpiresAt: live, Abort: &FaultAbort{Percent: 100, Status: 503}}, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !answered || w.Code != http.StatusServiceUnavailable || rec.Reason != "fault injected: abort 503" {
+		t.Errorf("abort: answered %t, status %d, reason %q", answered, w.Code, rec.Reason)
+	}
+
+	if _, _, answered := run(&FaultPolicy{ExpiresAt: time.Now().Add(-time.Second), Abort: &FaultAbort{Percent: 100, Status: 503}}, httptest.NewRequest(http.MethodGet, "/", nil)); answered {
+		t.Error("expired policy still aborted")
+	}
+
+	// A writer that cannot be hijacked turns a reset into a 502 abort.
+	if _, w, answered := run(&FaultPolicy{ExpiresAt: live, Reset: &FaultReset{Percent: 100}}, httptest.NewRequest(http.MethodGet, "/", nil)); !answered || w.Code != http.StatusBadGateway {
+		t.Errorf("reset fallback: answered %t, status %d", answered, w.Code)
+	}
+
+	delay := &FaultPolicy{ExpiresAt: live, Delay: &FaultDelay{Percent: 100, FixedMs: 10}}
+	start := time.Now()
+	if _, _, answered := run(delay, httptest.NewRequest(http.MethodGet, "/", nil)); answered || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("delay: answered %t after %v", answered, time.Since(start))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	delay.Delay.FixedMs = 10_000
+	if _, _, answered := run(delay, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)); !answered {
+		t.Error("delayed request of a departed client was proxied")
+	}
+}
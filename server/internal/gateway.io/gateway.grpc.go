@@ -0,0 +1,133 @@
+// gateway.grpc.go
+package gatewayio
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// gRPC status codes the gateway produces itself.
+const (
+	grpcStatusDeadlineExceeded = 4
+	grpcStatusPermissionDenied = 7
+	grpcStatusUnavailable      = 14
+)
+
+// h2cTransport speaks HTTP/2 with prior knowledge over cleartext connections.
+var h2cTransport = newHTTP2Transport(false)
+
+// h2Transport speaks HTTP/2 over TLS only, as gRPC requires.
+var h2Transport = newHTTP2Transport(true)
+
+func newHTTP2Transport(tlsOnly bool) *http.Transport {
+	var protocols http.Protocols
+	if tlsOnly {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = &protocols
+	return transport
+}
+
+// usesHTTP2 reports whether the route's upstreams must be reached over HTTP/2.
+func (b *BackendConfig) usesHTTP2() bool {
+	return b.Protocol == ProtocolGRPC || b.Protocol == ProtocolH2C
+}
+
+// upstreamTransport picks the round tripper for endpoint; nil keeps the proxy default.
+func (b *BackendConfig) upstreamTransport(endpoint *BackendEndpoint) http.RoundTripper {
+	secure := endpoint.URLParsed.Scheme == "https"
+	if secure && b.UpstreamTLS != nil {
+		s := b.loadUpstreamTLS()
+		if b.usesHTTP2() {
+			return s.h2
+		}
+		return s.transport
+	}
+	if !b.usesHTTP2() {
+		return nil
+	}
+	if secure {
+		return h2Transport
+	}
+	return h2cTransport
+}
+
+// isGRPCRequest reports whether r carries a gRPC call.
+func isGRPCRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// writeGRPCError answers a gRPC call with a trailers-only response so clients
+// see a proper status instead of a bare HTTP error.
+func writeGRPCError(w http.ResponseWriter, code int, message string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	h.Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// GRPCStatus extracts grpc-status from response headers or trailers; nil when absent.
+func GRPCStatus(h http.Header) *int {
+	for _, key := range []string{"Grpc-Status", http.TrailerPrefix + "Grpc-Status"} {
+		if v := h.Get(key); v != "" {
+			if code, err := strconv.Atoi(v); err == nil {
+				return &code
+			}
+		}
+	}
+	return nil
+}
+
+// checkGRPCHealth calls grpc.health.v1.Health/Check for the whole server.
+// statusCode is 200 when SERVING and 503 otherwise, so history stays comparable with HTTP probes.
+// tlsConfig carries the route's upstream TLS settings and may be nil; chain is
+// the certificate chain a TLS endpoint presented.
+func checkGRPCHealth(endpoint *BackendEndpoint, tlsConfig *tls.Config, timeout time.Duration) (healthy bool, statusCode int, reason string, chain []*x509.Certificate) {
+	creds := insecure.NewCredentials()
+	if endpoint.URLParsed.Scheme == "https" {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig = tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = endpoint.URLParsed.Hostname()
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(endpoint.URLParsed.Host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return false, 0, fmt.Sprintf("gRPC Dial Error: %v", err), nil
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var p peer.Peer
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		chain = info.State.PeerCertificates
+	}
+	if err != nil {
+		return false, 0, fmt.Sprintf("gRPC Health Error: %v", err), chain
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return false, http.StatusServiceUnavailable, "gRPC Health: " + resp.GetStatus().String(), chain
+	}
+	return true, http.StatusOK, "gRPC Health: SERVING", chain
+}
@@ -0,0 +1,101 @@
+package gatewayio
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int // -1 for none
+	}{
+		{"header", http.Header{"Grpc-Status": {"0"}}, 0},
+		{"trailer", http.Header{http.TrailerPrefix + "Grpc-Status": {"14"}}, 14},
+		{"absent", http.Header{}, -1},
+		{"garbage", http.Header{"Grpc-Status": {"ok"}}, -1},
+	}
+	for _, tt := range tests {
+		got := GRPCStatus(tt.header)
+		if (got == nil) != (tt.want < 0) || (got != nil && *got != tt.want) {
+			t.Errorf("%s: GRPCStatus = %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteGRPCError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+	r.Header.Set("Content-Type", "application/grpc+proto")
+	if !isGRPCRequest(r) {
+		t.Error("gRPC request not recognised")
+	}
+
+	w := httptest.NewRecorder()
+	writeGRPCError(w, grpcStatusUnavailable, "no healthy upstream")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/grpc" || w.Body.Len() != 0 {
+		t.Errorf("response = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if code := GRPCStatus(w.Header()); code == nil || *code != grpcStatusUnavailable || w.Header().Get("Grpc-Message") != "no healthy upstream" {
+		t.Errorf("status = %v, message %q", code, w.Header().Get("Grpc-Message"))
+	}
+}
+
+func TestUpstreamTransport(t *testing.T) {
+	plain := &BackendEndpoint{URLParsed: &url.URL{Scheme: "http", Host: "upstream"}}
+	secure := &BackendEndpoint{URLParsed: &url.URL{Scheme: "https", Host: "upstream"}}
+	tests := []struct {
+		protocol string
+		endpoint *BackendEndpoint
+		want     http.RoundTripper
+	}{
+		{ProtocolHTTP, plain, nil},
+		{ProtocolHTTP, secure, nil},
+		{ProtocolGRPC, plain, h2cTransport},
+		{ProtocolGRPC, secure, h2Transport},
+		{ProtocolH2C, plain, h2cTransport},
+	}
+	for _, tt := range tests {
+		cfg := &BackendConfig{Protocol: tt.protocol}
+		if got := cfg.upstreamTransport(tt.endpoint); got != tt.want {
+			t.Errorf("%s %s: transport %T %p, want %p", tt.protocol, tt.endpoint.URLParsed.Scheme, got, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGRPCHealth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	status := health.NewServer()
+	healthpb.RegisterHealthServer(server, status)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	endpoint := &BackendEndpoint{URLParsed: &url.URL{Scheme: "http", Host: ln.Addr().String()}}
+	healthy, code, reason, _ := checkGRPCHealth(endpoint, nil, 2*time.Second)
+	if !healthy || code != http.StatusOK {
+		t.Errorf("serving = %t %d %q", healthy, code, reason)
+	}
+
+	status.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthy, code, reason, _ = checkGRPCHealth(endpoint, nil, 2*time.Second)
+	if healthy || code != http.StatusServiceUnavailable || reason != "gRPC Health: NOT_SERVING" {
+		t.Errorf("not serving = %t %d %q", healthy, code, reason)
+	}
+
+	server.Stop()
+	if healthy, code, _, _ := checkGRPCHealth(endpoint, nil, 500*time.Millisecond); healthy || code != 0 {
+		t.Errorf("stopped server = %t %d", healthy, code)
+	}
+}
@@ -1,10 +1,19 @@
 package gatewayio
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"imanager.io/utils"
 )
 
 // GatewayConfigHandler exposes configuration endpoints and interacts with the service layer.
@@ -13,7 +22,56 @@ type GatewayConfigHandler struct {
 }
 type StatusRecorder struct {
 	http.ResponseWriter
-	Status int
+	Status    int
+	Reason    string // set when the gateway answers a request itself, e.g. an IP denial
+	Coalesced int    // identical requests that shared this request's upstream response
+}
+
+// findRecorder unwraps w down to the access log's StatusRecorder, if any.
+func findRecorder(w http.ResponseWriter) *StatusRecorder {
+	for {
+		switch v := w.(type) {
+		case *StatusRecorder:
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// noteReason records why the gateway answered a request itself, for the access log.
+// The reason is cut to fit AccessLog.Reason, since parts of it (ban reasons,
+// fault names) come from user input.
+func noteReason(w http.ResponseWriter, reason string) {
+	if rec := findRecorder(w); rec != nil {
+		rec.Reason = truncateRunes(reason, maxReasonLength)
+	}
+}
+
+// maxReasonLength matches the varchar size of AccessLog.Reason.
+const maxReasonLength = 255
+
+// truncateRunes cuts s to at most n characters without splitting one.
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// noteCoalesced records how many followers shared a leader's response.
+func noteCoalesced(w http.ResponseWriter, n int) {
+	if rec := findRecorder(w); rec != nil {
+		rec.Coalesced = n
+	}
 }
 
 // WriteHeader implements the http.ResponseWriter interface method.
@@ -37,6 +95,23 @@ func (r *StatusRecorder) Header() http.Header {
 	return r.ResponseWriter.Header()
 }
 
+// Hijack lets WebSocket upgrades take over the connection through the recorder.
+func (r *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	r.Status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Flush forwards to the underlying writer so streamed responses are not held back.
+func (r *StatusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // NewGatewayConfigHandler initializes the handler with the business logic service.
 func NewGatewayConfigHandler(s BackendService) *GatewayConfigHandler {
 	return &GatewayConfigHandler{service: s}
@@ -55,8 +130,7 @@ func (h *GatewayConfigHandler) CreateConfig(c *gin.Context) {
 	// 2. Call the Service Layer to create the configuration (DB persistence, cache update, Gateway reload)
 	newConfig, err := h.service.Create(&dto)
 	if err != nil {
-		log.Printf("ERROR saving config: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
+		respondServiceError(c, err, "Failed to save configuration")
 		return
 	}
 
@@ -68,6 +142,281 @@ func (h *GatewayConfigHandler) CreateConfig(c *gin.Context) {
 	})
 }
 
+// UpdateConfig handles PUT /config/v1/backends/:id to replace a configuration.
+func (h *GatewayConfigHandler) UpdateConfig(c *gin.Context) {
+	var dto BackendConfigDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+
+	updated, err := h.service.Update(c.Param("id"), &dto)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update configuration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      updated.ID,
+		"message": "Backend configuration updated and gateway reloaded.",
+		"status":  "success",
+	})
+}
+
+// DeleteConfig handles DELETE /config/v1/backends/:id.
+func (h *GatewayConfigHandler) DeleteConfig(c *gin.Context) {
+	if err := h.service.Delete(c.Param("id")); err != nil {
+		respondServiceError(c, err, "Failed to delete configuration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      c.Param("id"),
+		"message": "Backend configuration deleted and gateway reloaded.",
+		"status":  "success",
+	})
+}
+
+// ListIPBans handles GET /config/v1/ip-bans.
+func (h *GatewayConfigHandler) ListIPBans(c *gin.Context) {
+	bans, err := h.service.ListIPBans()
+	if err != nil {
+		respondServiceError(c, err, "Failed to list IP bans")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": bans, "count": len(bans)})
+}
+
+// CreateIPBan handles POST /config/v1/ip-bans with an address or CIDR and a duration.
+func (h *GatewayConfigHandler) CreateIPBan(c *gin.Context) {
+	var dto IPBanDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	ban, err := h.service.CreateIPBan(&dto)
+	if err != nil {
+		respondServiceError(c, err, "Failed to create IP ban")
+		return
+	}
+	c.JSON(http.StatusCreated, ban)
+}
+
+// DeleteIPBan handles DELETE /config/v1/ip-bans/:id.
+func (h *GatewayConfigHandler) DeleteIPBan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ip ban id"})
+		return
+	}
+	if err := h.service.DeleteIPBan(uint(id)); err != nil {
+		respondServiceError(c, err, "Failed to delete IP ban")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "IP ban lifted"})
+}
+
+// SetMaintenance handles PUT /config/v1/backends/:id/maintenance. Sending
+// {"enabled": false} without windows takes the route out of maintenance.
+func (h *GatewayConfigHandler) SetMaintenance(c *gin.Context) {
+	var policy MaintenancePolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	updated, err := h.service.SetMaintenance(c.Param("id"), &policy)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update maintenance")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": updated.ID, "maintenance": updated.Maintenance})
+}
+
+// GetWaitingRoom handles GET /config/v1/backends/:id/waiting-room.
+func (h *GatewayConfigHandler) GetWaitingRoom(c *gin.Context) {
+	stats, err := h.service.GetWaitingRoom(c.Param("id"))
+	if err != nil {
+		respondServiceError(c, err, "Failed to read waiting room")
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// SetWaitingRoom handles PUT /config/v1/backends/:id/waiting-room; the change
+// applies to the next visitor without resetting the queue.
+func (h *GatewayConfigHandler) SetWaitingRoom(c *gin.Context) {
+	var policy WaitingRoomPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	updated, err := h.service.SetWaitingRoom(c.Param("id"), &policy)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update waiting room")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": updated.ID, "waitingRoom": updated.WaitingRoom})
+}
+
+// DeleteWaitingRoom handles DELETE /config/v1/backends/:id/waiting-room.
+func (h *GatewayConfigHandler) DeleteWaitingRoom(c *gin.Context) {
+	if _, err := h.service.SetWaitingRoom(c.Param("id"), nil); err != nil {
+		respondServiceError(c, err, "Failed to remove waiting room")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Waiting room removed"})
+}
+
+// SetEndpointState handles PUT /config/v1/backends/:id/endpoints/:endpointId/state.
+// The response is the drain status; poll GetEndpointDrain until drained is true.
+func (h *GatewayConfigHandler) SetEndpointState(c *gin.Context) {
+	endpointID, err := strconv.ParseUint(c.Param("endpointId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endpoint id"})
+		return
+	}
+	var dto EndpointStateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	status, err := h.service.SetEndpointState(c.Param("id"), uint(endpointID), &dto)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update endpoint state")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// GetEndpointDrain handles GET /config/v1/backends/:id/endpoints/:endpointId/drain.
+func (h *GatewayConfigHandler) GetEndpointDrain(c *gin.Context) {
+	endpointID, err := strconv.ParseUint(c.Param("endpointId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endpoint id"})
+		return
+	}
+	status, err := h.service.GetEndpointDrain(c.Param("id"), uint(endpointID))
+	if err != nil {
+		respondServiceError(c, err, "Failed to read endpoint drain")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ListErrorPages handles GET /config/v1/error-pages.
+func (h *GatewayConfigHandler) ListErrorPages(c *gin.Context) {
+	pages, err := h.service.ListErrorPages()
+	if err != nil {
+		respondServiceError(c, err, "Failed to list error pages")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": pages, "count": len(pages)})
+}
+
+// SaveErrorPage handles PUT /config/v1/error-pages; one page per status and backend.
+func (h *GatewayConfigHandler) SaveErrorPage(c *gin.Context) {
+	var dto ErrorPageDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format: " + err.Error()})
+		return
+	}
+	page, err := h.service.SaveErrorPage(&dto)
+	if err != nil {
+		respondServiceError(c, err, "Failed to save error page")
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// DeleteErrorPage handles DELETE /config/v1/error-pages/:id.
+func (h *GatewayConfigHandler) DeleteErrorPage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid error page id"})
+		return
+	}
+	if err := h.service.DeleteErrorPage(uint(id)); err != nil {
+		respondServiceError(c, err, "Failed to delete error page")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Error page deleted"})
+}
+
+// GetConnectionLogs handles GET /config/v1/backends/:id/connections?limit= for TCP/UDP routes.
+func (h *GatewayConfigHandler) GetConnectionLogs(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 10000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 10000"})
+			return
+		}
+		limit = n
+	}
+
+	logs, err := h.service.GetConnectionLogs(c.Param("id"), limit)
+	if err != nil {
+		log.Printf("ERROR loading connection logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load connection logs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": logs, "count": len(logs)})
+}
+
+// GetMirrorLogs handles GET /config/v1/backends/:id/mirror?limit= for routes with a shadow set.
+func (h *GatewayConfigHandler) GetMirrorLogs(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 10000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 10000"})
+			return
+		}
+		limit = n
+	}
+
+	logs, err := h.service.GetMirrorLogs(c.Param("id"), limit)
+	if err != nil {
+		log.Printf("ERROR loading mirror logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mirror logs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": logs, "count": len(logs)})
+}
+
+// GetMirrorSummary handles GET /config/v1/backends/:id/mirror/summary?startTime=
+// (RFC3339, default the last 24 hours).
+func (h *GatewayConfigHandler) GetMirrorSummary(c *gin.Context) {
+	since := time.Now().Add(-24 * time.Hour)
+	if raw := c.Query("startTime"); raw != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime, expected RFC3339"})
+			return
+		}
+	}
+
+	summary, err := h.service.GetMirrorSummary(c.Param("id"), since)
+	if err != nil {
+		log.Printf("ERROR summarizing mirror logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize mirror logs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// respondServiceError maps a utils.ServiceError to its status; anything else is a 500 with fallback.
+func respondServiceError(c *gin.Context, err error, fallback string) {
+	var svcErr *utils.ServiceError
+	if errors.As(err, &svcErr) {
+		if svcErr.StatusCode >= http.StatusInternalServerError {
+			log.Printf("ERROR: %s: %v", fallback, err)
+		}
+		c.JSON(svcErr.StatusCode, gin.H{"error": svcErr.Message})
+		return
+	}
+	log.Printf("ERROR: %s: %v", fallback, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
 // ListConfigs handles GET /config/v1/backends to retrieve all registered endpoints.
 func (h *GatewayConfigHandler) ListConfigs(c *gin.Context) {
 	configs := h.service.GetRuntimeConfigs()
@@ -75,10 +424,14 @@ func (h *GatewayConfigHandler) ListConfigs(c *gin.Context) {
 	// 2. Return the list
 	c.JSON(http.StatusOK, configs)
 }
+
+// GetHealthHistory handles GET /config/v1/backends/:id/history.
+// Optional query: endpointId, startTime, endTime (RFC3339) and limit.
 func (h *GatewayConfigHandler) GetHealthHistory(c *gin.Context) {
-	backendID := c.Param("id")
-	query := &HistoryQueryDTO{
-		BackendID: backendID,
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 	history, err := h.service.GetHistory(query)
 	if err != nil {
@@ -89,4 +442,325 @@ func (h *GatewayConfigHandler) GetHealthHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+// GetHealthRollups handles GET /config/v1/backends/:id/history/rollups.
+// Same query as GetHealthHistory plus granularity (hour or day, default hour).
+func (h *GatewayConfigHandler) GetHealthRollups(c *gin.Context) {
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Granularity = c.DefaultQuery("granularity", RollupHourly)
+
+	rollups, err := h.service.GetHistoryRollups(query)
+	if err != nil {
+		respondServiceError(c, err, "Failed to read health rollups")
+		return
+	}
+	c.JSON(http.StatusOK, rollups)
+}
+
+// GetUptimeReport handles GET /config/v1/reports/uptime.
+// Query: backendId, month (YYYY-MM) or startTime/endTime (RFC3339), slo, format (json, csv or html).
+// The window defaults to the current calendar month (UTC) up to now.
+func (h *GatewayConfigHandler) GetUptimeReport(c *gin.Context) {
+	query, err := parseReportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.UptimeReport(query)
+	if err != nil {
+		respondServiceError(c, err, "Failed to build uptime report")
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="uptime-report.csv"`)
+		if err := report.WriteCSV(c.Writer); err != nil {
+			log.Printf("ERROR writing uptime CSV: %v", err)
+		}
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := report.WriteHTML(c.Writer); err != nil {
+			log.Printf("ERROR writing uptime HTML: %v", err)
+		}
+	case "json":
+		c.JSON(http.StatusOK, report)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or html"})
+	}
+}
+
+// parseReportQuery resolves the report window from month or startTime/endTime.
+func parseReportQuery(c *gin.Context) (*ReportQueryDTO, error) {
+	now := time.Now().UTC()
+	query := &ReportQueryDTO{
+		BackendID: c.Query("backendId"),
+		StartTime: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   now,
+	}
+
+	if raw := c.Query("month"); raw != "" {
+		month, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid month, expected YYYY-MM: %w", err)
+		}
+		query.StartTime = month
+		query.EndTime = month.AddDate(0, 1, 0)
+		if query.EndTime.After(now) {
+			query.EndTime = now
+		}
+	} else {
+		var err error
+		if raw := c.Query("startTime"); raw != "" {
+			if query.StartTime, err = time.Parse(time.RFC3339, raw); err != nil {
+				return nil, fmt.Errorf("invalid startTime, expected RFC3339: %w", err)
+			}
+		}
+		if raw := c.Query("endTime"); raw != "" {
+			if query.EndTime, err = time.Parse(time.RFC3339, raw); err != nil {
+				return nil, fmt.Errorf("invalid endTime, expected RFC3339: %w", err)
+			}
+		}
+	}
+
+	if raw := c.Query("slo"); raw != "" {
+		slo, err := strconv.ParseFloat(raw, 64)
+		if err != nil || slo <= 0 || slo >= 100 {
+			return nil, fmt.Errorf("slo must be a percentage between 0 and 100")
+		}
+		query.SLOTarget = slo
+	}
+	return query, nil
+}
+
+const (
+	defaultHistoryLimit = 1000
+	maxHistoryLimit     = 10000
+)
+
+// parseHistoryQuery builds a HistoryQueryDTO from the path and query string.
+func parseHistoryQuery(c *gin.Context) (*HistoryQueryDTO, error) {
+	query := &HistoryQueryDTO{
+		BackendID: c.Param("id"),
+	}
+
+	limit, err := utils.ParseIntOrDefault(c.Query("limit"), defaultHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxHistoryLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+	}
+	query.Limit = limit
+
+	if raw := c.Query("endpointId"); raw != "" {
+		endpointID, err := utils.ParseUintID(raw)
+		if err != nil {
+			return nil, err
+		}
+		query.EndpointID = endpointID
+	}
+	if raw := c.Query("startTime"); raw != "" {
+		if query.StartTime, err = time.Parse(time.RFC3339, raw); err != nil {
+			return nil, fmt.Errorf("invalid startTime, expected RFC3339: %w", err)
+		}
+	}
+	if raw := c.Query("endTime"); raw != "" {
+		if query.EndTime, err = time.Parse(time.RFC3339, raw); err != nil {
+			return nil, fmt.Errorf("invalid endTime, expected RFC3339: %w", err)
+		}
+	}
+	if !query.StartTime.IsZero() && !query.EndTime.IsZero() && query.EndTime.Before(query.StartTime) {
+		return nil, fmt.Errorf("endTime must not be before startTime")
+	}
+	return query, nil
+}
+
 // In your gateway/gateway.go or the file defining AccessLoggingHandler
+
+// WebSocketHandler exposes the live WebSocket connections of the gateway.
+type WebSocketHandler struct {
+	gateway *Gateway
+}
+
+func NewWebSocketHandler(g *Gateway) *WebSocketHandler {
+	return &WebSocketHandler{gateway: g}
+}
+
+// Register WebSocket inspector routes
+func (h *WebSocketHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/config/v1/websockets", h.ListConnections)
+	r.DELETE("/config/v1/websockets/:id", h.CloseConnection)
+	r.DELETE("/config/v1/backends/:id/websockets", h.CloseBackendConnections)
+}
+
+// ListConnections handles GET /config/v1/websockets?backendId=.
+func (h *WebSocketHandler) ListConnections(c *gin.Context) {
+	conns := h.gateway.WebSocketConnections(c.Query("backendId"))
+	c.JSON(http.StatusOK, gin.H{"data": conns, "count": len(conns)})
+}
+
+// CloseConnection handles DELETE /config/v1/websockets/:id?code=&reason=.
+func (h *WebSocketHandler) CloseConnection(c *gin.Context) {
+	code, reason, err := parseCloseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.gateway.CloseWebSocket(c.Param("id"), code, reason) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WebSocket connection not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "WebSocket connection closed", "code": code})
+}
+
+// CloseBackendConnections handles DELETE /config/v1/backends/:id/websockets?code=&reason=.
+func (h *WebSocketHandler) CloseBackendConnections(c *gin.Context) {
+	code, reason, err := parseCloseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	closed := h.gateway.CloseWebSockets(c.Param("id"), code, reason)
+	c.JSON(http.StatusOK, gin.H{"message": "WebSocket connections closed", "closed": closed, "code": code})
+}
+
+// parseCloseParams reads the close code (default 1001 Going Away) and reason.
+func parseCloseParams(c *gin.Context) (int, string, error) {
+	code := websocket.CloseGoingAway
+	if v := c.Query("code"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || !ValidCloseCode(n) {
+			return 0, "", fmt.Errorf("invalid close code %q: use 1000, 1001, 1008, 1011-1013 or 3000-4999", v)
+		}
+		code = n
+	}
+	reason := c.DefaultQuery("reason", "closed by administrator")
+	// Close frame payloads are limited to 125 bytes, two of which hold the code.
+	if len(reason) > 123 {
+		return 0, "", fmt.Errorf("reason must be at most 123 bytes")
+	}
+	return code, reason, nil
+}
+
+// MetricsHandler exposes the gateway's in-memory counters and probe results.
+type MetricsHandler struct {
+	gateway *Gateway
+}
+
+func NewMetricsHandler(g *Gateway) *MetricsHandler {
+	return &MetricsHandler{gateway: g}
+}
+
+// Register metrics routes
+func (h *MetricsHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/config/v1/metrics", h.GetMetrics)
+	r.GET("/config/v1/upstream-certificates", h.GetUpstreamCertificates)
+}
+
+// GetMetrics handles GET /config/v1/metrics.
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.gateway.Metrics()})
+}
+
+// GetUpstreamCertificates handles GET /config/v1/upstream-certificates?withinDays=30.
+func (h *MetricsHandler) GetUpstreamCertificates(c *gin.Context) {
+	withinDays := 0
+	if v := c.Query("withinDays"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "withinDays must be a non-negative integer"})
+			return
+		}
+		withinDays = n
+	}
+	certs := h.gateway.UpstreamCertificates(withinDays)
+	c.JSON(http.StatusOK, gin.H{"data": certs, "count": len(certs)})
+}
+
+// CacheHandler manages the gateway's response cache.
+type CacheHandler struct {
+	gateway *Gateway
+}
+
+func NewCacheHandler(g *Gateway) *CacheHandler {
+	return &CacheHandler{gateway: g}
+}
+
+// Register response cache routes
+func (h *CacheHandler) RegisterRoutes(r *gin.Engine) {
+	r.POST("/config/v1/cache/purge", h.Purge)
+}
+
+// Purge handles POST /config/v1/cache/purge. Every given field must match, so
+// {"backendId": "...", "tags": ["product"]} drops product entries of one route.
+func (h *CacheHandler) Purge(c *gin.Context) {
+	var purge CachePurge
+	if err := c.ShouldBindJSON(&purge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if purge.BackendID == "" && purge.Prefix == "" && len(purge.Tags) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give at least one of backendId, prefix or tags"})
+		return
+	}
+	if h.gateway.Cache == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "response cache is not configured"})
+		return
+	}
+	purged, err := h.gateway.PurgeCache(c.Request.Context(), purge)
+	if err != nil {
+		log.Printf("ERROR: Cache purge failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to purge cache"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cache purged", "purged": purged})
+}
+
+// StaticHandler uploads the files of STATIC routes.
+type StaticHandler struct {
+	gateway *Gateway
+}
+
+func NewStaticHandler(g *Gateway) *StaticHandler {
+	return &StaticHandler{gateway: g}
+}
+
+// Register static site routes
+func (h *StaticHandler) RegisterRoutes(r *gin.Engine) {
+	r.PUT("/config/v1/backends/:id/static", h.Upload)
+}
+
+// Upload handles PUT /config/v1/backends/:id/static with a zip or tar.gz archive,
+// either as the raw body or as the "archive" field of a multipart form.
+func (h *StaticHandler) Upload(c *gin.Context) {
+	body := io.Reader(c.Request.Body)
+	if file, err := c.FormFile("archive"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	files, err := h.gateway.InstallStaticArchive(c.Param("id"), body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Static site installed", "files": files})
+	case errors.Is(err, errStaticNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errStaticNotUploadable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errStaticArchiveInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Installing static site for backend %s failed: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to install static site"})
+	}
+}
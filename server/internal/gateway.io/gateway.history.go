@@ -0,0 +1,101 @@
+package gatewayio
+
+import (
+	"log"
+	"time"
+)
+
+// RetentionPolicy controls how long each level of health history is kept.
+// Raw probes older than RawRetention become hourly rollups, hourly rollups
+// older than HourlyRetention become daily rollups, and daily rollups older
+// than DailyRetention are deleted (zero keeps them forever).
+type RetentionPolicy struct {
+	RawRetention    time.Duration
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+	Interval        time.Duration
+}
+
+// HistoryRetentionJob downsamples and prunes health history in the background.
+type HistoryRetentionJob struct {
+	repo   BackendRepository
+	policy RetentionPolicy
+}
+
+// NewHistoryRetentionJob fills in defaults for any unset policy values.
+func NewHistoryRetentionJob(repo BackendRepository, policy RetentionPolicy) *HistoryRetentionJob {
+	if policy.RawRetention <= 0 {
+		policy.RawRetention = 7 * 24 * time.Hour
+	}
+	if policy.HourlyRetention <= 0 {
+		policy.HourlyRetention = 90 * 24 * time.Hour
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = time.Hour
+	}
+	return &HistoryRetentionJob{repo: repo, policy: policy}
+}
+
+// Start runs the job immediately and then on every interval. It blocks; run it in a goroutine.
+func (j *HistoryRetentionJob) Start() {
+	j.RunOnce(time.Now())
+
+	ticker := time.NewTicker(j.policy.Interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		j.RunOnce(now)
+	}
+}
+
+// RunOnce performs a single retention pass relative to now.
+// Buckets are aligned to UTC hours and days.
+func (j *HistoryRetentionJob) RunOnce(now time.Time) {
+	now = now.UTC()
+
+	// 1. Raw probes -> hourly rollups (one completed hour at a time)
+	hourCutoff := now.Add(-j.policy.RawRetention).Truncate(time.Hour)
+	if oldest, ok, err := j.repo.OldestHealthHistory(); err != nil {
+		log.Printf("ERROR: Retention failed to read oldest health history: %v", err)
+	} else if ok {
+		hours := 0
+		for start := oldest.UTC().Truncate(time.Hour); start.Before(hourCutoff); start = start.Add(time.Hour) {
+			if err := j.repo.RollupHealthHistory(start, start.Add(time.Hour)); err != nil {
+				log.Printf("ERROR: Retention failed to roll up hour %s: %v", start.Format(time.RFC3339), err)
+				return
+			}
+			hours++
+		}
+		if hours > 0 {
+			log.Printf("INFO: Retention rolled up %d hour(s) of raw health history", hours)
+		}
+	}
+
+	// 2. Hourly rollups -> daily rollups
+	dayCutoff := startOfDay(now.Add(-j.policy.HourlyRetention))
+	if oldest, ok, err := j.repo.OldestHealthRollup(RollupHourly); err != nil {
+		log.Printf("ERROR: Retention failed to read oldest hourly rollup: %v", err)
+	} else if ok {
+		days := 0
+		for day := startOfDay(oldest.UTC()); day.Before(dayCutoff); day = day.AddDate(0, 0, 1) {
+			if err := j.repo.RollupHourlyToDaily(day, day.AddDate(0, 0, 1)); err != nil {
+				log.Printf("ERROR: Retention failed to roll up day %s: %v", day.Format("2006-01-02"), err)
+				return
+			}
+			days++
+		}
+		if days > 0 {
+			log.Printf("INFO: Retention rolled up %d day(s) of hourly health rollups", days)
+		}
+	}
+
+	// 3. Expire daily rollups
+	if j.policy.DailyRetention > 0 {
+		if err := j.repo.DeleteHealthRollupsBefore(RollupDaily, now.Add(-j.policy.DailyRetention)); err != nil {
+			log.Printf("ERROR: Retention failed to expire daily rollups: %v", err)
+		}
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,126 @@
+package gatewayio
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyRepo fakes the health history part of BackendRepository; other
+// methods panic through the nil embedded interface.
+type historyRepo struct {
+	BackendRepository
+	oldestRaw, oldestHourly time.Time
+	hours, days             []time.Time
+	dailyBefore             time.Time
+	failAt                  time.Time
+	rollups                 []*HealthRollup
+	err                     error
+}
+
+func (r *historyRepo) OldestHealthHistory() (time.Time, bool, error) {
+	return r.oldestRaw, !r.oldestRaw.IsZero(), nil
+}
+
+func (r *historyRepo) OldestHealthRollup(string) (time.Time, bool, error) {
+	return r.oldestHourly, !r.oldestHourly.IsZero(), nil
+}
+
+func (r *historyRepo) RollupHealthHistory(from, to time.Time) error {
+	if from.Equal(r.failAt) {
+		return errors.New("database down")
+	}
+	if to.Sub(from) != time.Hour {
+		return errors.New("bucket is not one hour")
+	}
+	r.hours = append(r.hours, from)
+	return nil
+}
+
+func (r *historyRepo) RollupHourlyToDaily(from, to time.Time) error {
+	if !to.Equal(from.AddDate(0, 0, 1)) {
+		return errors.New("bucket is not one day")
+	}
+	r.days = append(r.days, from)
+	return nil
+}
+
+func (r *historyRepo) DeleteHealthRollupsBefore(granularity string, before time.Time) error {
+	if granularity == RollupDaily {
+		r.dailyBefore = before
+	}
+	return nil
+}
+
+func (r *historyRepo) GetHealthRollups(*HistoryQueryDTO) ([]*HealthRollup, error) {
+	return r.rollups, r.err
+}
+
+func TestHistoryRetentionRunOnce(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC) }
+	now := at(10, 12).Add(30 * time.Minute)
+	repo := &historyRepo{oldestRaw: at(10, 7).Add(15 * time.Minute), oldestHourly: at(6, 5)}
+	job := NewHistoryRetentionJob(repo, RetentionPolicy{
+		RawRetention:    2 * time.Hour,
+		HourlyRetention: 48 * time.Hour,
+		DailyRetention:  30 * 24 * time.Hour,
+	})
+	job.RunOnce(now)
+
+	if want := []time.Time{at(10, 7), at(10, 8), at(10, 9)}; !slices.Equal(repo.hours, want) {
+		t.Errorf("rolled up hours %v, want %v", repo.hours, want)
+	}
+	if want := []time.Time{at(6, 0), at(7, 0)}; !slices.Equal(repo.days, want) {
+		t.Errorf("rolled up days %v, want %v", repo.days, want)
+	}
+	if want := now.Add(-30 * 24 * time.Hour); !repo.dailyBefore.Equal(want) {
+		t.Errorf("expired daily rollups before %v, want %v", repo.dailyBefore, want)
+	}
+}
+
+func TestHistoryRetentionStopsOnError(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 3, 10, hour, 0, 0, 0, time.UTC) }
+	repo := &historyRepo{oldestRaw: at(1), failAt: at(3), oldestHourly: at(0).AddDate(0, 0, -5)}
+	NewHistoryRetentionJob(repo, RetentionPolicy{RawRetention: time.Hour}).RunOnce(at(10))
+	if want := []time.Time{at(1), at(2)}; !slices.Equal(repo.hours, want) {
+		t.Errorf("rolled up hours %v, want %v before the failure", repo.hours, want)
+	}
+	if len(repo.days) != 0 {
+		t.Errorf("daily rollups ran after a failed hour: %v", repo.days)
+	}
+}
+
+func TestGetHealthRollupsStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		granularity string
+		repoErr     error
+		status      int
+	}{
+		{"hourly", "", nil, http.StatusOK},
+		{"daily", RollupDaily, nil, http.StatusOK},
+		{"unknown granularity", "week", nil, http.StatusBadRequest},
+		{"database failure", RollupHourly, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		h := NewGatewayConfigHandler(&backendService{repo: &historyRepo{err: tt.repoErr}})
+		router.GET("/backends/:id/history/rollups", h.GetHealthRollups)
+
+		target := "/backends/b1/history/rollups"
+		if tt.granularity != "" {
+			target += "?granularity=" + tt.granularity
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// gateway.ipfilter.go
+package gatewayio
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IPFilter is an allow/deny list of addresses and CIDRs. Deny entries win; a
+// non-empty Allow list admits only the addresses it covers.
+type IPFilter struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// ipRules is the parsed form of an IPFilter.
+type ipRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// parsePrefixes accepts CIDRs ("10.0.0.0/8") and bare addresses ("10.0.0.5").
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+func (f *IPFilter) compile() (*ipRules, error) {
+	if f == nil {
+		return &ipRules{}, nil
+	}
+	allow, err := parsePrefixes(f.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	deny, err := parsePrefixes(f.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return &ipRules{allow: allow, deny: deny}, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) (netip.Prefix, bool) {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// check returns a denial reason, or "" when addr may pass. scope names the list
+// in the reason ("global" or "route").
+func (r *ipRules) check(addr netip.Addr, scope string) string {
+	if p, ok := containsAddr(r.deny, addr); ok {
+		return fmt.Sprintf("%s denylist (%s)", scope, p)
+	}
+	if len(r.allow) > 0 {
+		if _, ok := containsAddr(r.allow, addr); !ok {
+			return scope + " allowlist"
+		}
+	}
+	return ""
+}
+
+// ipFilterState caches the parsed route filter, once per loaded config.
+type ipFilterState struct {
+	once  sync.Once
+	rules *ipRules
+	err   error
+}
+
+// checkRouteIP applies the route's filter. Filters are validated on save, so a
+// parse failure here means a hand-edited row; the route then denies everyone.
+func (b *BackendConfig) checkRouteIP(addr netip.Addr) string {
+	s := &b.ipState
+	s.once.Do(func() {
+		s.rules, s.err = b.IPFilter.compile()
+		if s.err != nil {
+			log.Printf("ERROR: IP filter of backend %s is invalid, denying all clients: %v", b.ID, s.err)
+		}
+	})
+	if s.err != nil {
+		return "invalid route IP filter"
+	}
+	return s.rules.check(addr, "route")
+}
+
+// ipPolicy holds the gateway-wide filter, trusted proxies and active bans.
+type ipPolicy struct {
+	mu      sync.RWMutex
+	global  *ipRules
+	trusted []netip.Prefix
+	bans    []*IPBan
+}
+
+// SetIPPolicy installs the global allow/deny lists and the proxies whose
+// X-Forwarded-For header is trusted to carry the real client address.
+func (g *Gateway) SetIPPolicy(global IPFilter, trustedProxies []string) error {
+	rules, err := global.compile()
+	if err != nil {
+		return err
+	}
+	trusted, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return fmt.Errorf("trustedProxies: %w", err)
+	}
+	g.ipPolicy.mu.Lock()
+	g.ipPolicy.global, g.ipPolicy.trusted = rules, trusted
+	g.ipPolicy.mu.Unlock()
+	return nil
+}
+
+// ReloadIPBans replaces the active bans; called by the service after changes.
+func (g *Gateway) ReloadIPBans(bans []*IPBan) {
+	active := make([]*IPBan, 0, len(bans))
+	for _, ban := range bans {
+		prefixes, err := parsePrefixes([]string{ban.CIDR})
+		if err != nil {
+			log.Printf("ERROR: Skipping IP ban %d: %v", ban.ID, err)
+			continue
+		}
+		ban.prefix = prefixes[0]
+		active = append(active, ban)
+	}
+	g.ipPolicy.mu.Lock()
+	g.ipPolicy.bans = active
+	g.ipPolicy.mu.Unlock()
+}
+
+// realClientIP returns the client address, walking X-Forwarded-For from the
+// right while the hop that added it is a trusted proxy.
+func (g *Gateway) realClientIP(r *http.Request) string {
+	peer := clientIP(r)
+	g.ipPolicy.mu.RLock()
+	trusted := g.ipPolicy.trusted
+	g.ipPolicy.mu.RUnlock()
+	if len(trusted) == 0 {
+		return peer
+	}
+
+	isTrusted := func(ip string) bool {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		_, ok := containsAddr(trusted, addr.Unmap())
+		return ok
+	}
+	if !isTrusted(peer) {
+		return peer
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrusted(hop) {
+			return hop
+		}
+		peer = hop
+	}
+	return peer
+}
+
+// checkIP returns why ip may not reach cfg, or "" when it may. cfg may be nil
+// for requests that match no route; only the global rules and bans apply then.
+func (g *Gateway) checkIP(ip string, cfg *BackendConfig) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "unparseable client address"
+	}
+	addr = addr.Unmap()
+
+	g.ipPolicy.mu.RLock()
+	global, bans := g.ipPolicy.global, g.ipPolicy.bans
+	g.ipPolicy.mu.RUnlock()
+
+	now := time.Now()
+	for _, ban := range bans {
+		if now.After(ban.ExpiresAt) || !ban.prefix.Contains(addr) {
+			continue
+		}
+		if ban.BackendID == "" || (cfg != nil && ban.BackendID == cfg.ID) {
+			reason := fmt.Sprintf("banned until %s", ban.ExpiresAt.Format(time.RFC3339))
+			if ban.Reason != "" {
+				reason += ": " + ban.Reason
+			}
+			return reason
+		}
+	}
+	if global != nil {
+		if reason := global.check(addr, "global"); reason != "" {
+			return reason
+		}
+	}
+	if cfg != nil {
+		return cfg.checkRouteIP(addr)
+	}
+	return ""
+}
+
+// denyIP answers a blocked request with 403 (or PERMISSION_DENIED for gRPC)
+// and notes the reason for the access log.
+func denyIP(w http.ResponseWriter, r *http.Request, reason string) {
+	noteReason(w, "ip denied: "+reason)
+	if isGRPCRequest(r) {
+		writeGRPCError(w, grpcStatusPermissionDenied, "client address is not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Forbidden: client address is not allowed"})
+}
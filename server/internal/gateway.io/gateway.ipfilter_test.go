@@ -0,0 +1,105 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := parsePrefixes([]string{"10.1.2.3/8", " 192.168.0.5 ", "::ffff:10.0.0.9", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.0.5/32", "10.0.0.9/32", "2001:db8::/32"}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := parsePrefixes([]string{bad}); err == nil {
+			t.Errorf("parsePrefixes(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestIPRulesCheck(t *testing.T) {
+	rules, err := (&IPFilter{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32"},
+		Deny:  []string{"10.0.5.0/24"},
+	}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"10.0.5.7", false}, // deny wins over allow
+		{"192.168.1.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := rules.check(netip.MustParseAddr(tt.addr), "route") == ""; got != tt.allowed {
+			t.Errorf("check(%s) allowed = %t, want %t", tt.addr, got, tt.allowed)
+		}
+	}
+
+	open, _ := (*IPFilter)(nil).compile()
+	if reason := open.check(netip.MustParseAddr("1.2.3.4"), "global"); reason != "" {
+		t.Errorf("empty filter denied: %s", reason)
+	}
+}
+
+func TestRealClientIP(t *testing.T) {
+	g := &Gateway{}
+	if err := g.SetIPPolicy(IPFilter{}, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		peer   string
+		xff    []string
+		client string
+	}{
+		{"untrusted peer ignores xff", "203.0.113.9:1234", []string{"1.1.1.1"}, "203.0.113.9"},
+		{"trusted peer without xff", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"one trusted hop", "10.0.0.1:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"walks trusted hops from the right", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.7, 10.2.3.4"}, "198.51.100.7"},
+		{"spoofed left entries are ignored", "10.0.0.1:1234", []string{"6.6.6.6", "198.51.100.7"}, "198.51.100.7"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.9.9.9, 10.2.3.4"}, "10.9.9.9"},
+		{"empty entries skipped", "10.0.0.1:1234", []string{"198.51.100.7, , "}, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := g.realClientIP(r); got != tt.client {
+				t.Errorf("realClientIP = %s, want %s", got, tt.client)
+			}
+		})
+	}
+}
+
+func TestDenyIPReasonFitsAccessLog(t *testing.T) {
+	for _, reason := range []string{"short", strings.Repeat("x", 300), strings.Repeat("ü", 300)} {
+		rec := &StatusRecorder{ResponseWriter: httptest.NewRecorder()}
+		denyIP(rec, httptest.NewRequest(http.MethodGet, "/", nil), "banned until 2030-01-01T00:00:00Z: "+reason)
+		if n := utf8.RuneCountInString(rec.Reason); n > maxReasonLength || !utf8.ValidString(rec.Reason) {
+			t.Errorf("logged reason has %d characters (valid UTF-8 %t), column holds %d", n, utf8.ValidString(rec.Reason), maxReasonLength)
+		}
+		if !strings.HasPrefix(rec.Reason, "ip denied: banned until") {
+			t.Errorf("logged reason = %q", rec.Reason)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// gateway.l4.go
+package gatewayio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	l4DialTimeout      = 5 * time.Second
+	udpSessionIdle     = 60 * time.Second // upstream sockets of silent UDP clients are released after this
+	udpMaxDatagramSize = 64 * 1024
+)
+
+// l4Manager owns the dedicated TCP/UDP listeners, keyed by backend ID.
+type l4Manager struct {
+	mu        sync.Mutex
+	listeners map[string]*l4Listener
+}
+
+// l4Listener serves one TCP or UDP route. The config pointer is swapped on
+// reload so endpoint changes apply without rebinding the port.
+type l4Listener struct {
+	protocol string
+	port     int
+	config   atomic.Pointer[BackendConfig]
+	closer   io.Closer
+}
+
+// syncL4Listeners starts listeners for new TCP/UDP routes, stops the ones
+// whose route is gone or moved to another port, and refreshes the rest.
+func (g *Gateway) syncL4Listeners(configs []*BackendConfig) {
+	g.l4.mu.Lock()
+	defer g.l4.mu.Unlock()
+	if g.l4.listeners == nil {
+		g.l4.listeners = make(map[string]*l4Listener)
+	}
+
+	wanted := make(map[string]*BackendConfig)
+	for _, cfg := range configs {
+		if cfg.IsL4() {
+			wanted[cfg.ID] = cfg
+		}
+	}
+
+	for id, l := range g.l4.listeners {
+		cfg, ok := wanted[id]
+		if ok && cfg.Protocol == l.protocol && cfg.ListenPort == l.port {
+			l.config.Store(cfg)
+			continue
+		}
+		l.closer.Close()
+		delete(g.l4.listeners, id)
+		log.Printf("INFO: Stopped %s listener on port %d for backend %s", l.protocol, l.port, id)
+	}
+
+	for id, cfg := range wanted {
+		if _, running := g.l4.listeners[id]; running {
+			continue
+		}
+		l, err := g.startL4Listener(cfg)
+		if err != nil {
+			log.Printf("ERROR: Failed to start %s listener on port %d for backend %s: %v", cfg.Protocol, cfg.ListenPort, id, err)
+			continue
+		}
+		g.l4.listeners[id] = l
+		log.Printf("INFO: Started %s listener on port %d for backend %s", cfg.Protocol, cfg.ListenPort, id)
+	}
+}
+
+func (g *Gateway) startL4Listener(cfg *BackendConfig) (*l4Listener, error) {
+	l := &l4Listener{protocol: cfg.Protocol, port: cfg.ListenPort}
+	l.config.Store(cfg)
+	addr := ":" + strconv.Itoa(cfg.ListenPort)
+
+	if cfg.Protocol == ProtocolUDP {
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		l.closer = pc
+		go g.serveUDP(l, pc)
+		return l, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	l.closer = ln
+	go g.serveTCP(l, ln)
+	return l, nil
+}
+
+func (g *Gateway) serveTCP(l *l4Listener, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("WARN: TCP accept on port %d failed: %v", l.port, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go g.proxyTCPConn(l.config.Load(), conn)
+	}
+}
+
+// proxyTCPConn pipes one client connection to a healthy endpoint. Connections
+// already running keep going when their listener is stopped.
+func (g *Gateway) proxyTCPConn(cfg *BackendConfig, client net.Conn) {
+	defer client.Close()
+	metrics := g.metrics.route(cfg.ID)
+	metrics.connections.Add(1)
+	metrics.activeConnections.Add(1)
+	defer metrics.activeConnections.Add(-1)
+
+	entry := &ConnectionLog{
+		BackendID: cfg.ID,
+		Protocol:  ProtocolTCP,
+		ClientIP:  hostOnly(client.RemoteAddr()),
+		StartedAt: time.Now(),
+	}
+	defer g.recordConnection(entry)
+
+	if reason := g.checkIP(entry.ClientIP, cfg); reason != "" {
+		metrics.ipDenied.Add(1)
+		entry.Error = "ip denied: " + reason
+		return
+	}
+	endpoint := cfg.GetNextHealthyEndpoint()
+	if endpoint == nil {
+		entry.Error = "no healthy endpoints"
+		return
+	}
+	entry.EndpointID = endpoint.ID
+	defer g.endpointLoads.begin(endpoint.ID)()
+	upstream, err := net.DialTimeout("tcp", endpoint.URLParsed.Host, l4DialTimeout)
+	if err != nil {
+		entry.Error = fmt.Sprintf("dial %s: %v", endpoint.URLParsed.Host, err)
+		return
+	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		entry.BytesIn = pipeTCP(upstream, client)
+	}()
+	go func() {
+		defer wg.Done()
+		entry.BytesOut = pipeTCP(client, upstream)
+	}()
+	wg.Wait()
+}
+
+// pipeTCP copies src to dst, then half-closes dst so the peer sees EOF while
+// the other direction drains. On errors both sides are torn down.
+func pipeTCP(dst, src net.Conn) int64 {
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		src.Close()
+		return n
+	}
+	if tcp, ok := dst.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	} else {
+		dst.Close()
+	}
+	return n
+}
+
+// udpSession maps one client address to its own upstream socket.
+type udpSession struct {
+	upstream  net.Conn
+	entry     *ConnectionLog
+	lastSeen  atomic.Int64 // unix nanos
+	bytesIn   atomic.Int64
+	bytesOut  atomic.Int64
+	closeOnce sync.Once
+}
+
+func (g *Gateway) serveUDP(l *l4Listener, pc net.PacketConn) {
+	var (
+		mu       sync.Mutex
+		sessions = make(map[string]*udpSession)
+		done     = make(chan struct{})
+	)
+	closeSession := func(s *udpSession) {
+		s.closeOnce.Do(func() {
+			s.upstream.Close()
+			s.entry.BytesIn = s.bytesIn.Load()
+			s.entry.BytesOut = s.bytesOut.Load()
+			g.metrics.route(s.entry.BackendID).activeConnections.Add(-1)
+			go g.recordConnection(s.entry) // keep the session lock free of database writes
+		})
+	}
+	defer func() {
+		close(done)
+		mu.Lock()
+		for key, s := range sessions {
+			delete(sessions, key)
+			closeSession(s)
+		}
+		mu.Unlock()
+	}()
+
+	// Janitor: release sessions that have been silent for udpSessionIdle.
+	go func() {
+		ticker := time.NewTicker(udpSessionIdle / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				for key, s := range sessions {
+					if time.Since(time.Unix(0, s.lastSeen.Load())) >= udpSessionIdle {
+						delete(sessions, key)
+						closeSession(s)
+					}
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	buf := make([]byte, udpMaxDatagramSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("WARN: UDP read on port %d failed: %v", l.port, err)
+			continue
+		}
+
+		mu.Lock()
+		s := sessions[addr.String()]
+		if s == nil {
+			s = g.openUDPSession(l.config.Load(), pc, addr)
+			if s == nil {
+				mu.Unlock()
+				continue
+			}
+			sessions[addr.String()] = s
+		}
+		mu.Unlock()
+
+		s.lastSeen.Store(time.Now().UnixNano())
+		if _, err := s.upstream.Write(buf[:n]); err != nil {
+			log.Printf("WARN: UDP forward from %s on port %d failed: %v", addr, l.port, err)
+			continue
+		}
+		s.bytesIn.Add(int64(n))
+	}
+}
+
+// openUDPSession dials a healthy endpoint for a new client and relays its
+// replies back through the listener. nil when no endpoint can be reached.
+func (g *Gateway) openUDPSession(cfg *BackendConfig, pc net.PacketConn, client net.Addr) *udpSession {
+	entry := &ConnectionLog{
+		BackendID: cfg.ID,
+		Protocol:  ProtocolUDP,
+		ClientIP:  hostOnly(client),
+		StartedAt: time.Now(),
+	}
+	if reason := g.checkIP(entry.ClientIP, cfg); reason != "" {
+		g.metrics.route(cfg.ID).ipDenied.Add(1)
+		entry.Error = "ip denied: " + reason
+		g.recordConnection(entry)
+		return nil
+	}
+	endpoint := cfg.GetNextHealthyEndpoint()
+	if endpoint == nil {
+		entry.Error = "no healthy endpoints"
+		g.recordConnection(entry)
+		return nil
+	}
+	entry.EndpointID = endpoint.ID
+	upstream, err := net.DialTimeout("udp", endpoint.URLParsed.Host, l4DialTimeout)
+	if err != nil {
+		entry.Error = fmt.Sprintf("dial %s: %v", endpoint.URLParsed.Host, err)
+		g.recordConnection(entry)
+		return nil
+	}
+
+	metrics := g.metrics.route(cfg.ID)
+	metrics.connections.Add(1)
+	metrics.activeConnections.Add(1)
+
+	s := &udpSession{upstream: upstream, entry: entry}
+	s.lastSeen.Store(time.Now().UnixNano())
+	go func() {
+		buf := make([]byte, udpMaxDatagramSize)
+		for {
+			n, err := upstream.Read(buf)
+			if err != nil {
+				return // closed by the janitor or the listener
+			}
+			s.lastSeen.Store(time.Now().UnixNano())
+			if _, err := pc.WriteTo(buf[:n], client); err != nil {
+				return
+			}
+			s.bytesOut.Add(int64(n))
+		}
+	}()
+	return s
+}
+
+// recordConnection stamps the duration and persists entry.
+func (g *Gateway) recordConnection(entry *ConnectionLog) {
+	entry.DurationMs = time.Since(entry.StartedAt).Milliseconds()
+	if err := g.BackendService.RecordConnectionLog(entry); err != nil {
+		log.Printf("ERROR: Failed to record connection log for backend %s: %v", entry.BackendID, err)
+	}
+	log.Printf("  -> CONNECTION LOGGED: [%s] %s from %s. Endpoint: %d. In: %d bytes. Out: %d bytes. Duration: %dms. %s",
+		entry.BackendID, entry.Protocol, entry.ClientIP, entry.EndpointID, entry.BytesIn, entry.BytesOut, entry.DurationMs, entry.Error)
+}
+
+// probeL4 checks a TCP endpoint with a plain connect. UDP has no handshake, so a
+// probe datagram is sent and only an ICMP "port unreachable" counts as DOWN.
+// latency covers the connect or the datagram write, not the wait for ICMP.
+func probeL4(protocol string, endpoint *BackendEndpoint, timeout time.Duration) (healthy bool, reason string, latency time.Duration) {
+	start := time.Now()
+	if protocol == ProtocolTCP {
+		conn, err := net.DialTimeout("tcp", endpoint.URLParsed.Host, timeout)
+		if err != nil {
+			return false, fmt.Sprintf("TCP Connect Error: %v", err), time.Since(start)
+		}
+		conn.Close()
+		return true, "TCP connect OK", time.Since(start)
+	}
+
+	conn, err := net.DialTimeout("udp", endpoint.URLParsed.Host, timeout)
+	if err != nil {
+		return false, fmt.Sprintf("UDP Dial Error: %v", err), time.Since(start)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(nil); err != nil {
+		return false, fmt.Sprintf("UDP Write Error: %v", err), time.Since(start)
+	}
+	latency = time.Since(start)
+	conn.SetReadDeadline(time.Now().Add(timeout / 4))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true, "UDP no ICMP error", latency
+		}
+		return false, fmt.Sprintf("UDP Error: %v", err), latency
+	}
+	return true, "UDP reply received", time.Since(start)
+}
+
+// hostOnly strips the port from a network address.
+func hostOnly(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
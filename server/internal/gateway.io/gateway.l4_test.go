@@ -0,0 +1,55 @@
+package gatewayio
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func l4Endpoint(addr string) *BackendEndpoint {
+	return &BackendEndpoint{URLParsed: &url.URL{Host: addr}}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if healthy, reason, _ := probeL4(ProtocolTCP, l4Endpoint(addr), time.Second); !healthy {
+		t.Errorf("listening port probed DOWN: %s", reason)
+	}
+	ln.Close()
+	if healthy, _, _ := probeL4(ProtocolTCP, l4Endpoint(addr), time.Second); healthy {
+		t.Error("closed port probed UP")
+	}
+}
+
+func TestProbeUDP(t *testing.T) {
+	// A silent UDP service is UP, and the wait for an ICMP error (a quarter of
+	// the timeout) is not counted as latency, so it passes the latency gate.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	timeout := 2400 * time.Millisecond
+	start := time.Now()
+	healthy, reason, latency := probeL4(ProtocolUDP, l4Endpoint(addr), timeout)
+	if !healthy {
+		t.Fatalf("silent UDP service probed DOWN: %s", reason)
+	}
+	if elapsed := time.Since(start); elapsed < timeout/4 {
+		t.Errorf("probe returned after %v, before waiting for ICMP", elapsed)
+	}
+	if latency >= 500*time.Millisecond {
+		t.Errorf("latency = %v includes the ICMP wait", latency)
+	}
+
+	// On loopback a closed UDP port answers with ICMP port unreachable.
+	pc.Close()
+	if healthy, _, _ := probeL4(ProtocolUDP, l4Endpoint(addr), 400*time.Millisecond); healthy {
+		t.Error("closed UDP port probed UP")
+	}
+}
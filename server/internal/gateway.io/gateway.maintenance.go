@@ -0,0 +1,115 @@
+// gateway.maintenance.go
+package gatewayio
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultMaintenanceMessage = "Route is under maintenance."
+
+// MaintenancePolicy takes a route out of service, either right away (Enabled)
+// or during scheduled windows. Requests are answered with 503 and Retry-After.
+type MaintenancePolicy struct {
+	Enabled           bool                `json:"enabled"`
+	Windows           []MaintenanceWindow `json:"windows,omitempty" binding:"omitempty,dive"`
+	Message           string              `json:"message,omitempty"`                           // {{message}} of the 503 page; empty uses a generic text
+	RetryAfterSeconds int                 `json:"retryAfterSeconds" binding:"omitempty,gte=0"` // used when the end is unknown; 0 omits Retry-After
+}
+
+// MaintenanceWindow is a scheduled maintenance period, End exclusive.
+type MaintenanceWindow struct {
+	Start time.Time `json:"start" binding:"required"`
+	End   time.Time `json:"end" binding:"required"`
+}
+
+func (p *MaintenancePolicy) validate() error {
+	for i, window := range p.Windows {
+		if !window.End.After(window.Start) {
+			return fmt.Errorf("window %d: end must be after start", i)
+		}
+	}
+	return nil
+}
+
+// active reports whether the route is in maintenance at now and, when known,
+// when it ends.
+func (p *MaintenancePolicy) active(now time.Time) (bool, time.Time) {
+	if p == nil {
+		return false, time.Time{}
+	}
+	if p.Enabled {
+		return true, time.Time{}
+	}
+	for _, window := range p.Windows {
+		if !now.Before(window.Start) && now.Before(window.End) {
+			return true, window.End
+		}
+	}
+	return false, time.Time{}
+}
+
+// serveMaintenance answers r with 503 when the route is in maintenance. It
+// returns true when the request was answered.
+func (g *Gateway) serveMaintenance(w http.ResponseWriter, r *http.Request, cfg *BackendConfig) bool {
+	now := time.Now()
+	active, until := cfg.Maintenance.active(now)
+	if !active {
+		return false
+	}
+	retryAfter := cfg.Maintenance.RetryAfterSeconds
+	if !until.IsZero() {
+		retryAfter = int(math.Ceil(until.Sub(now).Seconds()))
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	noteReason(w, "maintenance")
+	if isGRPCRequest(r) {
+		writeGRPCError(w, grpcStatusUnavailable, "route under maintenance")
+		return true
+	}
+	message := cfg.Maintenance.Message
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	g.writeError(w, r, cfg, http.StatusServiceUnavailable, message)
+	return true
+}
+
+// MockResponse is the fixed answer of a MOCK route; no upstream is involved.
+type MockResponse struct {
+	Status  int               `json:"status" binding:"omitempty,min=200,max=599"` // 0 uses 200
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+func (m *MockResponse) validate() error {
+	if (m.Status == http.StatusNoContent || m.Status == http.StatusNotModified) && m.Body != "" {
+		return fmt.Errorf("status %d cannot have a body", m.Status)
+	}
+	return nil
+}
+
+// serveMock writes the route's static response.
+func serveMock(w http.ResponseWriter, r *http.Request, mock *MockResponse) {
+	status := mock.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	h := w.Header()
+	for name, value := range mock.Headers {
+		h.Set(name, value)
+	}
+	if h.Get("Content-Type") == "" && mock.Body != "" {
+		h.Set("Content-Type", http.DetectContentType([]byte(mock.Body)))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(mock.Body)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(mock.Body))
+	}
+}
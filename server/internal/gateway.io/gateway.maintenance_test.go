@@ -0,0 +1,83 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceActive(t *testing.T) {
+	now := time.Now()
+	window := MaintenanceWindow{Start: now.Add(-time.Minute), End: now.Add(time.Minute)}
+	tests := []struct {
+		name   string
+		policy *MaintenancePolicy
+		active bool
+		until  time.Time
+	}{
+		{"no policy", nil, false, time.Time{}},
+		{"enabled", &MaintenancePolicy{Enabled: true, Windows: []MaintenanceWindow{window}}, true, time.Time{}},
+		{"inside a window", &MaintenancePolicy{Windows: []MaintenanceWindow{window}}, true, window.End},
+		{"window ended", &MaintenancePolicy{Windows: []MaintenanceWindow{{Start: now.Add(-time.Hour), End: now}}}, false, time.Time{}},
+		{"window ahead", &MaintenancePolicy{Windows: []MaintenanceWindow{{Start: now.Add(time.Second), End: now.Add(time.Hour)}}}, false, time.Time{}},
+	}
+	for _, tt := range tests {
+		active, until := tt.policy.active(now)
+		if active != tt.active || !until.Equal(tt.until) {
+			t.Errorf("%s: active = %t until %v, want %t until %v", tt.name, active, until, tt.active, tt.until)
+		}
+	}
+	if err := (&MaintenancePolicy{Windows: []MaintenanceWindow{{Start: now, End: now}}}).validate(); err == nil {
+		t.Error("empty window accepted")
+	}
+}
+
+func TestServeMaintenance(t *testing.T) {
+	g := &Gateway{}
+	now := time.Now()
+	tests := []struct {
+		name       string
+		policy     *MaintenancePolicy
+		retryAfter string
+	}{
+		{"window end", &MaintenancePolicy{Windows: []MaintenanceWindow{{Start: now.Add(-time.Minute), End: now.Add(90 * time.Second)}}}, "90"},
+		{"configured", &MaintenancePolicy{Enabled: true, RetryAfterSeconds: 600}, "600"},
+		{"unknown", &MaintenancePolicy{Enabled: true}, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rec := &StatusRecorder{ResponseWriter: w}
+		if !g.serveMaintenance(rec, httptest.NewRequest(http.MethodGet, "/", nil), &BackendConfig{Maintenance: tt.policy}) {
+			t.Fatalf("%s: request passed through", tt.name)
+		}
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != tt.retryAfter || rec.Reason != "maintenance" {
+			t.Errorf("%s: status %d, Retry-After %q, reason %q", tt.name, w.Code, w.Header().Get("Retry-After"), rec.Reason)
+		}
+	}
+	if g.serveMaintenance(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), &BackendConfig{}) {
+		t.Error("route without maintenance was answered")
+	}
+}
+
+func TestServeMock(t *testing.T) {
+	mock := &MockResponse{Status: http.StatusCreated, Headers: map[string]string{"X-Mock": "1"}, Body: `{"ok":true}`}
+	w := httptest.NewRecorder()
+	serveMock(w, httptest.NewRequest(http.MethodGet, "/", nil), mock)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"ok":true}` || w.Header().Get("X-Mock") != "1" || w.Header().Get("Content-Length") != "11" {
+		t.Errorf("GET = %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w.Header().Get("Content-Type") == "" {
+		t.Error("Content-Type not detected")
+	}
+
+	w = httptest.NewRecorder()
+	serveMock(w, httptest.NewRequest(http.MethodHead, "/", nil), &MockResponse{Body: "hello"})
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "5" {
+		t.Errorf("HEAD = %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	if err := (&MockResponse{Status: http.StatusNoContent, Body: "x"}).validate(); err == nil {
+		t.Error("204 with a body accepted")
+	}
+}
@@ -0,0 +1,119 @@
+// gateway.metrics.go
+package gatewayio
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// routeMetrics holds in-memory counters for one backend; they reset on restart.
+type routeMetrics struct {
+	requests            atomic.Int64
+	activeRequests      atomic.Int64
+	upstreamTimeouts    atomic.Int64
+	streams             atomic.Int64
+	activeStreams       atomic.Int64
+	streamBytes         atomic.Int64
+	streamIdleTimeouts  atomic.Int64
+	connections         atomic.Int64 // TCP connections and UDP sessions
+	activeConnections   atomic.Int64
+	ipDenied            atomic.Int64 // requests and connections refused by the IP policy
+	cacheHits           atomic.Int64 // includes responses revalidated with a 304
+	cacheMisses         atomic.Int64
+	cacheStale          atomic.Int64 // served stale while refreshing in the background
+	coalesced           atomic.Int64 // requests answered with another request's upstream response
+	faults              atomic.Int64 // delays, aborts and resets injected by the fault policy
+	queued              atomic.Int64 // requests waiting for a route or endpoint slot
+	concurrencyRejected atomic.Int64 // requests refused with 503 by the concurrency policy
+}
+
+// RouteMetrics is the JSON view of one backend's counters.
+type RouteMetrics struct {
+	BackendID           string `json:"backendId"`
+	Requests            int64  `json:"requests"`
+	ActiveRequests      int64  `json:"activeRequests"`
+	UpstreamTimeouts    int64  `json:"upstreamTimeouts"`
+	Streams             int64  `json:"streams"`
+	ActiveStreams       int64  `json:"activeStreams"`
+	StreamBytes         int64  `json:"streamBytes"`
+	StreamIdleTimeouts  int64  `json:"streamIdleTimeouts"`
+	Connections         int64  `json:"connections"`
+	ActiveConnections   int64  `json:"activeConnections"`
+	IPDenied            int64  `json:"ipDenied"`
+	CacheHits           int64  `json:"cacheHits"`
+	CacheMisses         int64  `json:"cacheMisses"`
+	CacheStale          int64  `json:"cacheStale"`
+	Coalesced           int64  `json:"coalesced"`
+	Faults              int64  `json:"faults"`
+	Queued              int64  `json:"queued"`
+	ConcurrencyLimit    int    `json:"concurrencyLimit"` // route limit in force, adaptive or fixed; 0 when unbounded
+	ConcurrencyRejected int64  `json:"concurrencyRejected"`
+	ActiveWebSockets    int    `json:"activeWebSockets"`
+}
+
+// metricsRegistry maps backend IDs to their counters; the zero value is ready to use.
+type metricsRegistry struct {
+	mu     sync.Mutex
+	routes map[string]*routeMetrics
+}
+
+func (m *metricsRegistry) route(backendID string) *routeMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routes == nil {
+		m.routes = make(map[string]*routeMetrics)
+	}
+	rm, ok := m.routes[backendID]
+	if !ok {
+		rm = &routeMetrics{}
+		m.routes[backendID] = rm
+	}
+	return rm
+}
+
+// Metrics returns a snapshot of every backend that has seen traffic.
+func (g *Gateway) Metrics() []RouteMetrics {
+	g.metrics.mu.Lock()
+	ids := make([]string, 0, len(g.metrics.routes))
+	for id := range g.metrics.routes {
+		ids = append(ids, id)
+	}
+	g.metrics.mu.Unlock()
+	sort.Strings(ids)
+
+	out := make([]RouteMetrics, 0, len(ids))
+	for _, id := range ids {
+		rm := g.metrics.route(id)
+		limit := 0
+		g.mu.RLock()
+		cfg := g.backends[id]
+		g.mu.RUnlock()
+		if cfg != nil && cfg.Concurrency != nil && cfg.Concurrency.MaxInFlight > 0 {
+			limit, _ = g.bulkheads.routeSnapshot(id)
+		}
+		out = append(out, RouteMetrics{
+			BackendID:           id,
+			Requests:            rm.requests.Load(),
+			ActiveRequests:      rm.activeRequests.Load(),
+			UpstreamTimeouts:    rm.upstreamTimeouts.Load(),
+			Streams:             rm.streams.Load(),
+			ActiveStreams:       rm.activeStreams.Load(),
+			StreamBytes:         rm.streamBytes.Load(),
+			StreamIdleTimeouts:  rm.streamIdleTimeouts.Load(),
+			Connections:         rm.connections.Load(),
+			ActiveConnections:   rm.activeConnections.Load(),
+			IPDenied:            rm.ipDenied.Load(),
+			CacheHits:           rm.cacheHits.Load(),
+			CacheMisses:         rm.cacheMisses.Load(),
+			CacheStale:          rm.cacheStale.Load(),
+			Coalesced:           rm.coalesced.Load(),
+			Faults:              rm.faults.Load(),
+			Queued:              rm.queued.Load(),
+			ConcurrencyLimit:    limit,
+			ConcurrencyRejected: rm.concurrencyRejected.Load(),
+			ActiveWebSockets:    len(g.wsConns.find(id)),
+		})
+	}
+	return out
+}
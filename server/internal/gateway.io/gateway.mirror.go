@@ -0,0 +1,174 @@
+// gateway.mirror.go
+package gatewayio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultMirrorMaxBodyBytes = 64 << 10
+	defaultMirrorTimeout      = 10 * time.Second
+	// maxMirrorsInFlight bounds shadow requests across all routes; extra copies are dropped.
+	maxMirrorsInFlight = 256
+	// MirrorHeader marks shadow requests so shadow endpoints can tell them apart.
+	MirrorHeader = "X-Gateway-Mirror"
+)
+
+// MirrorPolicy copies a share of a route's requests to shadow endpoints. Shadow
+// responses are discarded; their status and latency land in MirrorLog.
+type MirrorPolicy struct {
+	TargetURLs     []string `json:"targetUrls" binding:"required,min=1,dive,url"`
+	Percent        float64  `json:"percent" binding:"required,gt=0,lte=100"`
+	MaxBodyBytes   int64    `json:"maxBodyBytes" binding:"omitempty,gte=0"`   // requests with larger bodies are not mirrored; 0 uses 64 KiB
+	TimeoutSeconds int      `json:"timeoutSeconds" binding:"omitempty,gte=0"` // 0 uses 10s
+}
+
+func (p *MirrorPolicy) maxBodyBytes() int64 {
+	if p.MaxBodyBytes > 0 {
+		return p.MaxBodyBytes
+	}
+	return defaultMirrorMaxBodyBytes
+}
+
+func (p *MirrorPolicy) timeout() time.Duration {
+	if p.TimeoutSeconds > 0 {
+		return time.Duration(p.TimeoutSeconds) * time.Second
+	}
+	return defaultMirrorTimeout
+}
+
+// mirrorOutcome is how the production request ended, for comparison.
+type mirrorOutcome struct {
+	status  int
+	latency time.Duration
+}
+
+// mirrorClient sends shadow requests; redirects are reported, not followed.
+var mirrorClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// mirrorSlots limits concurrent shadow requests so a slow shadow cannot pile up goroutines.
+var mirrorSlots = make(chan struct{}, maxMirrorsInFlight)
+
+// startMirror samples r for the route's shadow set. When chosen it buffers the
+// body (restoring r.Body for the real upstream) and sends the copy in the
+// background. The returned channel takes the production outcome; it is nil
+// when the request is not mirrored.
+func (g *Gateway) startMirror(r *http.Request, cfg *BackendConfig, requestID string) chan<- mirrorOutcome {
+	policy := cfg.Mirror
+	if policy == nil || isGRPCRequest(r) || rand.Float64()*100 >= policy.Percent {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		limit := policy.maxBodyBytes()
+		if r.ContentLength > limit {
+			return nil
+		}
+		buffered, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		// Whatever was read goes back in front of the rest for the real upstream.
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buffered), r.Body), r.Body}
+		if err != nil || int64(len(buffered)) > limit {
+			return nil
+		}
+		body = buffered
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		log.Printf("WARN: Dropping mirror of request %s on backend %s: too many shadow requests in flight", requestID, cfg.ID)
+		return nil
+	}
+
+	target := policy.TargetURLs[rand.Intn(len(policy.TargetURLs))]
+	entry := &MirrorLog{
+		BackendID: cfg.ID,
+		RequestID: requestID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		ShadowURL: target,
+		Timestamp: time.Now(),
+	}
+	header := r.Header.Clone()
+	query := r.URL.RawQuery
+	outcome := make(chan mirrorOutcome, 1)
+	go func() {
+		defer func() { <-mirrorSlots }()
+		g.sendMirror(entry, header, query, body, cfg.PathPrefix, policy.timeout())
+
+		// Wait for production to finish so both sides land in the same row.
+		select {
+		case primary := <-outcome:
+			entry.PrimaryStatus = primary.status
+			entry.PrimaryLatency = primary.latency.Nanoseconds()
+		case <-time.After(policy.timeout()):
+		}
+		if err := g.BackendService.RecordMirrorLog(entry); err != nil {
+			log.Printf("ERROR: Failed to record mirror log for backend %s: %v", entry.BackendID, err)
+		}
+	}()
+	return outcome
+}
+
+// sendMirror performs the shadow request and fills in its status, latency or error.
+func (g *Gateway) sendMirror(entry *MirrorLog, header http.Header, query string, body []byte, prefix string, timeout time.Duration) {
+	base, err := url.Parse(entry.ShadowURL)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	target := *base
+	target.Path = upstreamPath(base, prefix, entry.Path)
+	target.RawQuery = query
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, entry.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	req.Header = header
+	for _, name := range []string{"Connection", "Upgrade", "Te", "Keep-Alive", "Proxy-Connection"} {
+		req.Header.Del(name)
+	}
+	req.Header.Set(MirrorHeader, "1")
+	injectTraceContext(ctx, req.Header)
+
+	start := time.Now()
+	resp, err := mirrorClient.Do(req)
+	if err != nil {
+		entry.Latency = time.Since(start).Nanoseconds()
+		entry.Error = err.Error()
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	entry.Latency = time.Since(start).Nanoseconds()
+	entry.StatusCode = resp.StatusCode
+}
+
+// finishMirror hands the production outcome to a pending mirror, if any.
+func finishMirror(outcome chan<- mirrorOutcome, w http.ResponseWriter, start time.Time) {
+	if outcome == nil {
+		return
+	}
+	status := http.StatusOK
+	if rec := findRecorder(w); rec != nil && rec.Status != 0 {
+		status = rec.Status
+	}
+	outcome <- mirrorOutcome{status: status, latency: time.Since(start)}
+}
@@ -0,0 +1,90 @@
+package gatewayio
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mirrorService receives the recorded mirror logs.
+type mirrorService struct {
+	BackendService
+	logs chan *MirrorLog
+}
+
+func (s *mirrorService) RecordMirrorLog(entry *MirrorLog) error {
+	s.logs <- entry
+	return nil
+}
+
+func TestStartMirror(t *testing.T) {
+	type shadowRequest struct{ path, query, body, mirror, connection string }
+	seen := make(chan shadowRequest, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen <- shadowRequest{r.URL.Path, r.URL.RawQuery, string(body), r.Header.Get(MirrorHeader), r.Header.Get("Connection")}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer shadow.Close()
+
+	service := &mirrorService{logs: make(chan *MirrorLog, 1)}
+	g := &Gateway{BackendService: service}
+	cfg := &BackendConfig{ID: "b1", PathPrefix: "/api", Mirror: &MirrorPolicy{TargetURLs: []string{shadow.URL + "/v2"}, Percent: 100}}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/orders?dry=1", strings.NewReader(`{"id":1}`))
+	r.Header.Set("Connection", "keep-alive")
+	outcome := g.startMirror(r, cfg, "req-1")
+	if outcome == nil {
+		t.Fatal("request was not mirrored at 100%")
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"id":1}` {
+		t.Errorf("production body = %q", body)
+	}
+
+	got := <-seen
+	if got != (shadowRequest{"/v2/orders", "dry=1", `{"id":1}`, "1", ""}) {
+		t.Errorf("shadow saw %+v", got)
+	}
+
+	w := httptest.NewRecorder()
+	rec := &StatusRecorder{ResponseWriter: w, Status: http.StatusCreated}
+	finishMirror(outcome, rec, time.Now().Add(-time.Millisecond))
+	entry := <-service.logs
+	if entry.StatusCode != http.StatusTeapot || entry.PrimaryStatus != http.StatusCreated || entry.RequestID != "req-1" || entry.Error != "" {
+		t.Errorf("mirror log = %+v", entry)
+	}
+	if entry.Latency <= 0 || entry.PrimaryLatency <= 0 {
+		t.Errorf("latencies shadow %d, primary %d", entry.Latency, entry.PrimaryLatency)
+	}
+}
+
+func TestStartMirrorSkips(t *testing.T) {
+	g := &Gateway{BackendService: &mirrorService{logs: make(chan *MirrorLog, 1)}}
+	policy := &MirrorPolicy{TargetURLs: []string{"http://127.0.0.1:1"}, Percent: 100, MaxBodyBytes: 8}
+	cfg := &BackendConfig{ID: "b1", Mirror: policy}
+
+	// A body over the limit without Content-Length is read up to the limit,
+	// then handed to production intact.
+	large := strings.Repeat("x", 20)
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(large)))
+	r.ContentLength = -1
+	if g.startMirror(r, cfg, "req-1") != nil {
+		t.Error("oversized body was mirrored")
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != large {
+		t.Errorf("production body = %q, want it intact", body)
+	}
+
+	grpc := httptest.NewRequest(http.MethodPost, "/", nil)
+	grpc.Header.Set("Content-Type", "application/grpc")
+	if g.startMirror(grpc, cfg, "req-2") != nil {
+		t.Error("gRPC call was mirrored")
+	}
+	if g.startMirror(httptest.NewRequest(http.MethodGet, "/", nil), &BackendConfig{}, "req-3") != nil {
+		t.Error("route without a mirror policy was mirrored")
+	}
+	finishMirror(nil, httptest.NewRecorder(), time.Now())
+}
@@ -1,7 +1,9 @@
 package gatewayio
 
 import (
+	"encoding/json"
 	"log"
+	"net/netip"
 	"net/url"
 	"sync"
 	"time"
@@ -9,36 +11,118 @@ import (
 	"gorm.io/gorm"
 )
 
+// Route protocols accepted in BackendConfig.Protocol.
+const (
+	ProtocolHTTP     = "HTTP"
+	ProtocolWS       = "WS"
+	ProtocolGRPC     = "GRPC"     // gRPC over HTTP/2; h2c for http:// endpoints
+	ProtocolH2C      = "H2C"      // plain HTTP/2 without TLS (prior knowledge)
+	ProtocolTCP      = "TCP"      // raw streams on ListenPort
+	ProtocolUDP      = "UDP"      // datagrams on ListenPort
+	ProtocolMock     = "MOCK"     // answers with the route's Mock response; no endpoints
+	ProtocolStatic   = "STATIC"   // serves files from Static; no endpoints
+	ProtocolRedirect = "REDIRECT" // answers with Redirect; no endpoints
+)
+
 // BackendEndpoint represents a single physical instance (server) for a backend config.
 type BackendEndpoint struct {
-	ID              uint     `gorm:"primarykey" json:"id"`
-	BackendConfigID string   `gorm:"type:uuid;not null;index" json:"backendConfigId"`
-	URL             string   `gorm:"type:varchar(255);not null" json:"url"`
-	IsHealthy       bool     `gorm:"default:true" json:"isHealthy"` // Health status of this specific instance
-	URLParsed       *url.URL `gorm:"-" json:"-"`
+	ID              uint             `gorm:"primarykey" json:"id"`
+	BackendConfigID string           `gorm:"type:uuid;not null;index" json:"backendConfigId"`
+	URL             string           `gorm:"type:varchar(255);not null" json:"url"`
+	IsHealthy       bool             `gorm:"default:true" json:"isHealthy"`                                // Health status of this specific instance
+	TLS             *EndpointTLSInfo `gorm:"type:text;serializer:json" json:"tls,omitempty"`               // certificate seen by the last HTTPS probe
+	AdminState      string           `gorm:"type:varchar(10);not null;default:'active'" json:"adminState"` // one of the Endpoint* admin states
+	URLParsed       *url.URL         `gorm:"-" json:"-"`
+	warmSince       time.Time        // start of the slow-start ramp; zero means full weight
 }
 
 // BackendConfig represents a single API service configuration (the core model).
 type BackendConfig struct {
 	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	//  NEW FIELD: PathPrefix for routing (e.g., "/service-a/")
-	PathPrefix     string             `gorm:"type:varchar(255);not null;default:'/'" json:"pathPrefix"`
-	Protocol       string             // e.g., "HTTP", "WS"
-	Endpoints      []*BackendEndpoint `gorm:"foreignKey:BackendConfigID" json:"endpoints"`
-	RateLimit      int                `gorm:"not null" json:"rateLimit"`
-	AuthType       string             `gorm:"type:varchar(50);not null" json:"authType"`
-	LastUpdated    time.Time          `gorm:"autoUpdateTime" json:"lastUpdated"`
-	DeletedAt      gorm.DeletedAt     `gorm:"index" json:"-"`
-	currentLBIndex int                `gorm:"-"`
-	mu             sync.RWMutex
+	PathPrefix               string             `gorm:"type:varchar(255);not null;default:'/'" json:"pathPrefix"`
+	Protocol                 string             `gorm:"type:varchar(10);not null;default:'HTTP'"` // one of the Protocol* constants
+	ListenPort               int                `gorm:"not null;default:0" json:"listenPort"`     // TCP/UDP routes only
+	Endpoints                []*BackendEndpoint `gorm:"foreignKey:BackendConfigID" json:"endpoints"`
+	RateLimit                int                `gorm:"not null" json:"rateLimit"`
+	AuthType                 string             `gorm:"type:varchar(50);not null" json:"authType"`
+	SLOTarget                float64            `gorm:"not null;default:0" json:"sloTarget"` // availability objective in percent; 0 uses DefaultSLOTarget
+	WebSocket                *WebSocketPolicy   `gorm:"type:text;serializer:json" json:"webSocket,omitempty"`
+	ResponseTimeoutSeconds   int                `gorm:"not null;default:0" json:"responseTimeoutSeconds"`   // bounds non-streaming responses; 0 disables it
+	StreamIdleTimeoutSeconds int                `gorm:"not null;default:0" json:"streamIdleTimeoutSeconds"` // ends silent SSE/chunked streams; 0 disables it
+	RedirectHTTPS            bool               `gorm:"not null;default:false" json:"redirectHttps"`        // 308 plain-HTTP requests to the HTTPS listener
+	UpstreamTLS              *UpstreamTLS       `gorm:"type:text;serializer:json" json:"upstreamTls,omitempty"`
+	IPFilter                 *IPFilter          `gorm:"type:text;serializer:json" json:"ipFilter,omitempty"`
+	CORS                     *CORSPolicy        `gorm:"type:text;serializer:json" json:"cors,omitempty"`
+	Cache                    *CachePolicy       `gorm:"type:text;serializer:json" json:"cache,omitempty"` // nil disables response caching
+	Coalesce                 *CoalescePolicy    `gorm:"type:text;serializer:json" json:"coalesce,omitempty"`
+	Compression              *CompressionPolicy `gorm:"type:text;serializer:json" json:"compression,omitempty"`
+	Mirror                   *MirrorPolicy      `gorm:"type:text;serializer:json" json:"mirror,omitempty"`
+	Fault                    *FaultPolicy       `gorm:"type:text;serializer:json" json:"fault,omitempty"` // ignored once ExpiresAt passes
+	Maintenance              *MaintenancePolicy `gorm:"type:text;serializer:json" json:"maintenance,omitempty"`
+	Concurrency              *ConcurrencyPolicy `gorm:"type:text;serializer:json" json:"concurrency,omitempty"`
+	WaitingRoom              *WaitingRoomPolicy `gorm:"type:text;serializer:json" json:"waitingRoom,omitempty"`
+	Mock                     *MockResponse      `gorm:"type:text;serializer:json" json:"mock,omitempty"`     // MOCK routes only
+	Static                   *StaticSite        `gorm:"type:text;serializer:json" json:"static,omitempty"`   // STATIC routes only
+	Redirect                 *RedirectPolicy    `gorm:"type:text;serializer:json" json:"redirect,omitempty"` // REDIRECT routes only
+	SlowStartSeconds         int                `gorm:"not null;default:0" json:"slowStartSeconds"`          // ramps traffic to endpoints that just came UP; 0 disables it
+	LastUpdated              time.Time          `gorm:"autoUpdateTime" json:"lastUpdated"`
+	DeletedAt                gorm.DeletedAt     `gorm:"index" json:"-"`
+	currentLBIndex           int                `gorm:"-"`
+	tlsState                 upstreamTLSState
+	ipState                  ipFilterState
+	mu                       sync.RWMutex
 }
 
 // BackendConfigDTO for API requests
 type BackendConfigDTO struct {
-	PathPrefix string   `json:"pathPrefix" binding:"required"`
-	TargetURLs []string `json:"targetUrls" binding:"required"`
+	PathPrefix string   `json:"pathPrefix" binding:"required_without=ListenPort"`
+	TargetURLs []string `json:"targetUrls"` // required except on MOCK, STATIC and REDIRECT routes
 	RateLimit  int      `json:"rateLimit" binding:"required"`
 	AuthType   string   `json:"authType" binding:"required"`
+	SLOTarget  float64  `json:"sloTarget" binding:"omitempty,gt=0,lt=100"`
+	Protocol   string   `json:"protocol" binding:"omitempty,oneof=HTTP WS GRPC H2C TCP UDP MOCK STATIC REDIRECT"`
+	ListenPort int      `json:"listenPort" binding:"omitempty,min=1,max=65535"`
+	// WebSocket tunes upgraded connections; nil keeps the defaults.
+	WebSocket                *WebSocketPolicy   `json:"webSocket"`
+	ResponseTimeoutSeconds   int                `json:"responseTimeoutSeconds" binding:"omitempty,gte=0"`
+	StreamIdleTimeoutSeconds int                `json:"streamIdleTimeoutSeconds" binding:"omitempty,gte=0"`
+	RedirectHTTPS            bool               `json:"redirectHttps"` // send plain-HTTP clients to the HTTPS listener
+	UpstreamTLS              *UpstreamTLS       `json:"upstreamTls"`
+	IPFilter                 *IPFilter          `json:"ipFilter"`
+	CORS                     *CORSPolicy        `json:"cors"`
+	Cache                    *CachePolicy       `json:"cache"`
+	Coalesce                 *CoalescePolicy    `json:"coalesce"`
+	Compression              *CompressionPolicy `json:"compression"`
+	Mirror                   *MirrorPolicy      `json:"mirror"`
+	Fault                    *FaultPolicy       `json:"fault"`
+	Maintenance              *MaintenancePolicy `json:"maintenance"`
+	Concurrency              *ConcurrencyPolicy `json:"concurrency"`
+	WaitingRoom              *WaitingRoomPolicy `json:"waitingRoom"`
+	Mock                     *MockResponse      `json:"mock"`
+	Static                   *StaticSite        `json:"static"`
+	Redirect                 *RedirectPolicy    `json:"redirect"`
+	SlowStartSeconds         int                `json:"slowStartSeconds" binding:"omitempty,gte=0"`
+}
+
+// servedLocally reports whether the gateway answers the route itself, without endpoints.
+func (b *BackendConfig) servedLocally() bool {
+	return b.Protocol == ProtocolMock || b.Protocol == ProtocolStatic || b.Protocol == ProtocolRedirect
+}
+
+// MarshalJSON encodes the config under b.mu, which guards the endpoint fields
+// health probes update while the config API serves it.
+func (b *BackendConfig) MarshalJSON() ([]byte, error) {
+	type plain BackendConfig // drops this method
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return json.Marshal((*plain)(b))
+}
+
+// IsL4 reports whether the route is served by a dedicated TCP/UDP listener
+// instead of the HTTP router.
+func (b *BackendConfig) IsL4() bool {
+	return b.Protocol == ProtocolTCP || b.Protocol == ProtocolUDP
 }
 
 func (b *BackendConfig) EnsureURLsParsed() {
@@ -58,22 +142,60 @@ func (b *BackendConfig) EnsureURLsParsed() {
 	}
 }
 
-// HealthHistory records the health status of a backend at a specific time.
+// HasHealthyEndpoint reports whether at least one endpoint can take traffic:
+// healthy, parsed and not draining or disabled.
+func (b *BackendConfig) HasHealthyEndpoint() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ep := range b.Endpoints {
+		if ep.IsHealthy && ep.URLParsed != nil && ep.adminState() == EndpointActive {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthHistory records the result of a single probe against one endpoint.
 type HealthHistory struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	BackendID string         `gorm:"type:uuid;not null;index" json:"backendId"`
-	IsHealthy bool           `gorm:"not null" json:"isHealthy"`
-	Latency   int64          `json:"Latency"`
-	CheckedAt time.Time      `gorm:"index;autoCreateTime" json:"checkedAt"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primarykey" json:"id"`
+	BackendID     string         `gorm:"type:uuid;not null;index" json:"backendId"`
+	EndpointID    uint           `gorm:"index" json:"endpointId"`
+	IsHealthy     bool           `gorm:"not null" json:"isHealthy"`
+	Latency       int64          `json:"Latency"`
+	StatusCode    int            `gorm:"type:int" json:"statusCode"` // 0 when the probe failed before a response
+	FailureReason string         `gorm:"type:text" json:"failureReason,omitempty"`
+	CheckedAt     time.Time      `gorm:"index;autoCreateTime" json:"checkedAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Rollup granularities produced by the history retention job.
+const (
+	RollupHourly = "hour"
+	RollupDaily  = "day"
+)
+
+// HealthRollup is a downsampled summary of probes for one endpoint over an hour or a day.
+type HealthRollup struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	BackendID     string    `gorm:"type:uuid;not null;index:idx_health_rollup_bucket" json:"backendId"`
+	EndpointID    uint      `gorm:"index:idx_health_rollup_bucket" json:"endpointId"`
+	Granularity   string    `gorm:"type:varchar(10);not null;index:idx_health_rollup_bucket" json:"granularity"`
+	BucketStart   time.Time `gorm:"not null;index:idx_health_rollup_bucket" json:"bucketStart"`
+	Checks        int64     `gorm:"not null" json:"checks"`
+	HealthyChecks int64     `gorm:"not null" json:"healthyChecks"`
+	UptimePercent float64   `gorm:"not null" json:"uptimePercent"`
+	AvgLatency    int64     `json:"avgLatency"` // nanoseconds
+	MaxLatency    int64     `json:"maxLatency"` // nanoseconds
 }
 
 // HistoryQueryDTO defines the structure for fetching history (from frontend request).
 type HistoryQueryDTO struct {
-	BackendID string    `json:"backendId" binding:"required"`
-	StartTime time.Time `json:"startTime"`
-	EndTime   time.Time `json:"endTime"`
-	Limit     int       `json:"limit"`
+	BackendID   string    `json:"backendId" binding:"required"`
+	EndpointID  uint      `json:"endpointId"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	Limit       int       `json:"limit"`
+	Granularity string    `json:"granularity"` // hour or day; only used for rollup queries
 }
 
 type AccessLog struct {
@@ -85,5 +207,91 @@ type AccessLog struct {
 	Path       string         `gorm:"type:text;not null" json:"path"`
 	ClientIP   string         `gorm:"type:varchar(45);not null" json:"clientIP"`
 	StatusCode int            `gorm:"type:int;not null" json:"statusCode"`
+	RequestID  string         `gorm:"type:varchar(64);index" json:"requestId"`
+	GRPCStatus *int           `gorm:"type:int" json:"grpcStatus,omitempty"`      // nil for non-gRPC requests
+	Reason     string         `gorm:"type:varchar(255)" json:"reason,omitempty"` // why the gateway answered itself, e.g. an IP denial
+	Coalesced  int            `gorm:"not null;default:0" json:"coalesced"`       // identical requests that shared this upstream response
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// IPBan blocks an address or range until ExpiresAt, on one route or on all of them.
+type IPBan struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CIDR      string    `gorm:"type:varchar(50);not null" json:"cidr"`
+	BackendID string    `gorm:"type:varchar(64);index" json:"backendId,omitempty"` // empty bans on every route
+	Reason    string    `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	prefix    netip.Prefix
+}
+
+// IPBanDTO is the request body of POST /config/v1/ip-bans.
+type IPBanDTO struct {
+	IP              string `json:"ip" binding:"required"` // address or CIDR
+	BackendID       string `json:"backendId"`
+	DurationSeconds int    `json:"durationSeconds" binding:"required,min=1"`
+	Reason          string `json:"reason" binding:"max=255"`
+}
+
+// ErrorPage replaces the gateway's plain-text body for one status, on one route
+// or, with an empty BackendID, on every route and for unmatched paths.
+// Templates may use {{status}}, {{statusText}}, {{message}} and {{requestId}}.
+type ErrorPage struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	BackendID string    `gorm:"type:varchar(64);uniqueIndex:idx_error_page_scope" json:"backendId,omitempty"`
+	Status    int       `gorm:"not null;uniqueIndex:idx_error_page_scope" json:"status"`
+	HTML      string    `gorm:"type:text" json:"html,omitempty"` // served when the client prefers text/html
+	JSON      string    `gorm:"type:text" json:"json,omitempty"` // served to everyone else
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// ErrorPageDTO is the request body of PUT /config/v1/error-pages.
+type ErrorPageDTO struct {
+	BackendID string `json:"backendId"`
+	Status    int    `json:"status" binding:"required,oneof=404 429 502 503 504"`
+	HTML      string `json:"html"`
+	JSON      string `json:"json"`
+}
+
+// MirrorLog pairs one shadow request with the production request it copied.
+type MirrorLog struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	BackendID      string    `gorm:"type:uuid;not null;index" json:"backendId"`
+	RequestID      string    `gorm:"type:varchar(64);index" json:"requestId"`
+	Timestamp      time.Time `gorm:"index;not null" json:"timestamp"`
+	Method         string    `gorm:"type:varchar(10);not null" json:"method"`
+	Path           string    `gorm:"type:text;not null" json:"path"`
+	ShadowURL      string    `gorm:"type:varchar(255);not null" json:"shadowUrl"`
+	StatusCode     int       `gorm:"type:int" json:"statusCode"`               // 0 when the shadow request failed
+	Latency        int64     `gorm:"not null" json:"latency"`                  // nanoseconds
+	PrimaryStatus  int       `gorm:"type:int" json:"primaryStatus"`            // 0 when production had not finished in time
+	PrimaryLatency int64     `gorm:"not null;default:0" json:"primaryLatency"` // nanoseconds
+	Error          string    `gorm:"type:text" json:"error,omitempty"`
+}
+
+// MirrorSummary compares a route's shadow responses with production over a period.
+type MirrorSummary struct {
+	BackendID           string    `json:"backendId"`
+	Since               time.Time `json:"since"`
+	Total               int64     `json:"total"`
+	Errors              int64     `json:"errors"`        // shadow requests that got no response
+	StatusMatches       int64     `json:"statusMatches"` // shadow status equal to production status
+	ShadowServerErrors  int64     `json:"shadowServerErrors"`
+	PrimaryServerErrors int64     `json:"primaryServerErrors"`
+	AvgLatencyMs        float64   `json:"avgLatencyMs"`
+	AvgPrimaryLatencyMs float64   `json:"avgPrimaryLatencyMs"`
+}
+
+// ConnectionLog records one proxied TCP connection or UDP session.
+type ConnectionLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	BackendID  string    `gorm:"type:uuid;not null;index" json:"backendId"`
+	EndpointID uint      `gorm:"index" json:"endpointId"` // 0 when no endpoint was reached
+	Protocol   string    `gorm:"type:varchar(10);not null" json:"protocol"`
+	ClientIP   string    `gorm:"type:varchar(45);not null" json:"clientIP"`
+	StartedAt  time.Time `gorm:"index;not null" json:"startedAt"`
+	DurationMs int64     `gorm:"not null" json:"durationMs"`
+	BytesIn    int64     `gorm:"not null" json:"bytesIn"`  // client to upstream
+	BytesOut   int64     `gorm:"not null" json:"bytesOut"` // upstream to client
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+}
@@ -0,0 +1,80 @@
+// gateway.redirect.go
+package gatewayio
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectPolicy answers a REDIRECT route with a redirect instead of proxying.
+// Target may use {{scheme}}, {{host}}, {{path}} (the part after PathPrefix)
+// and {{query}}, e.g. "https://new.example.com/docs{{path}}".
+type RedirectPolicy struct {
+	Status       int    `json:"status" binding:"omitempty,oneof=301 302 303 307 308"` // 0 uses 302
+	Target       string `json:"target" binding:"required"`
+	PreservePath bool   `json:"preservePath"` // append the path after PathPrefix and the query string to Target
+}
+
+func (p *RedirectPolicy) status() int {
+	if p.Status != 0 {
+		return p.Status
+	}
+	return http.StatusFound
+}
+
+// validate renders Target with sample values and checks the result is a URL.
+func (p *RedirectPolicy) validate() error {
+	sample := p.render("https", "example.com", "/sample/path", "a=1")
+	if _, err := url.Parse(sample); err != nil {
+		return fmt.Errorf("target does not render to a valid URL: %v", err)
+	}
+	return nil
+}
+
+func (p *RedirectPolicy) render(scheme, host, rest, query string) string {
+	return strings.NewReplacer(
+		"{{scheme}}", scheme,
+		"{{host}}", host,
+		"{{path}}", rest,
+		"{{query}}", query,
+	).Replace(p.Target)
+}
+
+// location builds the redirect target for r on cfg.
+func (p *RedirectPolicy) location(r *http.Request, prefix string) (string, error) {
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	if rest != "" && !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	target, err := url.Parse(p.render(scheme, r.Host, rest, r.URL.RawQuery))
+	if err != nil {
+		return "", err
+	}
+	if p.PreservePath {
+		target.Path = strings.TrimSuffix(target.Path, "/") + rest
+		target.RawPath = ""
+		if r.URL.RawQuery != "" {
+			if target.RawQuery != "" {
+				target.RawQuery += "&"
+			}
+			target.RawQuery += r.URL.RawQuery
+		}
+	}
+	return target.String(), nil
+}
+
+// serveRedirect answers a REDIRECT route.
+func (g *Gateway) serveRedirect(w http.ResponseWriter, r *http.Request, cfg *BackendConfig) {
+	location, err := cfg.Redirect.location(r, cfg.PathPrefix)
+	if err != nil {
+		g.writeError(w, r, cfg, http.StatusBadGateway, "Redirect target is invalid.")
+		return
+	}
+	http.Redirect(w, r, location, cfg.Redirect.status())
+}
@@ -0,0 +1,53 @@
+package gatewayio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RedirectPolicy
+		target string
+		want   string
+	}{
+		{"template", RedirectPolicy{Target: "https://new.example.com/docs{{path}}?{{query}}"}, "/old/guide/intro?lang=en", "https://new.example.com/docs/guide/intro?lang=en"},
+		{"scheme and host", RedirectPolicy{Target: "{{scheme}}://www.{{host}}{{path}}"}, "/old/a", "http://www.example.com/a"},
+		{"preserve path", RedirectPolicy{Target: "https://new.example.com/v2/", PreservePath: true}, "/old/users/7?x=1", "https://new.example.com/v2/users/7?x=1"},
+		{"preserve path merges queries", RedirectPolicy{Target: "https://new.example.com/?src=old", PreservePath: true}, "/old/a?x=1", "https://new.example.com/a?src=old&x=1"},
+		{"prefix only", RedirectPolicy{Target: "https://new.example.com{{path}}"}, "/old", "https://new.example.com"},
+		{"fixed target", RedirectPolicy{Target: "https://new.example.com/"}, "/old/anything", "https://new.example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = "example.com"
+			got, err := tt.policy.location(r, "/old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeRedirect(t *testing.T) {
+	g := &Gateway{}
+	cfg := &BackendConfig{PathPrefix: "/old", Redirect: &RedirectPolicy{Status: http.StatusPermanentRedirect, Target: "https://new.example.com{{path}}"}}
+	w := httptest.NewRecorder()
+	g.serveRedirect(w, httptest.NewRequest(http.MethodPost, "/old/form", nil), cfg)
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "https://new.example.com/form" {
+		t.Errorf("redirect = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	cfg.Redirect = &RedirectPolicy{Target: "https://new.example.com"}
+	w = httptest.NewRecorder()
+	g.serveRedirect(w, httptest.NewRequest(http.MethodGet, "/old", nil), cfg)
+	if w.Code != http.StatusFound {
+		t.Errorf("default status = %d, want 302", w.Code)
+	}
+}
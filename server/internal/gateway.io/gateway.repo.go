@@ -6,18 +6,42 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BackendRepository defines the methods for data access.
 type BackendRepository interface {
 	Migrate() error
 	Create(cfg *BackendConfig) error
+	Update(cfg *BackendConfig, removedEndpointIDs []uint) error
+	Delete(id string) error
 	GetAll() ([]*BackendConfig, error)
 	//UpdateHealth(id string, isHealthy bool) error
 	UpdateEndpointHealth(configID string, endpointURL string, isHealthy bool) error
+	UpdateEndpointTLS(endpointID uint, info *EndpointTLSInfo) error
+	UpdateEndpointAdminState(endpointID uint, state string) error
 	SaveHealthHistory(record *HealthHistory) error
 	GetHealthHistory(query *HistoryQueryDTO) ([]*HealthHistory, error)
+	GetHealthRollups(query *HistoryQueryDTO) ([]*HealthRollup, error)
+	ListHealthHistory(backendID string, from, to time.Time) ([]*HealthHistory, error)
+	CountAccessLogs(backendID string, from, to time.Time) (total int64, serverErrors int64, err error)
+	OldestHealthHistory() (time.Time, bool, error)
+	OldestHealthRollup(granularity string) (time.Time, bool, error)
+	RollupHealthHistory(from, to time.Time) error
+	RollupHourlyToDaily(from, to time.Time) error
+	DeleteHealthRollupsBefore(granularity string, before time.Time) error
 	CreateAccessLog(logEntry *AccessLog) error
+	CreateConnectionLog(logEntry *ConnectionLog) error
+	ListConnectionLogs(backendID string, limit int) ([]*ConnectionLog, error)
+	CreateMirrorLog(logEntry *MirrorLog) error
+	ListMirrorLogs(backendID string, limit int) ([]*MirrorLog, error)
+	SummarizeMirrorLogs(backendID string, since time.Time) (*MirrorSummary, error)
+	CreateIPBan(ban *IPBan) error
+	ListActiveIPBans(now time.Time) ([]*IPBan, error)
+	DeleteIPBan(id uint) error
+	SaveErrorPage(page *ErrorPage) error
+	ListErrorPages() ([]*ErrorPage, error)
+	DeleteErrorPage(id uint) error
 }
 
 type gormRepository struct {
@@ -29,12 +53,68 @@ func NewGormRepository(db *gorm.DB) BackendRepository {
 }
 
 func (r *gormRepository) Migrate() error {
-	return r.db.AutoMigrate(&BackendConfig{}, &BackendEndpoint{}, &HealthHistory{}, &AccessLog{})
+	return r.db.AutoMigrate(&BackendConfig{}, &BackendEndpoint{}, &HealthHistory{}, &HealthRollup{}, &AccessLog{}, &ConnectionLog{}, &IPBan{}, &MirrorLog{}, &ErrorPage{})
 }
 
 func (r *gormRepository) Create(cfg *BackendConfig) error {
 	return r.db.Create(cfg).Error
 }
+
+// Update saves cfg's own columns, deletes removed endpoints and inserts new ones (ID 0).
+func (r *gormRepository) Update(cfg *BackendConfig, removedEndpointIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(cfg).Error; err != nil {
+			return err
+		}
+		if len(removedEndpointIDs) > 0 {
+			if err := tx.Where("id IN ?", removedEndpointIDs).Delete(&BackendEndpoint{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, ep := range cfg.Endpoints {
+			if ep.ID != 0 {
+				continue
+			}
+			if err := tx.Create(ep).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete soft-deletes a config; gorm.ErrRecordNotFound when it does not exist.
+func (r *gormRepository) Delete(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&BackendConfig{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormRepository) CreateConnectionLog(logEntry *ConnectionLog) error {
+	if err := r.db.Create(logEntry).Error; err != nil {
+		return fmt.Errorf("failed to create connection log: %w", err)
+	}
+	return nil
+}
+
+// ListConnectionLogs returns the newest connection logs first.
+func (r *gormRepository) ListConnectionLogs(backendID string, limit int) ([]*ConnectionLog, error) {
+	var logs []*ConnectionLog
+	db := r.db.Where("backend_id = ?", backendID).Order("started_at DESC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
 func (r *gormRepository) CreateAccessLog(logEntry *AccessLog) error {
 	if err := r.db.Create(logEntry).Error; err != nil {
 		return fmt.Errorf("failed to create access log: %w", err)
@@ -65,13 +145,14 @@ func (r *gormRepository) SaveHealthHistory(record *HealthHistory) error {
 	return r.db.Create(record).Error
 }
 
-// NEW: Implementation to fetch history records based on query parameters.
-func (r *gormRepository) GetHealthHistorys(query *HistoryQueryDTO) ([]*HealthHistory, error) {
-
-	var historyStructs []HealthHistory
-
+// GetHealthHistory returns raw probe records, newest first, filtered by the query.
+func (r *gormRepository) GetHealthHistory(query *HistoryQueryDTO) ([]*HealthHistory, error) {
+	var history []*HealthHistory
 	db := r.db.Where("backend_id = ?", query.BackendID).Order("checked_at DESC")
 
+	if query.EndpointID != 0 {
+		db = db.Where("endpoint_id = ?", query.EndpointID)
+	}
 	if !query.StartTime.IsZero() {
 		db = db.Where("checked_at >= ?", query.StartTime)
 	}
@@ -81,24 +162,221 @@ func (r *gormRepository) GetHealthHistorys(query *HistoryQueryDTO) ([]*HealthHis
 	if query.Limit > 0 {
 		db = db.Limit(query.Limit)
 	}
-	if err := db.Find(&historyStructs).Error; err != nil {
+	if err := db.Find(&history).Error; err != nil {
 		return nil, err
 	}
-	historyPointers := make([]*HealthHistory, len(historyStructs))
-	for i := range historyStructs {
-		historyPointers[i] = &historyStructs[i]
-	}
+	return history, nil // Return the slice of pointers directly
+}
 
-	return historyPointers, nil // Return the slice of pointers
+// GetHealthRollups returns hourly or daily summaries, newest first, filtered by the query.
+func (r *gormRepository) GetHealthRollups(query *HistoryQueryDTO) ([]*HealthRollup, error) {
+	var rollups []*HealthRollup
+	db := r.db.Where("backend_id = ? AND granularity = ?", query.BackendID, query.Granularity).
+		Order("bucket_start DESC")
+
+	if query.EndpointID != 0 {
+		db = db.Where("endpoint_id = ?", query.EndpointID)
+	}
+	if !query.StartTime.IsZero() {
+		db = db.Where("bucket_start >= ?", query.StartTime)
+	}
+	if !query.EndTime.IsZero() {
+		db = db.Where("bucket_start <= ?", query.EndTime)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if err := db.Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+	return rollups, nil
 }
 
-func (r *gormRepository) GetHealthHistory(query *HistoryQueryDTO) ([]*HealthHistory, error) {
+// ListHealthHistory returns all raw probes for a backend in [from, to), oldest first.
+func (r *gormRepository) ListHealthHistory(backendID string, from, to time.Time) ([]*HealthHistory, error) {
 	var history []*HealthHistory
-	db := r.db.Where("backend_id = ?", query.BackendID).Order("checked_at DESC")
-	if err := db.Find(&history).Error; err != nil {
+	err := r.db.Where("backend_id = ? AND checked_at >= ? AND checked_at < ?", backendID, from, to).
+		Order("checked_at ASC").
+		Find(&history).Error
+	if err != nil {
 		return nil, err
 	}
-	return history, nil // Return the slice of pointers directly
+	return history, nil
+}
+
+func (r *gormRepository) CreateMirrorLog(logEntry *MirrorLog) error {
+	if err := r.db.Create(logEntry).Error; err != nil {
+		return fmt.Errorf("failed to create mirror log: %w", err)
+	}
+	return nil
+}
+
+// ListMirrorLogs returns the newest mirror logs first.
+func (r *gormRepository) ListMirrorLogs(backendID string, limit int) ([]*MirrorLog, error) {
+	var logs []*MirrorLog
+	db := r.db.Where("backend_id = ?", backendID).Order("timestamp DESC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// SummarizeMirrorLogs aggregates a backend's mirror logs since the given time.
+func (r *gormRepository) SummarizeMirrorLogs(backendID string, since time.Time) (*MirrorSummary, error) {
+	var row struct {
+		Total               int64
+		Errors              int64
+		StatusMatches       int64
+		ShadowServerErrors  int64
+		PrimaryServerErrors int64
+		AvgLatency          float64
+		AvgPrimaryLatency   float64
+	}
+	err := r.db.Model(&MirrorLog{}).
+		Select("COUNT(*) AS total, "+
+			"COALESCE(SUM(CASE WHEN status_code = 0 THEN 1 ELSE 0 END), 0) AS errors, "+
+			"COALESCE(SUM(CASE WHEN status_code = primary_status THEN 1 ELSE 0 END), 0) AS status_matches, "+
+			"COALESCE(SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END), 0) AS shadow_server_errors, "+
+			"COALESCE(SUM(CASE WHEN primary_status >= 500 THEN 1 ELSE 0 END), 0) AS primary_server_errors, "+
+			"COALESCE(AVG(CASE WHEN status_code > 0 THEN latency END), 0) AS avg_latency, "+
+			"COALESCE(AVG(CASE WHEN primary_status > 0 THEN primary_latency END), 0) AS avg_primary_latency").
+		Where("backend_id = ? AND timestamp >= ?", backendID, since).
+		Scan(&row).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize mirror logs: %w", err)
+	}
+	return &MirrorSummary{
+		BackendID:           backendID,
+		Since:               since,
+		Total:               row.Total,
+		Errors:              row.Errors,
+		StatusMatches:       row.StatusMatches,
+		ShadowServerErrors:  row.ShadowServerErrors,
+		PrimaryServerErrors: row.PrimaryServerErrors,
+		AvgLatencyMs:        row.AvgLatency / float64(time.Millisecond),
+		AvgPrimaryLatencyMs: row.AvgPrimaryLatency / float64(time.Millisecond),
+	}, nil
+}
+
+// CountAccessLogs returns the number of requests and 5xx responses for a backend in [from, to).
+func (r *gormRepository) CountAccessLogs(backendID string, from, to time.Time) (int64, int64, error) {
+	var counts struct {
+		Total        int64
+		ServerErrors int64
+	}
+	err := r.db.Model(&AccessLog{}).
+		Select("COUNT(*) AS total, "+
+			"COALESCE(SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END), 0) AS server_errors").
+		Where("backend_id = ? AND timestamp >= ? AND timestamp < ?", backendID, from, to).
+		Scan(&counts).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count access logs: %w", err)
+	}
+	return counts.Total, counts.ServerErrors, nil
+}
+
+// OldestHealthHistory returns the time of the oldest raw probe record, if any.
+func (r *gormRepository) OldestHealthHistory() (time.Time, bool, error) {
+	var record HealthHistory
+	err := r.db.Order("checked_at ASC").Limit(1).Find(&record).Error
+	if err != nil || record.ID == 0 {
+		return time.Time{}, false, err
+	}
+	return record.CheckedAt, true, nil
+}
+
+// OldestHealthRollup returns the bucket start of the oldest rollup of the given granularity, if any.
+func (r *gormRepository) OldestHealthRollup(granularity string) (time.Time, bool, error) {
+	var rollup HealthRollup
+	err := r.db.Where("granularity = ?", granularity).Order("bucket_start ASC").Limit(1).Find(&rollup).Error
+	if err != nil || rollup.ID == 0 {
+		return time.Time{}, false, err
+	}
+	return rollup.BucketStart, true, nil
+}
+
+// healthAggregate is the scan target for rollup GROUP BY queries.
+type healthAggregate struct {
+	BackendID     string
+	EndpointID    uint
+	Checks        int64
+	HealthyChecks int64
+	AvgLatency    float64
+	MaxLatency    int64
+}
+
+// RollupHealthHistory folds raw probes in [from, to) into hourly rollups and deletes the raw rows.
+func (r *gormRepository) RollupHealthHistory(from, to time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rows []healthAggregate
+		err := tx.Model(&HealthHistory{}).
+			Select("backend_id, endpoint_id, COUNT(*) AS checks, "+
+				"SUM(CASE WHEN is_healthy THEN 1 ELSE 0 END) AS healthy_checks, "+
+				"AVG(latency) AS avg_latency, MAX(latency) AS max_latency").
+			Where("checked_at >= ? AND checked_at < ?", from, to).
+			Group("backend_id, endpoint_id").
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to aggregate health history: %w", err)
+		}
+		if err := createRollups(tx, rows, RollupHourly, from); err != nil {
+			return err
+		}
+		return tx.Unscoped().
+			Where("checked_at >= ? AND checked_at < ?", from, to).
+			Delete(&HealthHistory{}).Error
+	})
+}
+
+// RollupHourlyToDaily folds hourly rollups in [from, to) into daily rollups and deletes the hourly rows.
+func (r *gormRepository) RollupHourlyToDaily(from, to time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rows []healthAggregate
+		err := tx.Model(&HealthRollup{}).
+			Select("backend_id, endpoint_id, SUM(checks) AS checks, SUM(healthy_checks) AS healthy_checks, "+
+				"SUM(avg_latency * checks) / SUM(checks) AS avg_latency, MAX(max_latency) AS max_latency").
+			Where("granularity = ? AND bucket_start >= ? AND bucket_start < ?", RollupHourly, from, to).
+			Group("backend_id, endpoint_id").
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to aggregate hourly rollups: %w", err)
+		}
+		if err := createRollups(tx, rows, RollupDaily, from); err != nil {
+			return err
+		}
+		return tx.Where("granularity = ? AND bucket_start >= ? AND bucket_start < ?", RollupHourly, from, to).
+			Delete(&HealthRollup{}).Error
+	})
+}
+
+func createRollups(tx *gorm.DB, rows []healthAggregate, granularity string, bucketStart time.Time) error {
+	for _, row := range rows {
+		rollup := &HealthRollup{
+			BackendID:     row.BackendID,
+			EndpointID:    row.EndpointID,
+			Granularity:   granularity,
+			BucketStart:   bucketStart,
+			Checks:        row.Checks,
+			HealthyChecks: row.HealthyChecks,
+			AvgLatency:    int64(row.AvgLatency),
+			MaxLatency:    row.MaxLatency,
+		}
+		if row.Checks > 0 {
+			rollup.UptimePercent = float64(row.HealthyChecks) / float64(row.Checks) * 100
+		}
+		if err := tx.Create(rollup).Error; err != nil {
+			return fmt.Errorf("failed to save %s rollup: %w", granularity, err)
+		}
+	}
+	return nil
+}
+
+// DeleteHealthRollupsBefore removes rollups of the given granularity older than before.
+func (r *gormRepository) DeleteHealthRollupsBefore(granularity string, before time.Time) error {
+	return r.db.Where("granularity = ? AND bucket_start < ?", granularity, before).Delete(&HealthRollup{}).Error
 }
 
 func (r *gormRepository) UpdateEndpointHealth(configID string, endpointURL string, isHealthy bool) error {
@@ -110,3 +388,65 @@ func (r *gormRepository) UpdateEndpointHealth(configID string, endpointURL strin
 		// Update the health status
 		Update("is_healthy", isHealthy).Error
 }
+
+// UpdateEndpointTLS stores the certificate summary of one endpoint.
+func (r *gormRepository) UpdateEndpointTLS(endpointID uint, info *EndpointTLSInfo) error {
+	return r.db.Model(&BackendEndpoint{ID: endpointID}).
+		Select("TLS").
+		Updates(&BackendEndpoint{TLS: info}).Error
+}
+
+// UpdateEndpointAdminState stores the admin state of one endpoint.
+func (r *gormRepository) UpdateEndpointAdminState(endpointID uint, state string) error {
+	return r.db.Model(&BackendEndpoint{ID: endpointID}).Update("admin_state", state).Error
+}
+
+func (r *gormRepository) CreateIPBan(ban *IPBan) error {
+	return r.db.Create(ban).Error
+}
+
+// ListActiveIPBans returns bans that have not expired yet, soonest expiry first.
+func (r *gormRepository) ListActiveIPBans(now time.Time) ([]*IPBan, error) {
+	var bans []*IPBan
+	err := r.db.Where("expires_at > ?", now).Order("expires_at ASC").Find(&bans).Error
+	return bans, err
+}
+
+// DeleteIPBan lifts a ban; gorm.ErrRecordNotFound when it does not exist.
+func (r *gormRepository) DeleteIPBan(id uint) error {
+	result := r.db.Delete(&IPBan{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SaveErrorPage creates the page or replaces the one with the same backend and status.
+func (r *gormRepository) SaveErrorPage(page *ErrorPage) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "backend_id"}, {Name: "status"}},
+		DoUpdates: clause.AssignmentColumns([]string{"html", "json", "updated_at"}),
+	}).Create(page).Error
+}
+
+// ListErrorPages returns all custom error pages, gateway-wide ones first.
+func (r *gormRepository) ListErrorPages() ([]*ErrorPage, error) {
+	var pages []*ErrorPage
+	err := r.db.Order("backend_id ASC, status ASC").Find(&pages).Error
+	return pages, err
+}
+
+// DeleteErrorPage removes a page; gorm.ErrRecordNotFound when it does not exist.
+func (r *gormRepository) DeleteErrorPage(id uint) error {
+	result := r.db.Delete(&ErrorPage{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package gatewayio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"imanager.io/utils"
+)
+
+// DefaultSLOTarget is the availability objective (percent) for routes without their own SLOTarget.
+const DefaultSLOTarget = 99.9
+
+// ReportQueryDTO selects the window and routes for an uptime report.
+type ReportQueryDTO struct {
+	BackendID string    `json:"backendId"` // empty reports on every route
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	SLOTarget float64   `json:"sloTarget"` // overrides each route's target when > 0
+}
+
+// Incident is a contiguous period during which an endpoint, or a whole route, was down.
+type Incident struct {
+	EndpointID      uint      `json:"endpointId"` // 0 when every endpoint of the route was down
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Reason          string    `json:"reason,omitempty"`
+	Ongoing         bool      `json:"ongoing"`
+}
+
+// ErrorBudget compares downtime in the window with what the SLO allows.
+type ErrorBudget struct {
+	AllowedDowntimeSeconds float64 `json:"allowedDowntimeSeconds"`
+	ConsumedSeconds        float64 `json:"consumedSeconds"`
+	RemainingSeconds       float64 `json:"remainingSeconds"` // negative once the budget is exhausted
+	RemainingPercent       float64 `json:"remainingPercent"`
+}
+
+// EndpointAvailability summarises one BackendEndpoint over the report window.
+type EndpointAvailability struct {
+	EndpointID          uint       `json:"endpointId"`
+	URL                 string     `json:"url"`
+	Checks              int64      `json:"checks"`
+	HealthyChecks       int64      `json:"healthyChecks"`
+	AvailabilityPercent float64    `json:"availabilityPercent"`
+	DowntimeSeconds     float64    `json:"downtimeSeconds"`
+	AvgLatencyMs        float64    `json:"avgLatencyMs"`
+	MaxLatencyMs        float64    `json:"maxLatencyMs"`
+	Incidents           []Incident `json:"incidents"`
+}
+
+// BackendAvailability summarises one BackendConfig. The route counts as down
+// only while all of its endpoints are down.
+type BackendAvailability struct {
+	BackendID           string                  `json:"backendId"`
+	PathPrefix          string                  `json:"pathPrefix"`
+	SLOTarget           float64                 `json:"sloTarget"`
+	AvailabilityPercent float64                 `json:"availabilityPercent"`
+	DowntimeSeconds     float64                 `json:"downtimeSeconds"`
+	MeetsSLO            bool                    `json:"meetsSlo"`
+	ErrorBudget         ErrorBudget             `json:"errorBudget"`
+	Requests            int64                   `json:"requests"`
+	ServerErrors        int64                   `json:"serverErrors"`
+	ErrorRatePercent    float64                 `json:"errorRatePercent"`
+	Endpoints           []*EndpointAvailability `json:"endpoints"`
+	Incidents           []Incident              `json:"incidents"`
+}
+
+// UptimeReport is the response of the uptime/SLA report endpoint.
+type UptimeReport struct {
+	StartTime   time.Time              `json:"startTime"`
+	EndTime     time.Time              `json:"endTime"`
+	GeneratedAt time.Time              `json:"generatedAt"`
+	Backends    []*BackendAvailability `json:"backends"`
+}
+
+// UptimeReport computes availability for each matching route over the query window.
+// Incidents are derived from raw probes; periods already rolled up by the
+// retention job only contribute aggregate downtime.
+func (s *backendService) UptimeReport(query *ReportQueryDTO) (*UptimeReport, error) {
+	if !query.EndTime.After(query.StartTime) {
+		return nil, utils.NewServiceError(nil, "endTime must be after startTime", http.StatusBadRequest)
+	}
+
+	configs := s.GetRuntimeConfigs()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].PathPrefix < configs[j].PathPrefix })
+
+	report := &UptimeReport{
+		StartTime:   query.StartTime,
+		EndTime:     query.EndTime,
+		GeneratedAt: time.Now(),
+		Backends:    make([]*BackendAvailability, 0, len(configs)),
+	}
+	for _, cfg := range configs {
+		if query.BackendID != "" && cfg.ID != query.BackendID {
+			continue
+		}
+		availability, err := s.backendAvailability(cfg, query)
+		if err != nil {
+			return nil, utils.NewServiceError(err, "Failed to build uptime report", http.StatusInternalServerError)
+		}
+		report.Backends = append(report.Backends, availability)
+	}
+
+	if query.BackendID != "" && len(report.Backends) == 0 {
+		return nil, utils.NewServiceError(nil, "backend not found: "+query.BackendID, http.StatusNotFound)
+	}
+	return report, nil
+}
+
+func (s *backendService) backendAvailability(cfg *BackendConfig, query *ReportQueryDTO) (*BackendAvailability, error) {
+	start, end := query.StartTime, query.EndTime
+	window := end.Sub(start).Seconds()
+	ongoingCutoff := time.Now().Add(-time.Minute)
+
+	probes, err := s.repo.ListHealthHistory(cfg.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var rollups []*HealthRollup
+	for _, granularity := range []string{RollupHourly, RollupDaily} {
+		rows, err := s.repo.GetHealthRollups(&HistoryQueryDTO{
+			BackendID:   cfg.ID,
+			StartTime:   start,
+			EndTime:     end,
+			Granularity: granularity,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if row.BucketStart.Before(end) {
+				rollups = append(rollups, row)
+			}
+		}
+	}
+
+	// 1. Per-endpoint counters, seeded from the current config.
+	endpoints := make(map[uint]*EndpointAvailability)
+	latencySum := make(map[uint]float64)
+	endpointFor := func(id uint) *EndpointAvailability {
+		ea, ok := endpoints[id]
+		if !ok {
+			ea = &EndpointAvailability{EndpointID: id, Incidents: []Incident{}}
+			endpoints[id] = ea
+		}
+		return ea
+	}
+	for _, ep := range cfg.Endpoints {
+		endpointFor(ep.ID).URL = ep.URL
+	}
+
+	probesByEndpoint := make(map[uint][]*HealthHistory)
+	for _, p := range probes {
+		ea := endpointFor(p.EndpointID)
+		ea.Checks++
+		if p.IsHealthy {
+			ea.HealthyChecks++
+		}
+		latencySum[p.EndpointID] += float64(p.Latency)
+		ea.MaxLatencyMs = maxFloat(ea.MaxLatencyMs, nsToMs(p.Latency))
+		probesByEndpoint[p.EndpointID] = append(probesByEndpoint[p.EndpointID], p)
+	}
+
+	// Route-level downtime in rolled-up buckets assumes endpoint outages overlap,
+	// so the best endpoint of each bucket bounds the route's downtime.
+	type bucketKey struct {
+		granularity string
+		start       time.Time
+	}
+	bestBucketUptime := make(map[bucketKey]float64)
+	for _, r := range rollups {
+		ea := endpointFor(r.EndpointID)
+		ea.Checks += r.Checks
+		ea.HealthyChecks += r.HealthyChecks
+		latencySum[r.EndpointID] += float64(r.AvgLatency) * float64(r.Checks)
+		ea.MaxLatencyMs = maxFloat(ea.MaxLatencyMs, nsToMs(r.MaxLatency))
+
+		if r.Checks == 0 {
+			continue
+		}
+		uptime := float64(r.HealthyChecks) / float64(r.Checks)
+		ea.DowntimeSeconds += (1 - uptime) * rollupDuration(r.Granularity).Seconds()
+		key := bucketKey{r.Granularity, r.BucketStart}
+		bestBucketUptime[key] = maxFloat(bestBucketUptime[key], uptime)
+	}
+
+	// 2. Endpoint incidents from raw probes.
+	var incidentSets [][]Incident
+	for id, ps := range probesByEndpoint {
+		incidents := probeIncidents(id, ps, end, ongoingCutoff)
+		ea := endpointFor(id)
+		ea.Incidents = incidents
+		for _, inc := range incidents {
+			ea.DowntimeSeconds += inc.DurationSeconds
+		}
+		incidentSets = append(incidentSets, incidents)
+	}
+
+	availability := &BackendAvailability{
+		BackendID:  cfg.ID,
+		PathPrefix: cfg.PathPrefix,
+		SLOTarget:  cfg.SLOTarget,
+		Endpoints:  make([]*EndpointAvailability, 0, len(endpoints)),
+		Incidents:  intersectIncidents(incidentSets),
+	}
+	if query.SLOTarget > 0 {
+		availability.SLOTarget = query.SLOTarget
+	}
+	if availability.SLOTarget <= 0 {
+		availability.SLOTarget = DefaultSLOTarget
+	}
+
+	for id, ea := range endpoints {
+		if ea.Checks > 0 {
+			ea.AvgLatencyMs = latencySum[id] / float64(ea.Checks) / float64(time.Millisecond)
+		}
+		ea.AvailabilityPercent = availabilityPercent(ea.DowntimeSeconds, window)
+		availability.Endpoints = append(availability.Endpoints, ea)
+	}
+	sort.Slice(availability.Endpoints, func(i, j int) bool {
+		return availability.Endpoints[i].EndpointID < availability.Endpoints[j].EndpointID
+	})
+
+	// 3. Route availability and error budget.
+	for _, inc := range availability.Incidents {
+		availability.DowntimeSeconds += inc.DurationSeconds
+	}
+	for key, uptime := range bestBucketUptime {
+		availability.DowntimeSeconds += (1 - uptime) * rollupDuration(key.granularity).Seconds()
+	}
+	availability.AvailabilityPercent = availabilityPercent(availability.DowntimeSeconds, window)
+	availability.MeetsSLO = availability.AvailabilityPercent >= availability.SLOTarget
+
+	allowed := (1 - availability.SLOTarget/100) * window
+	availability.ErrorBudget = ErrorBudget{
+		AllowedDowntimeSeconds: allowed,
+		ConsumedSeconds:        availability.DowntimeSeconds,
+		RemainingSeconds:       allowed - availability.DowntimeSeconds,
+	}
+	if allowed > 0 {
+		availability.ErrorBudget.RemainingPercent = availability.ErrorBudget.RemainingSeconds / allowed * 100
+	}
+
+	// 4. Request error rate from access logs.
+	total, serverErrors, err := s.repo.CountAccessLogs(cfg.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	availability.Requests = total
+	availability.ServerErrors = serverErrors
+	if total > 0 {
+		availability.ErrorRatePercent = float64(serverErrors) / float64(total) * 100
+	}
+
+	return availability, nil
+}
+
+// probeIncidents turns an ordered probe series into down periods. A period
+// starts at the first failed probe and ends at the next successful one.
+func probeIncidents(endpointID uint, probes []*HealthHistory, windowEnd, ongoingCutoff time.Time) []Incident {
+	incidents := []Incident{}
+	var current *Incident
+	for _, p := range probes {
+		if !p.IsHealthy {
+			if current == nil {
+				current = &Incident{EndpointID: endpointID, Start: p.CheckedAt, Reason: p.FailureReason}
+			}
+			continue
+		}
+		if current != nil {
+			current.End = p.CheckedAt
+			current.DurationSeconds = current.End.Sub(current.Start).Seconds()
+			incidents = append(incidents, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		current.End = windowEnd
+		current.Ongoing = !windowEnd.Before(ongoingCutoff)
+		if current.Ongoing {
+			current.End = time.Now()
+		}
+		current.DurationSeconds = current.End.Sub(current.Start).Seconds()
+		incidents = append(incidents, *current)
+	}
+	return incidents
+}
+
+// intersectIncidents returns the periods during which every set was down at once.
+func intersectIncidents(sets [][]Incident) []Incident {
+	if len(sets) == 0 {
+		return []Incident{}
+	}
+	result := append([]Incident(nil), sets[0]...)
+	for _, set := range sets[1:] {
+		var next []Incident
+		i, j := 0, 0
+		for i < len(result) && j < len(set) {
+			a, b := result[i], set[j]
+			start, end := a.Start, a.End
+			if b.Start.After(start) {
+				start = b.Start
+			}
+			if b.End.Before(end) {
+				end = b.End
+			}
+			if start.Before(end) {
+				next = append(next, Incident{Start: start, End: end, Ongoing: a.Ongoing && b.Ongoing})
+			}
+			if a.End.Before(b.End) {
+				i++
+			} else {
+				j++
+			}
+		}
+		result = next
+	}
+
+	incidents := make([]Incident, 0, len(result))
+	for _, inc := range result {
+		inc.EndpointID = 0
+		inc.Reason = "all endpoints down"
+		inc.DurationSeconds = inc.End.Sub(inc.Start).Seconds()
+		incidents = append(incidents, inc)
+	}
+	return incidents
+}
+
+func rollupDuration(granularity string) time.Duration {
+	if granularity == RollupDaily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+func availabilityPercent(downtimeSeconds, windowSeconds float64) float64 {
+	if windowSeconds <= 0 {
+		return 100
+	}
+	pct := (1 - downtimeSeconds/windowSeconds) * 100
+	if pct < 0 {
+		return 0
+	}
+	return pct
+}
+
+func nsToMs(ns int64) float64 {
+	return float64(ns) / float64(time.Millisecond)
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// WriteCSV renders one row per route and one per endpoint.
+func (r *UptimeReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"backend_id", "path_prefix", "endpoint_id", "url", "availability_percent", "downtime_seconds",
+		"slo_target", "error_budget_remaining_seconds", "requests", "server_errors", "incidents",
+	})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, b := range r.Backends {
+		cw.Write([]string{
+			b.BackendID, b.PathPrefix, "", "", f(b.AvailabilityPercent), f(b.DowntimeSeconds),
+			f(b.SLOTarget), f(b.ErrorBudget.RemainingSeconds),
+			strconv.FormatInt(b.Requests, 10), strconv.FormatInt(b.ServerErrors, 10), strconv.Itoa(len(b.Incidents)),
+		})
+		for _, ep := range b.Endpoints {
+			cw.Write([]string{
+				b.BackendID, b.PathPrefix, strconv.FormatUint(uint64(ep.EndpointID), 10), ep.URL,
+				f(ep.AvailabilityPercent), f(ep.DowntimeSeconds), "", "", "", "", strconv.Itoa(len(ep.Incidents)),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var uptimeReportTemplate = template.Must(template.New("uptime").Funcs(template.FuncMap{
+	"pct":  func(v float64) string { return fmt.Sprintf("%.3f%%", v) },
+	"secs": func(v float64) string { return (time.Duration(v) * time.Second).String() },
+	"ts":   func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Uptime report</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse;margin-bottom:1em}td,th{border:1px solid #ccc;padding:4px 8px}.bad{color:#b00}</style>
+</head><body>
+<h1>Uptime report</h1>
+<p>{{ts .StartTime}} &ndash; {{ts .EndTime}}</p>
+{{range .Backends}}
+<h2>{{.PathPrefix}} <small>{{.BackendID}}</small></h2>
+<table>
+<tr><th>Availability</th><th>SLO</th><th>Downtime</th><th>Budget remaining</th><th>Requests</th><th>5xx rate</th></tr>
+<tr><td{{if not .MeetsSLO}} class="bad"{{end}}>{{pct .AvailabilityPercent}}</td><td>{{pct .SLOTarget}}</td><td>{{secs .DowntimeSeconds}}</td><td>{{secs .ErrorBudget.RemainingSeconds}}</td><td>{{.Requests}}</td><td>{{pct .ErrorRatePercent}}</td></tr>
+</table>
+<table>
+<tr><th>Endpoint</th><th>URL</th><th>Availability</th><th>Downtime</th><th>Avg latency (ms)</th><th>Incidents</th></tr>
+{{range .Endpoints}}<tr><td>{{.EndpointID}}</td><td>{{.URL}}</td><td>{{pct .AvailabilityPercent}}</td><td>{{secs .DowntimeSeconds}}</td><td>{{printf "%.1f" .AvgLatencyMs}}</td><td>{{len .Incidents}}</td></tr>
+{{end}}</table>
+{{if .Incidents}}<table>
+<tr><th>Route down from</th><th>Until</th><th>Duration</th></tr>
+{{range .Incidents}}<tr><td>{{ts .Start}}</td><td>{{if .Ongoing}}ongoing{{else}}{{ts .End}}{{end}}</td><td>{{secs .DurationSeconds}}</td></tr>
+{{end}}</table>{{end}}
+{{end}}
+</body></html>
+`))
+
+// WriteHTML renders a standalone HTML page of the report.
+func (r *UptimeReport) WriteHTML(w io.Writer) error {
+	return uptimeReportTemplate.Execute(w, r)
+}
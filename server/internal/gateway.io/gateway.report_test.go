@@ -0,0 +1,176 @@
+package gatewayio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reportStart = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func reportAt(minutes int) time.Time {
+	return reportStart.Add(time.Duration(minutes) * time.Minute)
+}
+
+func probesFrom(endpointID uint, states string) []*HealthHistory {
+	var probes []*HealthHistory
+	for i, s := range states {
+		probes = append(probes, &HealthHistory{EndpointID: endpointID, CheckedAt: reportAt(i * 10), IsHealthy: s == 'U', Latency: int64(10 * time.Millisecond)})
+	}
+	return probes
+}
+
+func TestProbeIncidents(t *testing.T) {
+	end := reportAt(100)
+	tests := []struct {
+		states  string
+		periods [][2]int // minutes
+	}{
+		{"UUUU", nil},
+		{"UDDU", [][2]int{{10, 30}}},
+		{"DUDU", [][2]int{{0, 10}, {20, 30}}},
+		{"UUDD", [][2]int{{20, 100}}},
+	}
+	for _, tt := range tests {
+		incidents := probeIncidents(1, probesFrom(1, tt.states), end, end.Add(time.Hour))
+		if len(incidents) != len(tt.periods) {
+			t.Errorf("%s: %d incidents, want %d", tt.states, len(incidents), len(tt.periods))
+			continue
+		}
+		for i, p := range tt.periods {
+			inc := incidents[i]
+			if !inc.Start.Equal(reportAt(p[0])) || !inc.End.Equal(reportAt(p[1])) || inc.Ongoing || inc.DurationSeconds != float64((p[1]-p[0])*60) {
+				t.Errorf("%s: incident %d = %+v, want %v", tt.states, i, inc, p)
+			}
+		}
+	}
+
+	// An outage still open at the end of a window reaching now is ongoing.
+	incidents := probeIncidents(1, probesFrom(1, "UD"), time.Now(), time.Now().Add(-time.Minute))
+	if len(incidents) != 1 || !incidents[0].Ongoing {
+		t.Errorf("open outage = %+v, want ongoing", incidents)
+	}
+}
+
+func TestIntersectIncidents(t *testing.T) {
+	span := func(from, to int) Incident { return Incident{Start: reportAt(from), End: reportAt(to)} }
+	got := intersectIncidents([][]Incident{
+		{span(0, 30), span(50, 80)},
+		{span(10, 60), span(70, 90)},
+		{span(0, 100)},
+	})
+	want := []Incident{span(10, 30), span(50, 60), span(70, 80)}
+	if len(got) != len(want) {
+		t.Fatalf("intersection = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) || got[i].Reason != "all endpoints down" {
+			t.Errorf("period %d = %+v, want %v-%v", i, got[i], want[i].Start, want[i].End)
+		}
+	}
+	if got := intersectIncidents([][]Incident{{span(0, 10)}, {}}); len(got) != 0 {
+		t.Errorf("an endpoint that never failed still produced %+v", got)
+	}
+	if got := intersectIncidents(nil); got == nil || len(got) != 0 {
+		t.Errorf("no endpoints = %#v, want an empty list", got)
+	}
+}
+
+func TestAvailabilityPercent(t *testing.T) {
+	tests := []struct{ down, window, want float64 }{
+		{0, 3600, 100},
+		{36, 3600, 99},
+		{7200, 3600, 0},
+		{10, 0, 100},
+	}
+	for _, tt := range tests {
+		if got := availabilityPercent(tt.down, tt.window); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("availabilityPercent(%v, %v) = %v, want %v", tt.down, tt.window, got, tt.want)
+		}
+	}
+}
+
+// reportRepo fakes the queries the uptime report makes.
+type reportRepo struct {
+	BackendRepository
+	probes              []*HealthHistory
+	rollups             []*HealthRollup
+	total, serverErrors int64
+}
+
+func (r *reportRepo) ListHealthHistory(string, time.Time, time.Time) ([]*HealthHistory, error) {
+	return r.probes, nil
+}
+
+func (r *reportRepo) GetHealthRollups(q *HistoryQueryDTO) ([]*HealthRollup, error) {
+	var rows []*HealthRollup
+	for _, row := range r.rollups {
+		if row.Granularity == q.Granularity {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (r *reportRepo) CountAccessLogs(string, time.Time, time.Time) (int64, int64, error) {
+	return r.total, r.serverErrors, nil
+}
+
+func TestUptimeReport(t *testing.T) {
+	// Two endpoints over 100 minutes: both down 20-30, endpoint 2 also down 50-60,
+	// plus an hourly rollup bucket in which the better endpoint was up 90%.
+	repo := &reportRepo{
+		probes: append(probesFrom(1, "UUDUUUUUUU"), probesFrom(2, "UUDUUDUUUU")...),
+		rollups: []*HealthRollup{
+			{EndpointID: 1, Granularity: RollupHourly, BucketStart: reportStart.Add(-time.Hour), Checks: 10, HealthyChecks: 9},
+			{EndpointID: 2, Granularity: RollupHourly, BucketStart: reportStart.Add(-time.Hour), Checks: 10, HealthyChecks: 5},
+		},
+		total: 200, serverErrors: 3,
+	}
+	s := &backendService{repo: repo, runtimeCache: map[string]*BackendConfig{
+		"b1": {ID: "b1", PathPrefix: "/api", SLOTarget: 99, Endpoints: []*BackendEndpoint{{ID: 1, URL: "http://a"}, {ID: 2, URL: "http://b"}, {ID: 3, URL: "http://c"}}},
+	}}
+	report, err := s.UptimeReport(&ReportQueryDTO{BackendID: "b1", StartTime: reportStart, EndTime: reportAt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := report.Backends[0]
+	if len(b.Endpoints) != 3 || b.Endpoints[2].Checks != 0 || b.Endpoints[2].AvailabilityPercent != 100 {
+		t.Errorf("endpoints = %+v", b.Endpoints)
+	}
+	if len(b.Incidents) != 1 || !b.Incidents[0].Start.Equal(reportAt(20)) || !b.Incidents[0].End.Equal(reportAt(30)) {
+		t.Errorf("route incidents = %+v, want 20-30 only", b.Incidents)
+	}
+	if want := 600.0 + 0.1*3600; math.Abs(b.DowntimeSeconds-want) > 1e-6 {
+		t.Errorf("route downtime = %v, want %v", b.DowntimeSeconds, want)
+	}
+	if want := 600.0 + 600 + 0.5*3600; math.Abs(b.Endpoints[1].DowntimeSeconds-want) > 1e-6 {
+		t.Errorf("endpoint 2 downtime = %v, want %v", b.Endpoints[1].DowntimeSeconds, want)
+	}
+	if b.MeetsSLO || b.ErrorBudget.RemainingSeconds >= 0 || b.ErrorRatePercent != 1.5 {
+		t.Errorf("SLO %t, budget %+v, error rate %v", b.MeetsSLO, b.ErrorBudget, b.ErrorRatePercent)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 5 || rows[1][0] != "b1" || rows[2][2] != "1" {
+		t.Errorf("csv rows = %v, %v", rows, err)
+	}
+	buf.Reset()
+	if err := report.WriteHTML(&buf); err != nil || !strings.Contains(buf.String(), "/api") {
+		t.Errorf("html report: %v", err)
+	}
+
+	if _, err := s.UptimeReport(&ReportQueryDTO{BackendID: "missing", StartTime: reportStart, EndTime: reportAt(1)}); err == nil {
+		t.Error("report for an unknown backend succeeded")
+	}
+	if _, err := s.UptimeReport(&ReportQueryDTO{StartTime: reportAt(1), EndTime: reportStart}); err == nil {
+		t.Error("report with an inverted window succeeded")
+	}
+}
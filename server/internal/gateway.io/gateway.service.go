@@ -2,13 +2,20 @@
 package gatewayio
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"imanager.io/internal/alerting"
+	"imanager.io/internal/eventbus"
+	"imanager.io/utils"
 )
 
 // NOTE: Assuming BackendConfig, BackendConfigDTO, BackendRepository, and Gateway are defined elsewhere in the gatewayio package.
@@ -16,19 +23,33 @@ import (
 // BackendService defines the service methods.
 type BackendService interface {
 	Create(dto *BackendConfigDTO) (*BackendConfig, error)
+	Update(id string, dto *BackendConfigDTO) (*BackendConfig, error)
+	Delete(id string) error
 	GetAll() ([]*BackendConfig, error)
 	GetRuntimeConfigs() []*BackendConfig
 	//SetHealthStatus(id string, isHealthy bool, letency time.Duration)
 	GetHistory(query *HistoryQueryDTO) ([]*HealthHistory, error)
-	SetHealthStatus(configID string, endpointURL string, isHealthy bool, latency time.Duration)
-	RecordAccessLog(
-		backendID string,
-		latency time.Duration,
-		method string,
-		path string,
-		clientIP string,
-		statusCode int,
-	) error
+	GetHistoryRollups(query *HistoryQueryDTO) ([]*HealthRollup, error)
+	UptimeReport(query *ReportQueryDTO) (*UptimeReport, error)
+	SetHealthStatus(configID string, endpointURL string, isHealthy bool, latency time.Duration, statusCode int, reason string)
+	SetEndpointTLS(configID string, endpointURL string, info *EndpointTLSInfo)
+	RecordAccessLog(logEntry *AccessLog) error
+	RecordConnectionLog(logEntry *ConnectionLog) error
+	GetConnectionLogs(backendID string, limit int) ([]*ConnectionLog, error)
+	RecordMirrorLog(logEntry *MirrorLog) error
+	GetMirrorLogs(backendID string, limit int) ([]*MirrorLog, error)
+	GetMirrorSummary(backendID string, since time.Time) (*MirrorSummary, error)
+	CreateIPBan(dto *IPBanDTO) (*IPBan, error)
+	ListIPBans() ([]*IPBan, error)
+	DeleteIPBan(id uint) error
+	SetMaintenance(id string, policy *MaintenancePolicy) (*BackendConfig, error)
+	SetWaitingRoom(id string, policy *WaitingRoomPolicy) (*BackendConfig, error)
+	GetWaitingRoom(id string) (*WaitingRoomStats, error)
+	SetEndpointState(id string, endpointID uint, dto *EndpointStateDTO) (*EndpointDrainStatus, error)
+	GetEndpointDrain(id string, endpointID uint) (*EndpointDrainStatus, error)
+	SaveErrorPage(dto *ErrorPageDTO) (*ErrorPage, error)
+	ListErrorPages() ([]*ErrorPage, error)
+	DeleteErrorPage(id uint) error
 }
 
 // backendService implements the core business logic.
@@ -47,32 +68,155 @@ func NewBackendService(repo BackendRepository, gateway *Gateway) BackendService
 		runtimeCache: make(map[string]*BackendConfig),
 	}
 	s.loadCacheFromRepo()
+	if err := s.reloadIPBans(); err != nil {
+		log.Printf("ERROR loading IP bans from DB: %v", err)
+	}
+	if err := s.reloadErrorPages(); err != nil {
+		log.Printf("ERROR loading error pages from DB: %v", err)
+	}
 	return s
 }
-func (s *backendService) RecordAccessLog(
-	backendID string,
-	latency time.Duration,
-	method string,
-	path string,
-	clientIP string,
-	statusCode int,
-) error {
 
-	// Convert time.Duration to int64 (nanoseconds) for GORM storage
-	latencyNs := latency.Nanoseconds()
+// RecordAccessLog persists one gateway request; ID and Timestamp are set by GORM.
+func (s *backendService) RecordAccessLog(logEntry *AccessLog) error {
+	return s.repo.CreateAccessLog(logEntry)
+}
 
-	logEntry := &AccessLog{
-		BackendID:  backendID,
-		Latency:    latencyNs, // Store as int64
-		Method:     method,
-		Path:       path,
-		ClientIP:   clientIP,
-		StatusCode: statusCode,
-		// ID and Timestamp will be handled by GORM on creation
+// RecordConnectionLog persists one finished TCP connection or UDP session.
+func (s *backendService) RecordConnectionLog(logEntry *ConnectionLog) error {
+	return s.repo.CreateConnectionLog(logEntry)
+}
+
+// GetConnectionLogs returns the newest connection logs of an L4 backend.
+func (s *backendService) GetConnectionLogs(backendID string, limit int) ([]*ConnectionLog, error) {
+	return s.repo.ListConnectionLogs(backendID, limit)
+}
+
+// RecordMirrorLog persists the outcome of one shadow request.
+func (s *backendService) RecordMirrorLog(logEntry *MirrorLog) error {
+	return s.repo.CreateMirrorLog(logEntry)
+}
+
+// GetMirrorLogs returns the newest shadow results of a backend.
+func (s *backendService) GetMirrorLogs(backendID string, limit int) ([]*MirrorLog, error) {
+	return s.repo.ListMirrorLogs(backendID, limit)
+}
+
+// GetMirrorSummary compares shadow and production results since the given time.
+func (s *backendService) GetMirrorSummary(backendID string, since time.Time) (*MirrorSummary, error) {
+	return s.repo.SummarizeMirrorLogs(backendID, since)
+}
+
+// CreateIPBan blocks an address or CIDR for dto.DurationSeconds, on one route or globally.
+func (s *backendService) CreateIPBan(dto *IPBanDTO) (*IPBan, error) {
+	prefixes, err := parsePrefixes([]string{dto.IP})
+	if err != nil {
+		return nil, utils.NewServiceError(err, err.Error(), http.StatusBadRequest)
+	}
+	if dto.BackendID != "" {
+		s.mu.RLock()
+		_, ok := s.runtimeCache[dto.BackendID]
+		s.mu.RUnlock()
+		if !ok {
+			return nil, utils.NewServiceError(nil, "backend not found: "+dto.BackendID, http.StatusNotFound)
+		}
 	}
+	ban := &IPBan{
+		CIDR:      prefixes[0].String(),
+		BackendID: dto.BackendID,
+		Reason:    dto.Reason,
+		ExpiresAt: time.Now().Add(time.Duration(dto.DurationSeconds) * time.Second),
+	}
+	if err := s.repo.CreateIPBan(ban); err != nil {
+		return nil, err
+	}
+	if err := s.reloadIPBans(); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Banned %s on %s until %s", ban.CIDR, displayScope(ban.BackendID), ban.ExpiresAt.Format(time.RFC3339))
+	return ban, nil
+}
 
-	// Call the repository to save the record
-	return s.repo.CreateAccessLog(logEntry)
+// ListIPBans returns the bans that are still in force.
+func (s *backendService) ListIPBans() ([]*IPBan, error) {
+	return s.repo.ListActiveIPBans(time.Now())
+}
+
+// DeleteIPBan lifts a ban before it expires.
+func (s *backendService) DeleteIPBan(id uint) error {
+	if err := s.repo.DeleteIPBan(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewServiceError(err, fmt.Sprintf("ip ban %d not found", id), http.StatusNotFound)
+		}
+		return err
+	}
+	return s.reloadIPBans()
+}
+
+func (s *backendService) reloadIPBans() error {
+	bans, err := s.repo.ListActiveIPBans(time.Now())
+	if err != nil {
+		return err
+	}
+	s.gateway.ReloadIPBans(bans)
+	return nil
+}
+
+// SaveErrorPage creates or replaces the page for dto.Status on one route or gateway-wide.
+func (s *backendService) SaveErrorPage(dto *ErrorPageDTO) (*ErrorPage, error) {
+	if err := dto.validate(); err != nil {
+		return nil, utils.NewServiceError(err, err.Error(), http.StatusBadRequest)
+	}
+	if dto.BackendID != "" {
+		s.mu.RLock()
+		_, ok := s.runtimeCache[dto.BackendID]
+		s.mu.RUnlock()
+		if !ok {
+			return nil, utils.NewServiceError(nil, "backend not found: "+dto.BackendID, http.StatusNotFound)
+		}
+	}
+	page := &ErrorPage{BackendID: dto.BackendID, Status: dto.Status, HTML: dto.HTML, JSON: dto.JSON}
+	if err := s.repo.SaveErrorPage(page); err != nil {
+		return nil, err
+	}
+	if err := s.reloadErrorPages(); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Saved %d error page on %s", page.Status, displayScope(page.BackendID))
+	return page, nil
+}
+
+// ListErrorPages returns every custom error page.
+func (s *backendService) ListErrorPages() ([]*ErrorPage, error) {
+	return s.repo.ListErrorPages()
+}
+
+// DeleteErrorPage restores the default body for the page's status and scope.
+func (s *backendService) DeleteErrorPage(id uint) error {
+	if err := s.repo.DeleteErrorPage(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewServiceError(err, fmt.Sprintf("error page %d not found", id), http.StatusNotFound)
+		}
+		return err
+	}
+	return s.reloadErrorPages()
+}
+
+func (s *backendService) reloadErrorPages() error {
+	pages, err := s.repo.ListErrorPages()
+	if err != nil {
+		return err
+	}
+	s.gateway.ReloadErrorPages(pages)
+	return nil
+}
+
+// displayScope names what a ban or error page applies to in log lines.
+func displayScope(backendID string) string {
+	if backendID == "" {
+		return "all routes"
+	}
+	return "backend " + backendID
 }
 
 // gateway.service.go (inside loadCacheFromRepo)
@@ -123,56 +267,412 @@ func (s *backendService) loadCacheFromRepo() {
 
 func (s *backendService) Create(dto *BackendConfigDTO) (*BackendConfig, error) {
 	// Generate ID before DB call
-	newID := uuid.New().String()
+	newConfig, err := s.buildConfig(uuid.New().String(), dto, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Persist to DB (Repo must handle saving Config AND associated Endpoints)
+	if err := s.repo.Create(newConfig); err != nil {
+		return nil, fmt.Errorf("failed to save config and endpoints to DB: %w", err)
+	}
+
+	// 2. Update Cache & reload the gateway.
+	s.storeAndReload(newConfig)
+	return newConfig, nil
+}
+
+// Update replaces a configuration. Endpoints whose URL is unchanged keep their
+// ID and health state; removed ones are deleted, new ones start DOWN.
+func (s *backendService) Update(id string, dto *BackendConfigDTO) (*BackendConfig, error) {
+	s.mu.RLock()
+	existing, ok := s.runtimeCache[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, utils.NewServiceError(nil, "backend not found: "+id, http.StatusNotFound)
+	}
+
+	updated, err := s.buildConfig(id, dto, existing)
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[uint]bool, len(updated.Endpoints))
+	for _, ep := range updated.Endpoints {
+		kept[ep.ID] = true
+	}
+	var removed []uint
+	for _, ep := range existing.Endpoints {
+		if !kept[ep.ID] {
+			removed = append(removed, ep.ID)
+		}
+	}
+
+	if err := s.repo.Update(updated, removed); err != nil {
+		return nil, fmt.Errorf("failed to update config %s: %w", id, err)
+	}
+	s.storeAndReload(updated)
+	return updated, nil
+}
+
+// SetMaintenance replaces only the maintenance policy of a route. A policy that
+// is neither enabled nor scheduled clears it.
+func (s *backendService) SetMaintenance(id string, policy *MaintenancePolicy) (*BackendConfig, error) {
+	if policy != nil && !policy.Enabled && len(policy.Windows) == 0 {
+		policy = nil
+	}
+	return s.updateOne(id, func(dto *BackendConfigDTO) { dto.Maintenance = policy })
+}
+
+// SetWaitingRoom replaces only the waiting room policy of a route; nil removes
+// the room. Queued visitors keep their place when limits change.
+func (s *backendService) SetWaitingRoom(id string, policy *WaitingRoomPolicy) (*BackendConfig, error) {
+	return s.updateOne(id, func(dto *BackendConfigDTO) { dto.WaitingRoom = policy })
+}
+
+// GetWaitingRoom reports the live queue of a route's waiting room.
+func (s *backendService) GetWaitingRoom(id string) (*WaitingRoomStats, error) {
+	s.mu.RLock()
+	_, ok := s.runtimeCache[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, utils.NewServiceError(nil, "backend not found: "+id, http.StatusNotFound)
+	}
+	stats := s.gateway.WaitingRoomStats(id)
+	if stats == nil {
+		return nil, utils.NewServiceError(nil, "backend has no waiting room: "+id, http.StatusNotFound)
+	}
+	return stats, nil
+}
+
+// SetEndpointState takes an endpoint out of rotation or puts it back. A
+// draining endpoint keeps its requests and WebSockets until they finish or the
+// drain timeout passes; a reactivated one ramps up when the route has slow start.
+func (s *backendService) SetEndpointState(id string, endpointID uint, dto *EndpointStateDTO) (*EndpointDrainStatus, error) {
+	cfg, endpoint, err := s.findEndpoint(id, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateEndpointAdminState(endpointID, dto.State); err != nil {
+		return nil, fmt.Errorf("failed to update endpoint %d: %w", endpointID, err)
+	}
+	if previous := cfg.setAdminState(endpoint, dto.State); previous != dto.State {
+		log.Printf("INFO: Endpoint %s (Config %s) admin state %s -> %s", endpoint.URL, id, previous, dto.State)
+		s.gateway.ApplyEndpointState(id, endpoint, dto.State, dto.drainTimeout())
+	}
+	return s.gateway.EndpointDrainStatus(id, endpoint, dto.State), nil
+}
+
+// GetEndpointDrain reports whether an endpoint has finished draining.
+func (s *backendService) GetEndpointDrain(id string, endpointID uint) (*EndpointDrainStatus, error) {
+	cfg, endpoint, err := s.findEndpoint(id, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	cfg.mu.RLock()
+	state := endpoint.adminState()
+	cfg.mu.RUnlock()
+	return s.gateway.EndpointDrainStatus(id, endpoint, state), nil
+}
+
+// findEndpoint looks up an endpoint of a route in the runtime cache.
+func (s *backendService) findEndpoint(id string, endpointID uint) (*BackendConfig, *BackendEndpoint, error) {
+	s.mu.RLock()
+	cfg, ok := s.runtimeCache[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, utils.NewServiceError(nil, "backend not found: "+id, http.StatusNotFound)
+	}
+	for _, ep := range cfg.Endpoints {
+		if ep.ID == endpointID {
+			return cfg, ep, nil
+		}
+	}
+	return nil, nil, utils.NewServiceError(nil, fmt.Sprintf("endpoint %d not found on backend %s", endpointID, id), http.StatusNotFound)
+}
+
+// updateOne applies change to the request body of an existing route and saves it.
+func (s *backendService) updateOne(id string, change func(dto *BackendConfigDTO)) (*BackendConfig, error) {
+	s.mu.RLock()
+	existing, ok := s.runtimeCache[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, utils.NewServiceError(nil, "backend not found: "+id, http.StatusNotFound)
+	}
+	dto := dtoFromConfig(existing)
+	if dto.Fault != nil && !dto.Fault.ExpiresAt.After(time.Now()) {
+		dto.Fault = nil // expired experiments would fail validation
+	}
+	change(dto)
+	return s.Update(id, dto)
+}
+
+// Delete removes a configuration from the database and the running gateway.
+func (s *backendService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewServiceError(err, "backend not found: "+id, http.StatusNotFound)
+		}
+		return fmt.Errorf("failed to delete config %s: %w", id, err)
+	}
+
+	s.mu.Lock()
+	delete(s.runtimeCache, id)
+	configsForReload := make([]*BackendConfig, 0, len(s.runtimeCache))
+	for _, cfg := range s.runtimeCache {
+		configsForReload = append(configsForReload, cfg)
+	}
+	s.mu.Unlock()
+
+	s.gateway.ReloadBackends(configsForReload)
+	return nil
+}
 
-	newConfig := &BackendConfig{
-		ID:          newID,
-		PathPrefix:  dto.PathPrefix, // Use the new PathPrefix field
-		RateLimit:   dto.RateLimit,
-		AuthType:    dto.AuthType,
-		LastUpdated: time.Now(),
+// buildConfig turns dto into a validated runtime config. When existing is set,
+// endpoints with an unchanged URL are carried over.
+func (s *backendService) buildConfig(id string, dto *BackendConfigDTO, existing *BackendConfig) (*BackendConfig, error) {
+	cfg := &BackendConfig{
+		ID:                       id,
+		PathPrefix:               dto.PathPrefix, // Use the new PathPrefix field
+		RateLimit:                dto.RateLimit,
+		AuthType:                 dto.AuthType,
+		SLOTarget:                dto.SLOTarget,
+		Protocol:                 dto.Protocol,
+		ListenPort:               dto.ListenPort,
+		UpstreamTLS:              dto.UpstreamTLS,
+		IPFilter:                 dto.IPFilter,
+		CORS:                     dto.CORS,
+		Cache:                    dto.Cache,
+		Coalesce:                 dto.Coalesce,
+		Compression:              dto.Compression,
+		Mirror:                   dto.Mirror,
+		Fault:                    dto.Fault,
+		Maintenance:              dto.Maintenance,
+		Concurrency:              dto.Concurrency,
+		WaitingRoom:              dto.WaitingRoom,
+		Mock:                     dto.Mock,
+		Static:                   dto.Static,
+		Redirect:                 dto.Redirect,
+		SlowStartSeconds:         dto.SlowStartSeconds,
+		RedirectHTTPS:            dto.RedirectHTTPS,
+		WebSocket:                dto.WebSocket,
+		ResponseTimeoutSeconds:   dto.ResponseTimeoutSeconds,
+		StreamIdleTimeoutSeconds: dto.StreamIdleTimeoutSeconds,
+		LastUpdated:              time.Now(),
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolHTTP
+	}
+	if dto.TargetURLs == nil && !cfg.servedLocally() {
+		return nil, utils.NewServiceError(nil, "targetUrls is required for "+cfg.Protocol+" routes", http.StatusBadRequest)
+	}
+
+	// Kept endpoints are copied: the old config stays in use by requests in
+	// flight, and each config guards its own endpoints with its mu.
+	previous := make(map[string]*BackendEndpoint)
+	if existing != nil {
+		existing.mu.RLock()
+		for _, ep := range existing.Endpoints {
+			kept := *ep
+			previous[ep.URL] = &kept
+		}
+		existing.mu.RUnlock()
 	}
 
 	// 🛑 NEW LOGIC: Create BackendEndpoint structs for each URL
 	endpoints := make([]*BackendEndpoint, 0, len(dto.TargetURLs))
 	for _, rawURL := range dto.TargetURLs {
+		if ep, ok := previous[rawURL]; ok {
+			endpoints = append(endpoints, ep)
+			continue
+		}
 		parsedURL, err := url.Parse(rawURL)
 		if err != nil {
-			return nil, fmt.Errorf("invalid target URL (%s): %w", rawURL, err)
+			return nil, utils.NewServiceError(err, fmt.Sprintf("invalid target URL (%s)", rawURL), http.StatusBadRequest)
 		}
 
 		endpoint := &BackendEndpoint{
-			BackendConfigID: newID,
+			BackendConfigID: id,
 			URL:             rawURL,
 			IsHealthy:       false, // Initial status is DOWN
+			AdminState:      EndpointActive,
 			URLParsed:       parsedURL,
 		}
 		endpoints = append(endpoints, endpoint)
 	}
-	newConfig.Endpoints = endpoints
+	cfg.Endpoints = endpoints
 
-	// 1. Persist to DB (Repo must handle saving Config AND associated Endpoints)
-	if err := s.repo.Create(newConfig); err != nil {
-		return nil, fmt.Errorf("failed to save config and endpoints to DB: %w", err)
+	if err := s.validateConfig(cfg); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
 
-	// --- 2. Update Cache & Build Reload List (Atomic) ---
-	var configsForReload []*BackendConfig
+// validateConfig checks protocol-specific fields; errors are 400 ServiceErrors.
+func (s *backendService) validateConfig(cfg *BackendConfig) error {
+	badRequest := func(format string, args ...any) error {
+		return utils.NewServiceError(nil, fmt.Sprintf(format, args...), http.StatusBadRequest)
+	}
 
-	s.mu.Lock()
-	s.runtimeCache[newConfig.ID] = newConfig
+	if _, err := cfg.IPFilter.compile(); err != nil {
+		return badRequest("ipFilter %v", err)
+	}
+	if cfg.CORS != nil {
+		if err := cfg.CORS.validate(); err != nil {
+			return badRequest("cors: %v", err)
+		}
+	}
+	// MOCK, STATIC and REDIRECT routes are answered by the gateway from their own field.
+	for _, local := range []struct {
+		protocol, field string
+		set             bool
+	}{
+		{ProtocolMock, "mock", cfg.Mock != nil},
+		{ProtocolStatic, "static", cfg.Static != nil},
+		{ProtocolRedirect, "redirect", cfg.Redirect != nil},
+	} {
+		if cfg.Protocol == local.protocol && !local.set {
+			return badRequest("%s routes need %s", local.protocol, local.field)
+		}
+		if cfg.Protocol != local.protocol && local.set {
+			return badRequest("%s is only valid on %s routes", local.field, local.protocol)
+		}
+	}
+	if cfg.servedLocally() && (len(cfg.Endpoints) > 0 || cfg.Mirror != nil) {
+		return badRequest("%s routes take no targetUrls or mirror", cfg.Protocol)
+	}
+	if cfg.Mock != nil {
+		if err := cfg.Mock.validate(); err != nil {
+			return badRequest("mock: %v", err)
+		}
+	}
+	if cfg.Static != nil {
+		if err := cfg.Static.validate(s.gateway.StaticRootDir); err != nil {
+			return badRequest("static: %v", err)
+		}
+	}
+	if cfg.Redirect != nil {
+		if err := cfg.Redirect.validate(); err != nil {
+			return badRequest("redirect: %v", err)
+		}
+	}
+	if cfg.Maintenance != nil {
+		if cfg.IsL4() {
+			return badRequest("maintenance is not supported on %s routes", cfg.Protocol)
+		}
+		if err := cfg.Maintenance.validate(); err != nil {
+			return badRequest("maintenance: %v", err)
+		}
+	}
+	if cfg.Concurrency != nil {
+		if cfg.IsL4() || cfg.servedLocally() {
+			return badRequest("concurrency limits are not supported on %s routes", cfg.Protocol)
+		}
+		if err := cfg.Concurrency.validate(); err != nil {
+			return badRequest("concurrency: %v", err)
+		}
+	}
+	if cfg.WaitingRoom != nil && cfg.IsL4() {
+		return badRequest("waiting rooms are not supported on %s routes", cfg.Protocol)
+	}
+	if cfg.Fault != nil {
+		if cfg.IsL4() {
+			return badRequest("fault injection is not supported on %s routes", cfg.Protocol)
+		}
+		if err := cfg.Fault.validate(time.Now()); err != nil {
+			return badRequest("fault: %v", err)
+		}
+	}
+	if !cfg.IsL4() {
+		if _, err := cfg.UpstreamTLS.clientConfig(); err != nil {
+			return badRequest("upstreamTls: %v", err)
+		}
+		if !strings.HasPrefix(cfg.PathPrefix, "/") {
+			return badRequest("pathPrefix must start with '/' for %s routes", cfg.Protocol)
+		}
+		if cfg.ListenPort != 0 {
+			return badRequest("listenPort is only valid for TCP and UDP routes")
+		}
+		if cfg.Cache != nil && cfg.Protocol == ProtocolGRPC {
+			return badRequest("cache is not supported on GRPC routes")
+		}
+		if cfg.Coalesce != nil && cfg.Protocol == ProtocolGRPC {
+			return badRequest("coalesce is not supported on GRPC routes")
+		}
+		if cfg.Mirror != nil && cfg.Protocol == ProtocolGRPC {
+			return badRequest("mirror is not supported on GRPC routes")
+		}
+		return nil
+	}
 
-	configsForReload = make([]*BackendConfig, 0, len(s.runtimeCache))
-	for _, cfg := range s.runtimeCache {
-		configsForReload = append(configsForReload, cfg)
+	if cfg.ListenPort < 1 || cfg.ListenPort > 65535 {
+		return badRequest("%s routes need a listenPort between 1 and 65535", cfg.Protocol)
+	}
+	for _, ep := range cfg.Endpoints {
+		if ep.URLParsed == nil || ep.URLParsed.Port() == "" {
+			return badRequest("target URL %s must look like %s://host:port", ep.URL, strings.ToLower(cfg.Protocol))
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, other := range s.runtimeCache {
+		if other.ID != cfg.ID && other.Protocol == cfg.Protocol && other.ListenPort == cfg.ListenPort {
+			return badRequest("%s port %d is already used by backend %s", cfg.Protocol, cfg.ListenPort, other.ID)
+		}
+	}
+	return nil
+}
+
+// dtoFromConfig rebuilds the request body that would produce cfg, so a single
+// setting can be changed through Update.
+func dtoFromConfig(cfg *BackendConfig) *BackendConfigDTO {
+	urls := make([]string, 0, len(cfg.Endpoints))
+	for _, ep := range cfg.Endpoints {
+		urls = append(urls, ep.URL)
+	}
+	return &BackendConfigDTO{
+		PathPrefix:               cfg.PathPrefix,
+		TargetURLs:               urls,
+		RateLimit:                cfg.RateLimit,
+		AuthType:                 cfg.AuthType,
+		SLOTarget:                cfg.SLOTarget,
+		Protocol:                 cfg.Protocol,
+		ListenPort:               cfg.ListenPort,
+		WebSocket:                cfg.WebSocket,
+		ResponseTimeoutSeconds:   cfg.ResponseTimeoutSeconds,
+		StreamIdleTimeoutSeconds: cfg.StreamIdleTimeoutSeconds,
+		RedirectHTTPS:            cfg.RedirectHTTPS,
+		UpstreamTLS:              cfg.UpstreamTLS,
+		IPFilter:                 cfg.IPFilter,
+		CORS:                     cfg.CORS,
+		Cache:                    cfg.Cache,
+		Coalesce:                 cfg.Coalesce,
+		Compression:              cfg.Compression,
+		Mirror:                   cfg.Mirror,
+		Fault:                    cfg.Fault,
+		Maintenance:              cfg.Maintenance,
+		Concurrency:              cfg.Concurrency,
+		WaitingRoom:              cfg.WaitingRoom,
+		Mock:                     cfg.Mock,
+		Static:                   cfg.Static,
+		Redirect:                 cfg.Redirect,
+		SlowStartSeconds:         cfg.SlowStartSeconds,
+	}
+}
+
+// storeAndReload puts cfg in the runtime cache and pushes the full list to the gateway.
+func (s *backendService) storeAndReload(cfg *BackendConfig) {
+	s.mu.Lock()
+	s.runtimeCache[cfg.ID] = cfg
+	configsForReload := make([]*BackendConfig, 0, len(s.runtimeCache))
+	for _, c := range s.runtimeCache {
+		configsForReload = append(configsForReload, c)
 	}
 	s.mu.Unlock()
 
-	// 3. Reload the gateway.
 	s.gateway.ReloadBackends(configsForReload)
-
-	return newConfig, nil
 }
+
 func (s *backendService) GetAll() ([]*BackendConfig, error) {
 	return s.GetRuntimeConfigs(), nil
 }
@@ -240,7 +740,7 @@ func (s *backendService) SetHealthStatus_(configID string, endpointURL string, i
 
 	log.Printf("INFO: Health status updated for Endpoint %s (Config %s). New Status: %t", endpointURL, configID, isHealthy)
 }
-func (s *backendService) SetHealthStatus(configID string, endpointURL string, isHealthy bool, latency time.Duration) {
+func (s *backendService) SetHealthStatus(configID string, endpointURL string, isHealthy bool, latency time.Duration, statusCode int, reason string) {
 	// 1. Locate the BackendConfig in the runtime cache
 	s.mu.RLock()
 	cfg, ok := s.runtimeCache[configID]
@@ -268,9 +768,14 @@ func (s *backendService) SetHealthStatus(configID string, endpointURL string, is
 	// 3. Record Health History (Convert latency to int64 for persistence)
 	latencyNs := latency.Nanoseconds()
 	historyRecord := &HealthHistory{
-		BackendID: configID, // Log history against the config ID
-		IsHealthy: isHealthy,
-		Latency:   latencyNs,
+		BackendID:  configID,
+		EndpointID: targetEndpoint.ID,
+		IsHealthy:  isHealthy,
+		Latency:    latencyNs,
+		StatusCode: statusCode,
+	}
+	if !isHealthy {
+		historyRecord.FailureReason = reason
 	}
 	if err := s.repo.SaveHealthHistory(historyRecord); err != nil {
 		log.Printf("ERROR saving health history for %s: %v", configID, err)
@@ -278,12 +783,38 @@ func (s *backendService) SetHealthStatus(configID string, endpointURL string, is
 	}
 
 	// 4. Update the runtime status and persist ONLY if the health status has changed
-	if targetEndpoint.IsHealthy == isHealthy {
+	cfg.mu.Lock() // the balancer reads IsHealthy under cfg.mu
+	changed := targetEndpoint.IsHealthy != isHealthy
+	targetEndpoint.IsHealthy = isHealthy
+	cfg.mu.Unlock()
+
+	s.gateway.Alerts.ObserveEndpoint(alerting.EndpointObservation{
+		BackendID:    configID,
+		PathPrefix:   cfg.PathPrefix,
+		EndpointID:   targetEndpoint.ID,
+		URL:          endpointURL,
+		Healthy:      isHealthy,
+		Reason:       reason,
+		RouteHealthy: cfg.HasHealthyEndpoint(),
+		At:           time.Now(),
+	})
+
+	if !changed {
 		return // Status hasn't changed, skip DB update
 	}
+	if isHealthy {
+		cfg.startSlowStart(targetEndpoint)
+	}
 
-	// Update status in the IN-MEMORY cache
-	targetEndpoint.IsHealthy = isHealthy
+	s.gateway.Events.Publish(eventbus.TypeEndpointHealth, eventbus.EndpointHealthData{
+		BackendID:  configID,
+		PathPrefix: cfg.PathPrefix,
+		EndpointID: targetEndpoint.ID,
+		URL:        endpointURL,
+		IsHealthy:  isHealthy,
+		Reason:     reason,
+		LatencyMs:  latency.Milliseconds(),
+	})
 
 	// 5. Persist the new status to the database
 	if err := s.repo.UpdateEndpointHealth(configID, endpointURL, isHealthy); err != nil {
@@ -295,7 +826,100 @@ func (s *backendService) SetHealthStatus(configID string, endpointURL string, is
 
 	log.Printf("INFO: Health status UPDATED for Endpoint %s (Config %s). New Status: %t", endpointURL, configID, isHealthy)
 }
+
+// SetEndpointTLS records the certificate an HTTPS endpoint presented to a health probe.
+// The database is only written when the chain or hostname result changes; warnings
+// repeat once per day while the certificate stays inside the warning window.
+func (s *backendService) SetEndpointTLS(configID string, endpointURL string, info *EndpointTLSInfo) {
+	s.mu.RLock()
+	cfg, ok := s.runtimeCache[configID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	var targetEndpoint *BackendEndpoint
+	for _, ep := range cfg.Endpoints {
+		if ep.URL == endpointURL {
+			targetEndpoint = ep
+			break
+		}
+	}
+	if targetEndpoint == nil {
+		return
+	}
+
+	now := time.Now()
+	cfg.mu.Lock() // readers are UpstreamCertificates and the config API's JSON
+	previous := targetEndpoint.TLS
+	targetEndpoint.TLS = info
+	cfg.mu.Unlock()
+
+	subject := ""
+	if len(info.Chain) > 0 {
+		subject = info.Chain[0].Subject
+	}
+	s.gateway.Alerts.ObserveCertificate(alerting.CertificateObservation{
+		BackendID:     configID,
+		PathPrefix:    cfg.PathPrefix,
+		EndpointID:    targetEndpoint.ID,
+		URL:           endpointURL,
+		Subject:       subject,
+		NotAfter:      info.NotAfter,
+		HostnameError: info.HostnameError,
+		At:            now,
+	})
+
+	changed := !info.sameChain(previous)
+	if changed {
+		if err := s.repo.UpdateEndpointTLS(targetEndpoint.ID, info); err != nil {
+			log.Printf("ERROR: Failed to persist certificate of endpoint %s (%s): %v", endpointURL, configID, err)
+		}
+	}
+
+	daysLeft := info.DaysLeft(now)
+	expiring := daysLeft < s.gateway.certWarningDays()
+	if !expiring && info.HostnameError == "" {
+		return
+	}
+	if !changed && previous.DaysLeft(now) == daysLeft {
+		return
+	}
+	if info.HostnameError != "" {
+		log.Printf("WARN: Certificate of endpoint %s (%s) does not match %s: %s", endpointURL, configID, info.ServerName, info.HostnameError)
+	}
+	if expiring {
+		log.Printf("WARN: Certificate of endpoint %s (%s) expires %s (%d days left)",
+			endpointURL, configID, info.NotAfter.Format(time.RFC3339), daysLeft)
+	}
+	var domains []string
+	if len(info.Chain) > 0 {
+		domains = info.Chain[0].DNSNames
+	}
+	s.gateway.Events.Publish(eventbus.TypeCertExpiring, eventbus.CertificateExpiryData{
+		Source:        "upstream",
+		ID:            targetEndpoint.ID,
+		BackendID:     configID,
+		Name:          endpointURL,
+		Domains:       domains,
+		NotAfter:      info.NotAfter,
+		DaysLeft:      daysLeft,
+		HostnameError: info.HostnameError,
+	})
+}
+
 func (s *backendService) GetHistory(query *HistoryQueryDTO) ([]*HealthHistory, error) {
 	// The repository handles the filtering and ordering
 	return s.repo.GetHealthHistory(query)
 }
+
+// GetHistoryRollups returns the hourly or daily summaries kept by the retention job.
+func (s *backendService) GetHistoryRollups(query *HistoryQueryDTO) ([]*HealthRollup, error) {
+	if query.Granularity != RollupHourly && query.Granularity != RollupDaily {
+		return nil, utils.NewServiceError(nil, fmt.Sprintf("unsupported rollup granularity: %q", query.Granularity), http.StatusBadRequest)
+	}
+	rollups, err := s.repo.GetHealthRollups(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read health rollups: %w", err)
+	}
+	return rollups, nil
+}
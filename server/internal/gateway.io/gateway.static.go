@@ -0,0 +1,450 @@
+// gateway.static.go
+package gatewayio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultStaticDir is where uploaded site archives are unpacked when Gateway.StaticDir is empty.
+	DefaultStaticDir        = "data/static"
+	defaultStaticIndex      = "index.html"
+	defaultMaxArchiveBytes  = 100 << 20
+	defaultMaxSiteBytes     = 500 << 20
+	immutableMaxAge         = 365 * 24 * 60 * 60
+	staticRevalidateControl = "no-cache"
+)
+
+// precompressedSuffixes maps content codings to the file suffix of their
+// precompressed variant (app.js.br next to app.js).
+var precompressedSuffixes = map[string]string{
+	EncodingBrotli: ".br",
+	EncodingZstd:   ".zst",
+	EncodingGzip:   ".gz",
+}
+
+// StaticSite serves a STATIC route from a directory on the gateway host or,
+// when Root is empty, from the archive uploaded for the route.
+type StaticSite struct {
+	Root              string   `json:"root,omitempty"`                                                    // directory under gateway.static.rootDir; empty serves the uploaded archive
+	IndexFile         string   `json:"indexFile,omitempty"`                                               // served for directories; empty uses index.html
+	SPAFallback       bool     `json:"spaFallback"`                                                       // serve IndexFile for missing paths without a file extension
+	MaxAgeSeconds     int      `json:"maxAgeSeconds" binding:"omitempty,gte=0"`                           // Cache-Control max-age for files; HTML always revalidates
+	ImmutablePrefixes []string `json:"immutablePrefixes,omitempty" binding:"omitempty,dive,startswith=/"` // e.g. /assets/ for hashed build output, cached for a year
+}
+
+func (s *StaticSite) indexFile() string {
+	if s.IndexFile != "" {
+		return s.IndexFile
+	}
+	return defaultStaticIndex
+}
+
+// validate rejects index names that would leave the site root and roots
+// outside rootDir, the only place host directories may be served from.
+func (s *StaticSite) validate(rootDir string) error {
+	if s.IndexFile != "" && (strings.ContainsAny(s.IndexFile, `/\`) || hiddenStaticName(s.IndexFile)) {
+		return fmt.Errorf("indexFile must be a plain file name")
+	}
+	if s.Root != "" {
+		return checkStaticRoot(s.Root, rootDir)
+	}
+	return nil
+}
+
+// checkStaticRoot requires root to be an absolute directory under rootDir
+// without hidden segments such as .git; an empty rootDir allows no root.
+func checkStaticRoot(root, rootDir string) error {
+	if rootDir == "" {
+		return fmt.Errorf("root is disabled; set gateway.static.rootDir or upload an archive")
+	}
+	if !filepath.IsAbs(root) {
+		return fmt.Errorf("root must be an absolute path")
+	}
+	base, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(base, filepath.Clean(root))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("root must be inside %s", base)
+	}
+	for _, segment := range strings.Split(rel, string(filepath.Separator)) {
+		if hiddenStaticName(segment) {
+			return fmt.Errorf("root must not contain hidden directories")
+		}
+	}
+	return nil
+}
+
+// hiddenStaticName reports whether a path segment is a dotfile, which is never
+// served (.git, .env, ...). .well-known is public by definition.
+func hiddenStaticName(segment string) bool {
+	return strings.HasPrefix(segment, ".") && segment != "." && segment != ".well-known"
+}
+
+// cacheControl picks the Cache-Control value for the file at name.
+func (s *StaticSite) cacheControl(name string) string {
+	for _, prefix := range s.ImmutablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Sprintf("public, max-age=%d, immutable", immutableMaxAge)
+		}
+	}
+	if path.Ext(name) == ".html" || s.MaxAgeSeconds == 0 {
+		return staticRevalidateControl // a new deploy must be picked up on the next load
+	}
+	return fmt.Sprintf("public, max-age=%d", s.MaxAgeSeconds)
+}
+
+func (g *Gateway) staticDir() string {
+	if g.StaticDir != "" {
+		return g.StaticDir
+	}
+	return DefaultStaticDir
+}
+
+// staticRoot is the directory a STATIC route serves. Roots saved before
+// StaticRootDir changed are checked again and yield "" when now outside it.
+func (g *Gateway) staticRoot(cfg *BackendConfig) string {
+	if cfg.Static.Root != "" {
+		if err := checkStaticRoot(cfg.Static.Root, g.StaticRootDir); err != nil {
+			log.Printf("ERROR: Static route %s root %s refused: %v", cfg.ID, cfg.Static.Root, err)
+			return ""
+		}
+		return cfg.Static.Root
+	}
+	return filepath.Join(g.staticDir(), cfg.ID)
+}
+
+// serveStatic answers GET and HEAD requests of a STATIC route from its files.
+// Directory listings are never produced.
+func (g *Gateway) serveStatic(w http.ResponseWriter, r *http.Request, cfg *BackendConfig) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	site := cfg.Static
+	dir := g.staticRoot(cfg)
+	if dir == "" {
+		g.writeError(w, r, cfg, http.StatusNotFound, "File not found.")
+		return
+	}
+	root := http.Dir(dir)
+	name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, cfg.PathPrefix))
+
+	f, info, err := openStaticFile(root, name)
+	if err == nil && info.IsDir() {
+		f.Close()
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Relative links in the index resolve against the directory.
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		name = path.Join(name, site.indexFile())
+		f, info, err = openStaticFile(root, name)
+	}
+	if errors.Is(err, fs.ErrNotExist) && site.SPAFallback && path.Ext(name) == "" {
+		name = "/" + site.indexFile()
+		f, info, err = openStaticFile(root, name)
+	}
+	if err != nil || info.IsDir() {
+		if err == nil {
+			f.Close()
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("ERROR: Static route %s failed to open %s: %v", cfg.ID, name, err)
+		}
+		g.writeError(w, r, cfg, http.StatusNotFound, "File not found.")
+		return
+	}
+	defer f.Close()
+
+	h := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	h.Set("Cache-Control", site.cacheControl(name))
+
+	// A precompressed sibling is served as-is; the compression wrapper leaves
+	// responses that already carry a Content-Encoding alone.
+	content, modTime, size, encoding := io.ReadSeeker(f), info.ModTime(), info.Size(), ""
+	if available := precompressedVariants(root, name); len(available) > 0 {
+		h.Add("Vary", "Accept-Encoding")
+		policy := &CompressionPolicy{Encodings: available}
+		if encoding = policy.negotiate(r.Header.Get("Accept-Encoding")); encoding != "" {
+			if cf, cinfo, err := openStaticFile(root, name+precompressedSuffixes[encoding]); err == nil {
+				defer cf.Close()
+				content, modTime, size = cf, cinfo.ModTime(), cinfo.Size()
+				h.Set("Content-Encoding", encoding)
+				if contentType == "" {
+					h.Set("Content-Type", "application/octet-stream") // sniffing compressed bytes is useless
+				}
+			} else {
+				encoding = ""
+			}
+		}
+	}
+	h.Set("ETag", fmt.Sprintf(`"%x-%x%s"`, modTime.UnixNano(), size, encoding))
+	http.ServeContent(w, r, name, modTime, content)
+}
+
+func openStaticFile(root http.Dir, name string) (http.File, fs.FileInfo, error) {
+	if slices.ContainsFunc(strings.Split(name, "/"), hiddenStaticName) {
+		return nil, nil, fs.ErrNotExist // http.Dir would serve dotfiles
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// precompressedVariants lists the codings with a regular file next to name,
+// in the gateway's default preference order.
+func precompressedVariants(root http.Dir, name string) []string {
+	var available []string
+	for _, encoding := range defaultEncodings {
+		if f, info, err := openStaticFile(root, name+precompressedSuffixes[encoding]); err == nil {
+			f.Close()
+			if info.Mode().IsRegular() {
+				available = append(available, encoding)
+			}
+		}
+	}
+	return available
+}
+
+// InstallStaticArchive unpacks a zip or tar.gz archive as the files of a STATIC
+// route without a Root, replacing the previous upload in one rename. An archive
+// holding a single top-level directory (e.g. dist/) is served from inside it.
+func (g *Gateway) InstallStaticArchive(backendID string, archive io.Reader) (int, error) {
+	g.mu.RLock()
+	cfg, ok := g.backends[backendID]
+	g.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%w: backend %s", errStaticNotFound, backendID)
+	}
+	if cfg.Protocol != ProtocolStatic || cfg.Static.Root != "" {
+		return 0, fmt.Errorf("%w: backend %s is not a STATIC route served from an upload", errStaticNotUploadable, backendID)
+	}
+
+	dir := g.staticDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	// Spool the upload so zip can seek and the size limit applies before unpacking.
+	spool, err := os.CreateTemp(dir, backendID+"-*.upload")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	limit := g.maxArchiveBytes()
+	size, err := io.Copy(spool, io.LimitReader(archive, limit+1))
+	if err != nil {
+		return 0, err
+	}
+	if size > limit {
+		return 0, fmt.Errorf("%w: archive exceeds %d bytes", errStaticArchiveInvalid, limit)
+	}
+
+	staging, err := os.MkdirTemp(dir, backendID+"-*.staging")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(staging)
+	files, err := unpackArchive(spool, size, staging, g.maxSiteBytes())
+	if err != nil {
+		return 0, err
+	}
+
+	content := staging
+	if entries, err := os.ReadDir(staging); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		content = filepath.Join(staging, entries[0].Name())
+	}
+
+	// Uploads to one route swap in turn; each keeps the old tree in its own
+	// backup directory until the new one is in place.
+	lock, _ := g.staticUploads.LoadOrStore(backendID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	backup, err := os.MkdirTemp(dir, backendID+"-*.previous")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(backup)
+	target := g.staticRoot(cfg)
+	previous := filepath.Join(backup, "site")
+	if err := os.Rename(target, previous); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err := os.Rename(content, target); err != nil {
+		os.Rename(previous, target)
+		return 0, err
+	}
+	log.Printf("INFO: Installed static site for backend %s: %d files", backendID, files)
+	return files, nil
+}
+
+var (
+	errStaticNotFound       = errors.New("backend not found")
+	errStaticNotUploadable  = errors.New("not an uploadable static route")
+	errStaticArchiveInvalid = errors.New("invalid archive")
+)
+
+func (g *Gateway) maxArchiveBytes() int64 {
+	if g.StaticMaxArchiveBytes > 0 {
+		return g.StaticMaxArchiveBytes
+	}
+	return defaultMaxArchiveBytes
+}
+
+func (g *Gateway) maxSiteBytes() int64 {
+	if g.StaticMaxSiteBytes > 0 {
+		return g.StaticMaxSiteBytes
+	}
+	return defaultMaxSiteBytes
+}
+
+// unpackArchive extracts regular files and directories of a zip or tar.gz
+// into dst, refusing entries that would land outside it. Links are skipped.
+func unpackArchive(f *os.File, size int64, dst string, maxBytes int64) (int, error) {
+	head := make([]byte, 4)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return 0, fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+	}
+	u := &unpacker{dst: dst, remaining: maxBytes}
+	switch {
+	case bytes.Equal(head, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+		}
+		for _, entry := range zr.File {
+			if entry.FileInfo().IsDir() {
+				if err := u.dir(entry.Name); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			rc, err := entry.Open()
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+			}
+			err = u.file(entry.Name, rc)
+			rc.Close()
+			if err != nil {
+				return 0, err
+			}
+		}
+	case head[0] == 0x1f && head[1] == 0x8b:
+		gz, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(f, 0, size)))
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeDir:
+				err = u.dir(hdr.Name)
+			case tar.TypeReg:
+				err = u.file(hdr.Name, tr)
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	default:
+		return 0, fmt.Errorf("%w: expected a zip or tar.gz file", errStaticArchiveInvalid)
+	}
+	if u.files == 0 {
+		return 0, fmt.Errorf("%w: archive has no files", errStaticArchiveInvalid)
+	}
+	return u.files, nil
+}
+
+type unpacker struct {
+	dst       string
+	remaining int64
+	files     int
+}
+
+// target maps an archive entry name to a path inside dst.
+func (u *unpacker) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: entry %q escapes the archive", errStaticArchiveInvalid, name)
+		}
+	}
+	return filepath.Join(u.dst, filepath.FromSlash(path.Clean("/"+name))), nil
+}
+
+func (u *unpacker) dir(name string) error {
+	target, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0o755)
+}
+
+func (u *unpacker) file(name string, r io.Reader) error {
+	target, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, u.remaining+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errStaticArchiveInvalid, err)
+	}
+	if u.remaining -= n; u.remaining < 0 {
+		return fmt.Errorf("%w: unpacked site is larger than the limit", errStaticArchiveInvalid)
+	}
+	u.files++
+	return nil
+}
@@ -0,0 +1,203 @@
+package gatewayio
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUnpackerTarget(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "site")
+	u := &unpacker{dst: dst}
+	tests := []struct {
+		name string
+		want string // "" means the entry is refused
+	}{
+		{"index.html", "index.html"},
+		{"assets/app.js", "assets/app.js"},
+		{"/abs/file.txt", "abs/file.txt"},
+		{`windows\style.css`, "windows/style.css"},
+		{"./a/./b.txt", "a/b.txt"},
+		{"../evil.txt", ""},
+		{"a/../../evil.txt", ""},
+		{`..\evil.txt`, ""},
+	}
+	for _, tt := range tests {
+		got, err := u.target(tt.name)
+		if tt.want == "" {
+			if !errors.Is(err, errStaticArchiveInvalid) {
+				t.Errorf("target(%q) = %q, %v; want refused", tt.name, got, err)
+			}
+			continue
+		}
+		if want := filepath.Join(dst, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("target(%q) = %q, %v; want %q", tt.name, got, err, want)
+		}
+	}
+}
+
+func TestUnpackArchiveRejectsEscape(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "site.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"index.html", "../outside.txt"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte("x"))
+	}
+	zw.Close()
+	info, _ := f.Stat()
+
+	dst := filepath.Join(dir, "site")
+	if _, err := unpackArchive(f, info.Size(), dst, 1<<20); !errors.Is(err, errStaticArchiveInvalid) {
+		t.Fatalf("unpackArchive = %v, want errStaticArchiveInvalid", err)
+	}
+	f.Close()
+	if _, err := os.Stat(filepath.Join(dir, "outside.txt")); !os.IsNotExist(err) {
+		t.Error("entry was written outside the destination")
+	}
+}
+
+func TestInstallStaticArchiveConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	g := &Gateway{StaticDir: dir, backends: map[string]*BackendConfig{
+		"s": {ID: "s", Protocol: ProtocolStatic, Static: &StaticSite{}},
+	}}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			w, _ := zw.Create("index.html")
+			fmt.Fprintf(w, "upload %d", i)
+			zw.Close()
+			if _, err := g.InstallStaticArchive("s", &buf); err != nil {
+				t.Errorf("upload %d: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	body, err := os.ReadFile(filepath.Join(dir, "s", "index.html"))
+	if err != nil || !bytes.HasPrefix(body, []byte("upload ")) {
+		t.Fatalf("live site after concurrent uploads: %q, %v", body, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("leftovers next to the live site: %v", names)
+	}
+}
+
+func TestCheckStaticRoot(t *testing.T) {
+	base := t.TempDir()
+	tests := []struct {
+		name, root, rootDir string
+		ok                  bool
+	}{
+		{"inside", filepath.Join(base, "docs"), base, true},
+		{"the base itself", base, base, true},
+		{"no base configured", filepath.Join(base, "docs"), "", false},
+		{"relative", "docs", base, false},
+		{"filesystem root", "/", base, false},
+		{"sibling with shared prefix", base + "-other", base, false},
+		{"dot-dot", filepath.Join(base, "docs", "..", ".."), base, false},
+		{"hidden directory", filepath.Join(base, ".git"), base, false},
+	}
+	for _, tt := range tests {
+		if err := checkStaticRoot(tt.root, tt.rootDir); (err == nil) != tt.ok {
+			t.Errorf("%s: checkStaticRoot(%q, %q) = %v, want ok %t", tt.name, tt.root, tt.rootDir, err, tt.ok)
+		}
+	}
+}
+
+func TestServeStaticHidesDotfiles(t *testing.T) {
+	root := t.TempDir()
+	for name, body := range map[string]string{
+		"index.html":                 "<p>home</p>",
+		".env":                       "SECRET=1",
+		".git/config":                "[core]",
+		".well-known/security.txt":   "Contact: x",
+		"assets/.hidden/app.js":      "x",
+		"assets/app.3f2a.js":         "js",
+		"assets/app.3f2a.js.gz":      "gz",
+		"unrelated/.nothing-to-find": "",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(body), 0o644)
+	}
+	g := &Gateway{StaticRootDir: filepath.Dir(root)}
+	cfg := &BackendConfig{ID: "s", PathPrefix: "/site", Protocol: ProtocolStatic, Static: &StaticSite{Root: root, ImmutablePrefixes: []string{"/assets/"}}}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/site/", http.StatusOK},
+		{"/site/.env", http.StatusNotFound},
+		{"/site/.git/config", http.StatusNotFound},
+		{"/site/assets/.hidden/app.js", http.StatusNotFound},
+		{"/site/.well-known/security.txt", http.StatusOK},
+		{"/site/assets/app.3f2a.js", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		g.serveStatic(w, httptest.NewRequest(http.MethodGet, tt.path, nil), cfg)
+		if w.Code != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/site/assets/app.3f2a.js", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	g.serveStatic(w, r, cfg)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Body.String() != "gz" {
+		t.Errorf("precompressed variant not served: %v %q", w.Header(), w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+
+	// A root saved before rootDir was narrowed is refused at serve time.
+	g.StaticRootDir = filepath.Join(root, "assets")
+	w = httptest.NewRecorder()
+	g.serveStatic(w, httptest.NewRequest(http.MethodGet, "/site/", nil), cfg)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("root outside rootDir served with %d", w.Code)
+	}
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	site := &StaticSite{MaxAgeSeconds: 300, ImmutablePrefixes: []string{"/assets/"}}
+	tests := map[string]string{
+		"/index.html":     staticRevalidateControl,
+		"/assets/a.js":    "public, max-age=31536000, immutable",
+		"/img/logo.png":   "public, max-age=300",
+		"/docs/page.html": staticRevalidateControl,
+	}
+	for name, want := range tests {
+		if got := site.cacheControl(name); got != want {
+			t.Errorf("cacheControl(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := (&StaticSite{}).cacheControl("/img/logo.png"); got != staticRevalidateControl {
+		t.Errorf("cacheControl without max-age = %q", got)
+	}
+}
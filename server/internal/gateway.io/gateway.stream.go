@@ -0,0 +1,107 @@
+// gateway.stream.go
+package gatewayio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// errResponseTimeout cancels an upstream request that exceeded the route's ResponseTimeoutSeconds.
+	errResponseTimeout = errors.New("upstream response timeout")
+	// errStreamIdle cancels a stream that sent nothing for StreamIdleTimeoutSeconds.
+	errStreamIdle = errors.New("stream idle timeout")
+)
+
+func (b *BackendConfig) responseTimeout() time.Duration {
+	return time.Duration(b.ResponseTimeoutSeconds) * time.Second
+}
+
+func (b *BackendConfig) streamIdleTimeout() time.Duration {
+	return time.Duration(b.StreamIdleTimeoutSeconds) * time.Second
+}
+
+// isStreamingResponse reports whether resp is an event stream, a gRPC call or an
+// open-ended chunked body. httputil.ReverseProxy already flushes these immediately
+// as long as the response writer implements http.Flusher.
+func isStreamingResponse(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	if isStreamContentType(resp.Header) {
+		return true
+	}
+	if resp.ContentLength != -1 {
+		return false
+	}
+	for _, te := range resp.TransferEncoding {
+		if te == "chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// isStreamContentType reports whether h announces an event stream or a gRPC
+// call, which never end on their own. Plain chunked bodies do not count.
+func isStreamContentType(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && (mediaType == "text/event-stream" || strings.HasPrefix(mediaType, "application/grpc"))
+}
+
+// streamBody counts streamed bytes and cancels the upstream request when no
+// data arrives within idle. Closing it ends the stream in the route metrics.
+type streamBody struct {
+	io.ReadCloser
+	metrics   *routeMetrics
+	idle      time.Duration
+	timer     *time.Timer
+	timedOut  atomic.Bool
+	closeOnce sync.Once
+}
+
+func newStreamBody(body io.ReadCloser, metrics *routeMetrics, idle time.Duration, cancel context.CancelCauseFunc) *streamBody {
+	metrics.streams.Add(1)
+	metrics.activeStreams.Add(1)
+	s := &streamBody{ReadCloser: body, metrics: metrics, idle: idle}
+	if idle > 0 {
+		s.timer = time.AfterFunc(idle, func() {
+			s.timedOut.Store(true)
+			metrics.streamIdleTimeouts.Add(1)
+			cancel(errStreamIdle)
+		})
+	}
+	return s
+}
+
+func (s *streamBody) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if n > 0 {
+		s.metrics.streamBytes.Add(int64(n))
+		if s.timer != nil {
+			s.timer.Reset(s.idle)
+		}
+	}
+	// End an idle stream cleanly instead of aborting the client connection.
+	if err != nil && s.timedOut.Load() {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (s *streamBody) Close() error {
+	s.closeOnce.Do(func() {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		s.metrics.activeStreams.Add(-1)
+	})
+	return s.ReadCloser.Close()
+}
@@ -0,0 +1,92 @@
+package gatewayio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsStreamingResponse(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "http://upstream/", nil)
+	head, _ := http.NewRequest(http.MethodHead, "http://upstream/", nil)
+	tests := []struct {
+		name        string
+		req         *http.Request
+		contentType string
+		length      int64
+		chunked     bool
+		want        bool
+	}{
+		{"event stream", get, "text/event-stream; charset=utf-8", -1, false, true},
+		{"grpc", get, "application/grpc+proto", -1, false, true},
+		{"chunked", get, "application/json", -1, true, true},
+		{"sized", get, "application/json", 42, false, false},
+		{"unknown length, not chunked", get, "application/json", -1, false, false},
+		{"head", head, "text/event-stream", -1, false, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Request: tt.req, Header: http.Header{"Content-Type": {tt.contentType}}, ContentLength: tt.length}
+		if tt.chunked {
+			resp.TransferEncoding = []string{"chunked"}
+		}
+		if got := isStreamingResponse(resp); got != tt.want {
+			t.Errorf("%s: isStreamingResponse = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStreamBodyIdleTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	// Like the transport, abort the upstream body once the request is cancelled.
+	go func() {
+		<-ctx.Done()
+		pw.CloseWithError(context.Cause(ctx))
+	}()
+
+	metrics := &routeMetrics{}
+	body := newStreamBody(pr, metrics, 100*time.Millisecond, cancel)
+	go func() {
+		for range 3 {
+			pw.Write([]byte("data: tick\n\n"))
+			time.Sleep(40 * time.Millisecond) // each write resets the idle timer
+		}
+	}()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("idle stream ended with %v, want a clean EOF", err)
+	}
+	if len(data) != 36 || metrics.streamBytes.Load() != 36 {
+		t.Errorf("read %d bytes, counted %d, want 36", len(data), metrics.streamBytes.Load())
+	}
+	if !errors.Is(context.Cause(ctx), errStreamIdle) || metrics.streamIdleTimeouts.Load() != 1 {
+		t.Errorf("cause %v, idle timeouts %d", context.Cause(ctx), metrics.streamIdleTimeouts.Load())
+	}
+
+	if metrics.streams.Load() != 1 || metrics.activeStreams.Load() != 1 {
+		t.Errorf("streams %d, active %d before close", metrics.streams.Load(), metrics.activeStreams.Load())
+	}
+	body.Close()
+	body.Close()
+	if metrics.activeStreams.Load() != 0 {
+		t.Errorf("active streams %d after close", metrics.activeStreams.Load())
+	}
+}
+
+func TestStreamBodyWithoutIdleTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	metrics := &routeMetrics{}
+	body := newStreamBody(pr, metrics, 0, func(error) { t.Error("stream without an idle timeout was cancelled") })
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pw.CloseWithError(io.ErrUnexpectedEOF)
+	}()
+	if _, err := io.ReadAll(body); err != io.ErrUnexpectedEOF {
+		t.Errorf("upstream error = %v, want it passed through", err)
+	}
+	body.Close()
+}
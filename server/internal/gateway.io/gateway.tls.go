@@ -0,0 +1,150 @@
+// gateway.tls.go
+package gatewayio
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// isHTTPS reports whether the client reached the gateway over TLS, directly or
+// through a TLS-terminating proxy in front of it.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// redirectToHTTPS sends a method-preserving permanent redirect to the HTTPS listener.
+func (g *Gateway) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if g.HTTPSPort != "" && g.HTTPSPort != "443" {
+		host = net.JoinHostPort(host, g.HTTPSPort)
+	}
+	target := "https://" + host + r.URL.RequestURI()
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+}
+
+// UpstreamTLS controls how the gateway connects to https/wss endpoints of a route.
+// Certificates and keys are file paths on the gateway host (e.g. the mounted
+// config directory), so private keys never pass through the API or the database.
+type UpstreamTLS struct {
+	CABundle           string `json:"caBundle,omitempty"`   // PEM file trusted in addition to the system roots
+	ClientCert         string `json:"clientCert,omitempty"` // PEM certificate presented for mTLS
+	ClientKey          string `json:"clientKey,omitempty"`  // PEM key matching ClientCert
+	ServerName         string `json:"serverName,omitempty"` // SNI and verified name instead of the URL host
+	MinVersion         string `json:"minVersion,omitempty" binding:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // development only: accept any certificate
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// clientConfig reads the referenced files and builds the TLS client settings.
+// A nil policy returns nil, which keeps Go's defaults.
+func (t *UpstreamTLS) clientConfig() (*tls.Config, error) {
+	if t == nil {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported minVersion %q", t.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	if t.CABundle != "" {
+		pem, err := os.ReadFile(t.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CABundle)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return nil, errors.New("clientCert and clientKey must be set together")
+	}
+	if t.ClientCert != "" {
+		pair, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// upstreamTLSState holds what a route's UpstreamTLS builds. It is filled once per
+// loaded config; saving the route creates a new config and re-reads the files.
+type upstreamTLSState struct {
+	once      sync.Once
+	config    *tls.Config
+	transport http.RoundTripper // HTTP/1.1 with HTTP/2 upgrade via ALPN
+	h2        http.RoundTripper // HTTP/2 only, for gRPC
+	err       error
+}
+
+func (b *BackendConfig) loadUpstreamTLS() *upstreamTLSState {
+	s := &b.tlsState
+	s.once.Do(func() {
+		s.config, s.err = b.UpstreamTLS.clientConfig()
+		if s.err != nil {
+			log.Printf("ERROR: Upstream TLS settings for backend %s are unusable: %v", b.ID, s.err)
+			s.transport = failedTransport{s.err}
+			s.h2 = s.transport
+			return
+		}
+		if s.config == nil {
+			return
+		}
+		if s.config.InsecureSkipVerify {
+			log.Printf("WARN: Backend %s skips upstream certificate verification", b.ID)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = s.config.Clone()
+		s.transport = transport
+		h2 := newHTTP2Transport(true)
+		h2.TLSClientConfig = s.config.Clone()
+		s.h2 = h2
+	})
+	return s
+}
+
+// upstreamTLSConfig returns the route's TLS client settings; nil means Go's defaults.
+func (b *BackendConfig) upstreamTLSConfig() (*tls.Config, error) {
+	s := b.loadUpstreamTLS()
+	return s.config, s.err
+}
+
+// failedTransport fails every request, so a route with broken TLS settings
+// answers 502 instead of silently falling back to the default trust store.
+type failedTransport struct{ err error }
+
+func (t failedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("upstream TLS settings: %w", t.err)
+}
@@ -0,0 +1,102 @@
+package gatewayio
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCABundle stores the test server's certificate as a PEM file.
+func writeCABundle(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpstreamTLSClientConfig(t *testing.T) {
+	var none *UpstreamTLS
+	if cfg, err := none.clientConfig(); cfg != nil || err != nil {
+		t.Errorf("nil policy = %v, %v", cfg, err)
+	}
+
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a certificate"), 0o600)
+	tests := []struct {
+		name   string
+		policy UpstreamTLS
+		ok     bool
+	}{
+		{"defaults", UpstreamTLS{}, true},
+		{"tls 1.3", UpstreamTLS{MinVersion: "1.3"}, true},
+		{"unknown version", UpstreamTLS{MinVersion: "2.0"}, false},
+		{"missing bundle", UpstreamTLS{CABundle: filepath.Join(dir, "missing.pem")}, false},
+		{"empty bundle", UpstreamTLS{CABundle: garbage}, false},
+		{"cert without key", UpstreamTLS{ClientCert: garbage}, false},
+		{"unreadable key pair", UpstreamTLS{ClientCert: garbage, ClientKey: garbage}, false},
+	}
+	for _, tt := range tests {
+		cfg, err := tt.policy.clientConfig()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: clientConfig = %v, want ok %t", tt.name, err, tt.ok)
+			continue
+		}
+		if err == nil && cfg.MinVersion < tls.VersionTLS12 {
+			t.Errorf("%s: MinVersion %x below TLS 1.2", tt.name, cfg.MinVersion)
+		}
+	}
+}
+
+func TestUpstreamTLSTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	bundle := writeCABundle(t, srv)
+	target, _ := url.Parse(srv.URL)
+	endpoint := &BackendEndpoint{URLParsed: target}
+
+	tests := []struct {
+		name   string
+		policy *UpstreamTLS
+		ok     bool
+	}{
+		{"trusted CA", &UpstreamTLS{CABundle: bundle}, true},
+		{"trusted CA, wrong server name", &UpstreamTLS{CABundle: bundle, ServerName: "other.test"}, false},
+		{"covered server name", &UpstreamTLS{CABundle: bundle, ServerName: "example.com"}, true},
+		{"skip verify", &UpstreamTLS{InsecureSkipVerify: true}, true},
+		{"system roots only", &UpstreamTLS{MinVersion: "1.2"}, false},
+		{"broken settings", &UpstreamTLS{MinVersion: "0.9", InsecureSkipVerify: true}, false},
+	}
+	for _, tt := range tests {
+		cfg := &BackendConfig{ID: "b1", Protocol: ProtocolHTTP, UpstreamTLS: tt.policy}
+		transport := cfg.upstreamTransport(endpoint)
+		if transport == nil {
+			t.Errorf("%s: no transport for an https endpoint with upstream TLS", tt.name)
+			continue
+		}
+		if again := cfg.upstreamTransport(endpoint); again != transport {
+			t.Errorf("%s: settings were loaded twice", tt.name)
+		}
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: RoundTrip = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+
+	plain := &BackendEndpoint{URLParsed: &url.URL{Scheme: "http", Host: target.Host}}
+	if transport := (&BackendConfig{UpstreamTLS: &UpstreamTLS{CABundle: bundle}}).upstreamTransport(plain); transport != nil {
+		t.Errorf("http endpoint got transport %T", transport)
+	}
+}
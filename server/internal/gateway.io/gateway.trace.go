@@ -0,0 +1,47 @@
+package gatewayio
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// RequestIDHeader carries the correlation ID between client, gateway and upstream.
+	RequestIDHeader = "X-Request-ID"
+	// TraceParentHeader is the W3C Trace Context header.
+	TraceParentHeader = "traceparent"
+
+	maxRequestIDLength = 64
+)
+
+// ensureRequestID accepts the client's X-Request-ID or generates a new one.
+// The ID is pinned on the request headers so it is forwarded upstream and
+// visible to AccessLoggingHandler after ServeHTTP returns.
+func ensureRequestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = uuid.New().String()
+	}
+	r.Header.Set(RequestIDHeader, id)
+	return id
+}
+
+// extractTraceContext reads an incoming traceparent (if any) into ctx.
+func extractTraceContext(ctx context.Context, h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// injectTraceContext writes the span in ctx as traceparent/tracestate into h.
+func injectTraceContext(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// echoTraceHeaders returns the request ID and the gateway's traceparent to the client.
+func echoTraceHeaders(ctx context.Context, h http.Header, requestID string) {
+	h.Set(RequestIDHeader, requestID)
+	injectTraceContext(ctx, h)
+}
@@ -0,0 +1,63 @@
+package gatewayio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEnsureRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{"client id", "abc-123", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+		{"longest allowed", strings.Repeat("x", maxRequestIDLength), true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.id != "" {
+			r.Header.Set(RequestIDHeader, tt.id)
+		}
+		got := ensureRequestID(r)
+		if (got == tt.id) != tt.keep || got == "" {
+			t.Errorf("%s: ensureRequestID = %q", tt.name, got)
+		}
+		if r.Header.Get(RequestIDHeader) != got {
+			t.Errorf("%s: id not pinned on the request", tt.name)
+		}
+	}
+}
+
+func TestTraceContextRoundTrip(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	in := http.Header{}
+	in.Set(TraceParentHeader, traceparent)
+	ctx := extractTraceContext(context.Background(), in)
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !sc.IsSampled() {
+		t.Fatalf("extracted %v", sc)
+	}
+
+	out := http.Header{}
+	echoTraceHeaders(ctx, out, "req-1")
+	if out.Get(RequestIDHeader) != "req-1" || out.Get(TraceParentHeader) != traceparent {
+		t.Errorf("echoed headers = %v", out)
+	}
+
+	none := http.Header{}
+	injectTraceContext(context.Background(), none)
+	if none.Get(TraceParentHeader) != "" {
+		t.Errorf("traceparent injected without a span: %q", none.Get(TraceParentHeader))
+	}
+}
@@ -0,0 +1,474 @@
+// gateway.waitingroom.go
+package gatewayio
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPassTTL       = 10 * time.Minute
+	defaultRoomRefresh   = 10 * time.Second
+	roomPassCookie       = "gw_room_pass"
+	roomPositionCookie   = "gw_room_pos"
+	roomAbandonRefreshes = 3 // visitors who miss this many refreshes lose their place
+	defaultRoomMaxQueue  = 10000
+	roomPruneInterval    = time.Second // abandoned visitors are swept at most this often
+)
+
+// WaitingRoomPolicy queues visitors of a route once MaxActiveUsers hold a pass.
+// Queued visitors get a page that refreshes itself and are admitted in arrival
+// order as passes expire. Queue state is kept in memory per gateway instance;
+// passes are signed and stay valid across restarts.
+type WaitingRoomPolicy struct {
+	MaxActiveUsers int    `json:"maxActiveUsers" binding:"required,min=1"`
+	PassTTLSeconds int    `json:"passTtlSeconds" binding:"omitempty,gte=0"` // how long an admitted visitor may stay; 0 uses 600
+	AdmitPerMinute int    `json:"admitPerMinute" binding:"omitempty,gte=0"` // 0 admits as fast as passes free up
+	RefreshSeconds int    `json:"refreshSeconds" binding:"omitempty,gte=0"` // queue page refresh; 0 uses 10
+	MaxQueue       int    `json:"maxQueue" binding:"omitempty,gte=0"`       // visitors beyond it get a plain 503; 0 uses 10000
+	Title          string `json:"title,omitempty"`                          // heading of the queue page
+}
+
+func (p *WaitingRoomPolicy) passTTL() time.Duration {
+	if p.PassTTLSeconds > 0 {
+		return time.Duration(p.PassTTLSeconds) * time.Second
+	}
+	return defaultPassTTL
+}
+
+func (p *WaitingRoomPolicy) maxQueue() int {
+	if p.MaxQueue > 0 {
+		return p.MaxQueue
+	}
+	return defaultRoomMaxQueue
+}
+
+func (p *WaitingRoomPolicy) refresh() time.Duration {
+	if p.RefreshSeconds > 0 {
+		return time.Duration(p.RefreshSeconds) * time.Second
+	}
+	return defaultRoomRefresh
+}
+
+// WaitingRoomStats is the live view of one route's waiting room.
+type WaitingRoomStats struct {
+	BackendID          string             `json:"backendId"`
+	Policy             *WaitingRoomPolicy `json:"policy"`
+	ActiveUsers        int                `json:"activeUsers"`
+	Queued             int                `json:"queued"`
+	AdmittedTotal      int64              `json:"admittedTotal"`
+	AdmittedLastMinute int                `json:"admittedLastMinute"`
+}
+
+// waitingRoom is the queue and pass bookkeeping of one route.
+type waitingRoom struct {
+	mu            sync.Mutex
+	epoch         string // positions issued by an earlier process are not honoured
+	nextSeq       uint64
+	queue         list.List // *roomVisitor in arrival order
+	bySeq         map[uint64]*list.Element
+	index         seqIndex // positions of queued visitors
+	lastPrune     time.Time
+	passes        map[string]time.Time // pass ID -> expiry
+	tokens        float64              // admission budget when AdmitPerMinute is set
+	lastRefill    time.Time
+	admissions    []time.Time // admissions of the last minute
+	admittedTotal int64
+}
+
+type roomVisitor struct {
+	seq      uint64
+	lastSeen time.Time
+}
+
+func newWaitingRoom() *waitingRoom {
+	return &waitingRoom{
+		epoch:  randomHex(8),
+		bySeq:  make(map[uint64]*list.Element),
+		passes: make(map[string]time.Time),
+	}
+}
+
+// prune drops expired passes, abandoned visitors and old admission times.
+// The queue is swept at most once per roomPruneInterval.
+func (room *waitingRoom) prune(now time.Time, policy *WaitingRoomPolicy) {
+	for id, expiry := range room.passes {
+		if now.After(expiry) {
+			delete(room.passes, id)
+		}
+	}
+	if now.Sub(room.lastPrune) >= roomPruneInterval {
+		room.lastPrune = now
+		abandonAfter := roomAbandonRefreshes*policy.refresh() + 5*time.Second
+		for e := room.queue.Front(); e != nil; {
+			next := e.Next()
+			if v := e.Value.(*roomVisitor); now.Sub(v.lastSeen) > abandonAfter {
+				room.dequeue(v.seq)
+			}
+			e = next
+		}
+	}
+	cut := 0
+	for cut < len(room.admissions) && now.Sub(room.admissions[cut]) > time.Minute {
+		cut++
+	}
+	room.admissions = room.admissions[cut:]
+}
+
+// takeAdmission spends one unit of the admission rate, if any is left.
+func (room *waitingRoom) takeAdmission(now time.Time, policy *WaitingRoomPolicy) bool {
+	if policy.AdmitPerMinute <= 0 {
+		return true
+	}
+	rate := float64(policy.AdmitPerMinute)
+	if room.lastRefill.IsZero() {
+		room.tokens = rate
+	} else {
+		room.tokens = min(room.tokens+now.Sub(room.lastRefill).Minutes()*rate, rate)
+	}
+	room.lastRefill = now
+	if room.tokens < 1 {
+		return false
+	}
+	room.tokens--
+	return true
+}
+
+// enqueue adds a visitor at the back of the queue and returns its sequence number.
+func (room *waitingRoom) enqueue(now time.Time) uint64 {
+	room.nextSeq++
+	seq := room.nextSeq
+	room.bySeq[seq] = room.queue.PushBack(&roomVisitor{seq: seq, lastSeen: now})
+	room.index.add(seq, 1, &room.queue)
+	return seq
+}
+
+// dequeue removes a visitor from the queue.
+func (room *waitingRoom) dequeue(seq uint64) {
+	if e, ok := room.bySeq[seq]; ok {
+		room.queue.Remove(e)
+		delete(room.bySeq, seq)
+		room.index.add(seq, -1, &room.queue)
+	}
+}
+
+// position is the number of live visitors ahead of seq.
+func (room *waitingRoom) position(seq uint64) int {
+	return room.index.before(seq)
+}
+
+// seqIndex is a Fenwick tree counting queued visitors by sequence number from
+// base on, so positions take O(log n) instead of a walk of the queue.
+type seqIndex struct {
+	base uint64
+	tree []int
+}
+
+// add changes the count at seq by delta, rebuilding from queue when seq lies
+// past the tree. queue must already reflect the change.
+func (ix *seqIndex) add(seq uint64, delta int, queue *list.List) {
+	if seq < ix.base || seq-ix.base >= uint64(len(ix.tree)) {
+		ix.rebuild(queue)
+		return
+	}
+	for i := int(seq-ix.base) + 1; i <= len(ix.tree); i += i & -i {
+		ix.tree[i-1] += delta
+	}
+}
+
+// before counts queued visitors with a sequence number below seq.
+func (ix *seqIndex) before(seq uint64) int {
+	if seq <= ix.base {
+		return 0
+	}
+	n := 0
+	for i := int(min(seq-ix.base, uint64(len(ix.tree)))); i > 0; i -= i & -i {
+		n += ix.tree[i-1]
+	}
+	return n
+}
+
+// rebuild restarts the tree at the front of queue with room to double.
+func (ix *seqIndex) rebuild(queue *list.List) {
+	ix.base, ix.tree = 0, nil
+	front, back := queue.Front(), queue.Back()
+	if front == nil {
+		return
+	}
+	ix.base = front.Value.(*roomVisitor).seq
+	span := back.Value.(*roomVisitor).seq - ix.base + 1
+	ix.tree = make([]int, max(2*span, 64))
+	for e := front; e != nil; e = e.Next() {
+		ix.add(e.Value.(*roomVisitor).seq, 1, queue)
+	}
+}
+
+// admit issues a pass and records the admission; the caller holds room.mu.
+func (room *waitingRoom) admit(now time.Time, policy *WaitingRoomPolicy) (string, time.Time) {
+	id := randomHex(16)
+	expiry := now.Add(policy.passTTL())
+	room.passes[id] = expiry
+	room.admissions = append(room.admissions, now)
+	room.admittedTotal++
+	return id, expiry
+}
+
+// waitingRooms keeps room state across config reloads, keyed by backend ID;
+// the zero value is ready to use.
+type waitingRooms struct {
+	mu    sync.Mutex
+	rooms map[string]*waitingRoom
+	key   []byte // signs passes and positions when Gateway.SecretKey is empty
+}
+
+func (reg *waitingRooms) get(backendID string) *waitingRoom {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.rooms == nil {
+		reg.rooms = make(map[string]*waitingRoom)
+	}
+	room, ok := reg.rooms[backendID]
+	if !ok {
+		room = newWaitingRoom()
+		reg.rooms[backendID] = room
+	}
+	return room
+}
+
+// roomKey is the HMAC key for passes and positions.
+func (g *Gateway) roomKey() []byte {
+	if g.SecretKey != "" {
+		return []byte(g.SecretKey)
+	}
+	g.rooms.mu.Lock()
+	defer g.rooms.mu.Unlock()
+	if g.rooms.key == nil {
+		log.Printf("WARN: No secret key configured; waiting room passes will not survive a restart")
+		g.rooms.key = []byte(randomHex(32))
+	}
+	return g.rooms.key
+}
+
+// signRoomToken returns payload and its HMAC, both base64url encoded.
+func signRoomToken(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyRoomToken returns the payload of a token signed with key.
+func verifyRoomToken(key []byte, token string) (string, bool) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return "", false
+	}
+	return string(payload), true
+}
+
+// roomCookies yields the payloads of every validly signed cookie called name
+// whose payload starts with prefix. Nested routes can each set one.
+func roomCookies(r *http.Request, key []byte, name, prefix string) []string {
+	var out []string
+	for _, c := range r.Cookies() {
+		if c.Name != name {
+			continue
+		}
+		if payload, ok := verifyRoomToken(key, c.Value); ok && strings.HasPrefix(payload, prefix) {
+			out = append(out, strings.TrimPrefix(payload, prefix))
+		}
+	}
+	return out
+}
+
+// validPass finds an unexpired pass for the route and its ID and expiry.
+func validPass(r *http.Request, key []byte, backendID string, now time.Time) (string, time.Time, bool) {
+	for _, rest := range roomCookies(r, key, roomPassCookie, "pass|"+backendID+"|") {
+		id, exp, ok := strings.Cut(rest, "|")
+		if !ok {
+			continue
+		}
+		unix, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil {
+			continue
+		}
+		if expiry := time.Unix(unix, 0); now.Before(expiry) {
+			return id, expiry, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// serveWaitingRoom lets visitors with a pass through and queues the rest when
+// the route is full. It returns true when it answered with the queue page.
+func (g *Gateway) serveWaitingRoom(w http.ResponseWriter, r *http.Request, cfg *BackendConfig) bool {
+	policy := cfg.WaitingRoom
+	if policy == nil {
+		return false
+	}
+	key := g.roomKey()
+	room := g.rooms.get(cfg.ID)
+	now := time.Now()
+
+	if id, expiry, ok := validPass(r, key, cfg.ID, now); ok {
+		room.mu.Lock()
+		room.passes[id] = expiry // re-registers passes issued before a restart
+		room.mu.Unlock()
+		return false
+	}
+
+	room.mu.Lock()
+	room.prune(now, policy)
+	var seq uint64
+	queued := false
+	for _, rest := range roomCookies(r, key, roomPositionCookie, "pos|"+cfg.ID+"|"+room.epoch+"|") {
+		if n, err := strconv.ParseUint(rest, 10, 64); err == nil {
+			if e, ok := room.bySeq[n]; ok {
+				seq, queued = n, true
+				e.Value.(*roomVisitor).lastSeen = now
+				break
+			}
+		}
+	}
+	free := policy.MaxActiveUsers - len(room.passes)
+	if !queued {
+		if room.queue.Len() >= policy.maxQueue() && free <= 0 {
+			room.mu.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(int(policy.refresh().Seconds())))
+			noteReason(w, "waiting room full")
+			if isGRPCRequest(r) {
+				writeGRPCError(w, grpcStatusUnavailable, "waiting room full")
+				return true
+			}
+			g.writeError(w, r, cfg, http.StatusServiceUnavailable, "Waiting room is full.")
+			return true
+		}
+		seq = room.enqueue(now)
+	}
+
+	position := room.position(seq)
+	if position < free && room.takeAdmission(now, policy) {
+		room.dequeue(seq)
+		id, expiry := room.admit(now, policy)
+		room.mu.Unlock()
+		setRoomCookie(w, r, cfg, roomPassCookie, signRoomToken(key, fmt.Sprintf("pass|%s|%s|%d", cfg.ID, id, expiry.Unix())), expiry)
+		if queued {
+			setRoomCookie(w, r, cfg, roomPositionCookie, "", time.Time{}) // place no longer needed
+		}
+		return false
+	}
+	queueLength := room.queue.Len()
+	room.mu.Unlock()
+
+	setRoomCookie(w, r, cfg, roomPositionCookie, signRoomToken(key, fmt.Sprintf("pos|%s|%s|%d", cfg.ID, room.epoch, seq)), time.Time{})
+	noteReason(w, fmt.Sprintf("waiting room position %d of %d", position+1, queueLength))
+	writeQueuePage(w, r, policy, position+1, queueLength)
+	return true
+}
+
+// setRoomCookie sets a route-scoped cookie; a zero expiry makes a session
+// cookie and an empty value deletes it.
+func setRoomCookie(w http.ResponseWriter, r *http.Request, cfg *BackendConfig, name, value string, expires time.Time) {
+	maxAge := 0
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cfg.PathPrefix,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// writeQueuePage answers a queued visitor: a self-refreshing page for
+// browsers, JSON for everyone else. Both carry Retry-After.
+func writeQueuePage(w http.ResponseWriter, r *http.Request, policy *WaitingRoomPolicy, position, length int) {
+	refresh := int(policy.refresh().Seconds())
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Retry-After", strconv.Itoa(refresh))
+	h.Add("Vary", "Accept")
+	if isGRPCRequest(r) {
+		writeGRPCError(w, grpcStatusUnavailable, "waiting room: try again later")
+		return
+	}
+	if !prefersHTML(r.Header.Get("Accept")) {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{"error": "waiting room", "position": position, "queueLength": length, "retryAfterSeconds": refresh})
+		return
+	}
+	title := policy.Title
+	if title == "" {
+		title = "You are in line"
+	}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="%d"><title>%s</title></head>
+<body style="font-family:sans-serif;text-align:center;margin-top:15vh">
+<h1>%s</h1>
+<p>You are number <strong>%d</strong> of %d in the queue.</p>
+<p>This page refreshes every %d seconds. Keep it open to hold your place.</p>
+</body></html>
+`, refresh, html.EscapeString(title), html.EscapeString(title), position, length, refresh)
+}
+
+// WaitingRoomStats reports the live state of a route's waiting room, or nil
+// when the route has none.
+func (g *Gateway) WaitingRoomStats(backendID string) *WaitingRoomStats {
+	g.mu.RLock()
+	cfg, ok := g.backends[backendID]
+	g.mu.RUnlock()
+	if !ok || cfg.WaitingRoom == nil {
+		return nil
+	}
+	room := g.rooms.get(backendID)
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.prune(time.Now(), cfg.WaitingRoom)
+	return &WaitingRoomStats{
+		BackendID:          backendID,
+		Policy:             cfg.WaitingRoom,
+		ActiveUsers:        len(room.passes),
+		Queued:             room.queue.Len(),
+		AdmittedTotal:      room.admittedTotal,
+		AdmittedLastMinute: len(room.admissions),
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
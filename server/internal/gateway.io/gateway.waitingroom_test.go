@@ -0,0 +1,144 @@
+package gatewayio
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoomTokens(t *testing.T) {
+	key := []byte("secret")
+	token := signRoomToken(key, "pass|b1|abc|123")
+
+	if payload, ok := verifyRoomToken(key, token); !ok || payload != "pass|b1|abc|123" {
+		t.Fatalf("verify = %q, %t", payload, ok)
+	}
+	encoded, sig, _ := strings.Cut(token, ".")
+	forged := signRoomToken([]byte("other"), "pass|b1|abc|999")
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	for name, bad := range map[string]string{
+		"wrong key":        signRoomToken([]byte("other"), "pass|b1|abc|123"),
+		"swapped payload":  forgedPayload + "." + sig,
+		"missing sig":      encoded,
+		"bad base64":       "!!." + sig,
+		"truncated sig":    encoded + "." + sig[:10],
+		"empty":            "",
+		"separator only":   ".",
+		"payload with dot": encoded + ".." + sig,
+	} {
+		if _, ok := verifyRoomToken(key, bad); ok {
+			t.Errorf("%s: verified", name)
+		}
+	}
+}
+
+func TestValidPass(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	pass := func(backendID string, expiry time.Time) *http.Cookie {
+		return &http.Cookie{Name: roomPassCookie, Value: signRoomToken(key, fmt.Sprintf("pass|%s|id1|%d", backendID, expiry.Unix()))}
+	}
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		valid  bool
+	}{
+		{"valid", pass("b1", now.Add(time.Minute)), true},
+		{"expired", pass("b1", now.Add(-time.Minute)), false},
+		{"other route", pass("b2", now.Add(time.Minute)), false},
+		{"unsigned", &http.Cookie{Name: roomPassCookie, Value: "pass|b1|id1|9999999999"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(tt.cookie)
+			if _, _, ok := validPass(r, key, "b1", now); ok != tt.valid {
+				t.Errorf("validPass = %t, want %t", ok, tt.valid)
+			}
+		})
+	}
+}
+
+func TestWaitingRoomPositions(t *testing.T) {
+	room := newWaitingRoom()
+	now := time.Now()
+	var seqs []uint64
+	for range 200 {
+		seqs = append(seqs, room.enqueue(now))
+	}
+	// Remove from the front, the middle and the back.
+	for _, i := range []int{0, 100, 199, 50} {
+		room.dequeue(seqs[i])
+	}
+	var live []uint64
+	for i, seq := range seqs {
+		if i != 0 && i != 100 && i != 199 && i != 50 {
+			live = append(live, seq)
+		}
+	}
+	for want, seq := range live {
+		if got := room.position(seq); got != want {
+			t.Fatalf("position of seq %d = %d, want %d", seq, got, want)
+		}
+	}
+	if room.queue.Len() != len(live) {
+		t.Fatalf("queue length = %d, want %d", room.queue.Len(), len(live))
+	}
+
+	// Draining the queue and refilling it past the tree moves the index base.
+	for _, seq := range live {
+		room.dequeue(seq)
+	}
+	var refill []uint64
+	for range 300 {
+		refill = append(refill, room.enqueue(now))
+	}
+	for want, seq := range refill {
+		if got := room.position(seq); got != want {
+			t.Fatalf("position after refill of seq %d = %d, want %d", seq, got, want)
+		}
+	}
+}
+
+func TestWaitingRoomAdmission(t *testing.T) {
+	g := &Gateway{SecretKey: "secret"}
+	cfg := &BackendConfig{ID: "b1", PathPrefix: "/shop", WaitingRoom: &WaitingRoomPolicy{MaxActiveUsers: 1, MaxQueue: 1}}
+	visit := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/shop/", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		if !g.serveWaitingRoom(w, r, cfg) {
+			w.Code = http.StatusOK // passed through to the upstream
+		}
+		return w
+	}
+
+	first := visit(nil)
+	if first.Code != http.StatusOK || len(first.Result().Cookies()) == 0 {
+		t.Fatalf("first visitor: status %d, cookies %v", first.Code, first.Result().Cookies())
+	}
+	if w := visit(first.Result().Cookies()); w.Code != http.StatusOK {
+		t.Fatalf("pass holder: status %d", w.Code)
+	}
+
+	queued := visit(nil)
+	if queued.Code != http.StatusServiceUnavailable || queued.Header().Get("Retry-After") == "" {
+		t.Fatalf("second visitor: status %d", queued.Code)
+	}
+	if !strings.Contains(queued.Body.String(), `"position":1`) {
+		t.Errorf("queue page = %s", queued.Body.String())
+	}
+
+	full := visit(nil)
+	if full.Code != http.StatusServiceUnavailable || strings.Contains(full.Body.String(), "position") {
+		t.Fatalf("visitor past maxQueue: status %d body %s", full.Code, full.Body.String())
+	}
+	if room := g.rooms.get(cfg.ID); room.queue.Len() != 1 {
+		t.Errorf("queue length = %d, want 1", room.queue.Len())
+	}
+}
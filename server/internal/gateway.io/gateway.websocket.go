@@ -0,0 +1,522 @@
+// gateway.websocket.go
+package gatewayio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"imanager.io/internal/tracing"
+	"imanager.io/utils"
+)
+
+// Defaults applied when a route has no WebSocketPolicy or leaves a field at zero.
+const (
+	DefaultWSPingIntervalSeconds = 30
+	DefaultWSPongTimeoutSeconds  = 10
+	wsHandshakeTimeout           = 10 * time.Second
+	wsWriteWait                  = 5 * time.Second
+)
+
+// WebSocketPolicy tunes how a route proxies upgraded connections.
+type WebSocketPolicy struct {
+	// AllowedOrigins lists origins allowed to connect, e.g. "https://app.example.com"
+	// or "https://*.example.com". Empty allows every origin.
+	AllowedOrigins      []string `json:"allowedOrigins"`
+	PingIntervalSeconds int      `json:"pingIntervalSeconds" binding:"omitempty,gte=0"`
+	PongTimeoutSeconds  int      `json:"pongTimeoutSeconds" binding:"omitempty,gte=0"`
+	MaxMessageBytes     int64    `json:"maxMessageBytes" binding:"omitempty,gte=0"`    // 0 means unlimited
+	IdleTimeoutSeconds  int      `json:"idleTimeoutSeconds" binding:"omitempty,gte=0"` // 0 disables the idle timeout
+	MaxConnections      int      `json:"maxConnections" binding:"omitempty,gte=0"`     // 0 means unlimited
+}
+
+func (p *WebSocketPolicy) pingInterval() time.Duration {
+	if p == nil || p.PingIntervalSeconds <= 0 {
+		return DefaultWSPingIntervalSeconds * time.Second
+	}
+	return time.Duration(p.PingIntervalSeconds) * time.Second
+}
+
+func (p *WebSocketPolicy) pongTimeout() time.Duration {
+	if p == nil || p.PongTimeoutSeconds <= 0 {
+		return DefaultWSPongTimeoutSeconds * time.Second
+	}
+	return time.Duration(p.PongTimeoutSeconds) * time.Second
+}
+
+func (p *WebSocketPolicy) idleTimeout() time.Duration {
+	if p == nil || p.IdleTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(p.IdleTimeoutSeconds) * time.Second
+}
+
+func (p *WebSocketPolicy) maxMessageBytes() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.MaxMessageBytes
+}
+
+func (p *WebSocketPolicy) maxConnections() int {
+	if p == nil {
+		return 0
+	}
+	return p.MaxConnections
+}
+
+// AllowsOrigin reports whether origin may open a connection. Requests without an
+// Origin header come from non-browser clients and are always allowed.
+func (p *WebSocketPolicy) AllowsOrigin(origin string) bool {
+	if p == nil || len(p.AllowedOrigins) == 0 || origin == "" {
+		return true
+	}
+	return utils.MatchOrigin(p.AllowedOrigins, origin)
+}
+
+// WebSocketConnection describes one live proxied connection.
+type WebSocketConnection struct {
+	ID                 string    `json:"id"`
+	BackendID          string    `json:"backendId"`
+	PathPrefix         string    `json:"pathPrefix"`
+	EndpointID         uint      `json:"endpointId"`
+	EndpointURL        string    `json:"endpointUrl"`
+	ClientIP           string    `json:"clientIp"`
+	Path               string    `json:"path"`
+	Subprotocol        string    `json:"subprotocol,omitempty"`
+	StartedAt          time.Time `json:"startedAt"`
+	MessagesFromClient int64     `json:"messagesFromClient"`
+	BytesFromClient    int64     `json:"bytesFromClient"`
+	MessagesToClient   int64     `json:"messagesToClient"`
+	BytesToClient      int64     `json:"bytesToClient"`
+}
+
+// wsRegistry tracks live sessions and the slots reserved per backend.
+type wsRegistry struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	sessions map[string]*wsSession
+}
+
+// acquire reserves a slot for backendID; max <= 0 means unlimited.
+func (reg *wsRegistry) acquire(backendID string, max int) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.counts == nil {
+		reg.counts = make(map[string]int)
+	}
+	if max > 0 && reg.counts[backendID] >= max {
+		return false
+	}
+	reg.counts[backendID]++
+	return true
+}
+
+func (reg *wsRegistry) release(backendID string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.counts[backendID] <= 1 {
+		delete(reg.counts, backendID)
+		return
+	}
+	reg.counts[backendID]--
+}
+
+func (reg *wsRegistry) add(s *wsSession) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.sessions == nil {
+		reg.sessions = make(map[string]*wsSession)
+	}
+	reg.sessions[s.info.ID] = s
+}
+
+func (reg *wsRegistry) remove(id string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.sessions, id)
+}
+
+// find returns the sessions matching backendID ("" for all).
+func (reg *wsRegistry) find(backendID string) []*wsSession {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	out := make([]*wsSession, 0, len(reg.sessions))
+	for _, s := range reg.sessions {
+		if backendID == "" || s.info.BackendID == backendID {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (reg *wsRegistry) get(id string) *wsSession {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.sessions[id]
+}
+
+// WebSocketConnections lists live connections, oldest first; backendID "" lists all.
+func (g *Gateway) WebSocketConnections(backendID string) []WebSocketConnection {
+	sessions := g.wsConns.find(backendID)
+	out := make([]WebSocketConnection, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, s.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// CloseWebSocket force-closes one connection; false when it is not live.
+func (g *Gateway) CloseWebSocket(id string, code int, text string) bool {
+	s := g.wsConns.get(id)
+	if s == nil {
+		return false
+	}
+	s.finish(code, text, fmt.Sprintf("closed by admin with code %d", code))
+	return true
+}
+
+// CloseWebSockets force-closes every connection of a backend and returns how many.
+func (g *Gateway) CloseWebSockets(backendID string, code int, text string) int {
+	sessions := g.wsConns.find(backendID)
+	for _, s := range sessions {
+		s.finish(code, text, fmt.Sprintf("closed by admin with code %d", code))
+	}
+	return len(sessions)
+}
+
+// ValidCloseCode reports whether code may be sent in a close frame by the gateway.
+func ValidCloseCode(code int) bool {
+	switch code {
+	case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.ClosePolicyViolation,
+		websocket.CloseInternalServerErr, websocket.CloseServiceRestart, websocket.CloseTryAgainLater:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// wsHandshakeHeader reports whether a header belongs to one hop of the
+// handshake (hop-by-hop or Sec-WebSocket-*) and must not be passed on.
+func wsHandshakeHeader(canonical string) bool {
+	switch {
+	case strings.HasPrefix(canonical, "Sec-Websocket-"),
+		canonical == "Connection", canonical == "Upgrade", canonical == "Host",
+		canonical == "Keep-Alive", canonical == "Te", canonical == "Trailer",
+		canonical == "Transfer-Encoding", canonical == "Proxy-Connection":
+		return true
+	}
+	return false
+}
+
+// wsDialHeaders copies the client's handshake headers minus the ones the dialer
+// generates itself; forwarding Sec-WebSocket-* makes the dial fail.
+func wsDialHeaders(r *http.Request) http.Header {
+	header := make(http.Header)
+	for name, values := range r.Header {
+		canonical := http.CanonicalHeaderKey(name)
+		if wsHandshakeHeader(canonical) {
+			continue
+		}
+		header[canonical] = append([]string(nil), values...)
+	}
+	forwarded := clientIP(r)
+	if prior := header.Get("X-Forwarded-For"); prior != "" {
+		forwarded = prior + ", " + forwarded
+	}
+	header.Set("X-Forwarded-For", forwarded)
+	return header
+}
+
+// relayWSRefusal passes a backend's refusal of the upgrade (e.g. 401 with
+// WWW-Authenticate, 429 with Retry-After) to the client with its headers and
+// up to 64 KiB of its body. The gateway's own correlation headers stay.
+func relayWSRefusal(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	header := w.Header()
+	for name, values := range resp.Header {
+		switch name {
+		case "Content-Length", RequestIDHeader, TraceParentHeader, "Tracestate":
+			continue
+		}
+		if !wsHandshakeHeader(name) {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, io.LimitReader(resp.Body, 64<<10))
+}
+
+// proxyWebSocket dials the backend first so the negotiated subprotocol can be
+// returned to the client, then pumps frames both ways until either side closes.
+func (g *Gateway) proxyWebSocket(w http.ResponseWriter, r *http.Request, matchedConfig *BackendConfig) {
+	policy := matchedConfig.WebSocket
+
+	// 1. Policy checks before any upstream work
+	if origin := r.Header.Get("Origin"); !policy.AllowsOrigin(origin) {
+		log.Printf("WARN: WebSocket origin %q rejected for route %s", origin, matchedConfig.PathPrefix)
+		http.Error(w, "403 Forbidden: Origin not allowed.", http.StatusForbidden)
+		return
+	}
+	if !g.wsConns.acquire(matchedConfig.ID, policy.maxConnections()) {
+		log.Printf("WARN: Route %s reached its WebSocket connection limit (%d)", matchedConfig.PathPrefix, policy.maxConnections())
+		w.Header().Set("Retry-After", "5")
+		g.writeError(w, r, matchedConfig, http.StatusServiceUnavailable, "WebSocket connection limit reached.")
+		return
+	}
+	defer g.wsConns.release(matchedConfig.ID)
+
+	// 2. Load Balancing & Target Selection
+	targetEndpoint := pickEndpoint(r.Context(), matchedConfig)
+	if targetEndpoint == nil {
+		log.Printf("ERROR: Backend [%s] has no healthy WS targets for path %s", matchedConfig.ID, r.URL.Path)
+		g.writeError(w, r, matchedConfig, http.StatusServiceUnavailable, "No healthy WS targets found.")
+		return
+	}
+
+	backendURL := targetEndpoint.URLParsed
+	proxyScheme := "ws"
+	if backendURL.Scheme == "https" || backendURL.Scheme == "wss" {
+		proxyScheme = "wss"
+	}
+	targetWSURL := url.URL{
+		Scheme:   proxyScheme,
+		Host:     backendURL.Host,
+		Path:     upstreamPath(backendURL, matchedConfig.PathPrefix, r.URL.Path),
+		RawQuery: r.URL.RawQuery,
+	}
+
+	// 3. Dial Backend WebSocket Server
+	requestID := r.Header.Get(RequestIDHeader)
+	tlsConfig, err := matchedConfig.upstreamTLSConfig()
+	if err != nil {
+		g.writeError(w, r, matchedConfig, http.StatusBadGateway, "upstream TLS settings are invalid.")
+		return
+	}
+	dialHeader := wsDialHeaders(r)
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: wsHandshakeTimeout,
+		Subprotocols:     websocket.Subprotocols(r),
+		TLSClientConfig:  tlsConfig,
+	}
+	dialCtx, dialSpan := tracing.Tracer().Start(r.Context(), "gateway.upstream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", backendURL.Host)))
+	injectTraceContext(dialCtx, dialHeader)
+	backendConn, backendResp, err := dialer.DialContext(dialCtx, targetWSURL.String(), dialHeader)
+	if err != nil {
+		dialSpan.RecordError(err)
+		dialSpan.SetStatus(codes.Error, err.Error())
+	}
+	dialSpan.End()
+	if err != nil {
+		log.Printf("ERROR: Failed to dial backend WS %s for request %s: %v", targetWSURL.String(), requestID, err)
+		// Relay a backend refusal (e.g. 401/403) as-is; anything else is a bad gateway.
+		if backendResp != nil && backendResp.StatusCode != http.StatusSwitchingProtocols {
+			relayWSRefusal(w, backendResp)
+			return
+		}
+		g.writeError(w, r, matchedConfig, http.StatusBadGateway, "Backend WebSocket connection failed.")
+		return
+	}
+	defer backendConn.Close()
+
+	// 4. Upgrade Client Connection with the backend's negotiated subprotocol
+	respHeader := http.Header{}
+	if requestID != "" {
+		respHeader.Set(RequestIDHeader, requestID)
+	}
+	if sub := backendConn.Subprotocol(); sub != "" {
+		respHeader.Set("Sec-WebSocket-Protocol", sub)
+	}
+	if backendResp != nil {
+		for _, cookie := range backendResp.Header.Values("Set-Cookie") {
+			respHeader.Add("Set-Cookie", cookie)
+		}
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     func(*http.Request) bool { return true }, // already checked against the policy
+	}
+	clientConn, err := upgrader.Upgrade(w, r, respHeader)
+	if err != nil {
+		log.Printf("ERROR: Failed to upgrade client to WebSocket: %v", err)
+		backendConn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "client upgrade failed"), time.Now().Add(wsWriteWait))
+		return // Upgrade failure already sends a 4xx response
+	}
+	defer clientConn.Close()
+
+	log.Printf("INFO: Proxying WS from %s to %s (subprotocol %q)", r.RemoteAddr, targetWSURL.String(), backendConn.Subprotocol())
+
+	// 5. Bidirectional pumping with keepalive and idle timeout
+	session := newWSSession(clientConn, backendConn, policy, WebSocketConnection{
+		ID:          uuid.New().String(),
+		BackendID:   matchedConfig.ID,
+		PathPrefix:  matchedConfig.PathPrefix,
+		EndpointID:  targetEndpoint.ID,
+		EndpointURL: targetEndpoint.URL,
+		ClientIP:    clientIP(r),
+		Path:        r.URL.Path,
+		Subprotocol: backendConn.Subprotocol(),
+		StartedAt:   time.Now(),
+	})
+	g.wsConns.add(session)
+	reason := session.run()
+	g.wsConns.remove(session.info.ID)
+
+	log.Printf("INFO: WebSocket connection closed for route %s: %s", matchedConfig.PathPrefix, reason)
+}
+
+// wsSession pumps frames between the client and backend connections.
+type wsSession struct {
+	client, backend *websocket.Conn
+	policy          *WebSocketPolicy
+	info            WebSocketConnection // static fields; counters live below
+	lastActivity    atomic.Int64        // unix nanos of the last data frame in either direction
+	msgsFromClient  atomic.Int64
+	bytesFromClient atomic.Int64
+	msgsToClient    atomic.Int64
+	bytesToClient   atomic.Int64
+	done            chan struct{}
+	closeOnce       sync.Once
+	reason          string
+}
+
+func newWSSession(client, backend *websocket.Conn, policy *WebSocketPolicy, info WebSocketConnection) *wsSession {
+	s := &wsSession{client: client, backend: backend, policy: policy, info: info, done: make(chan struct{})}
+	s.lastActivity.Store(time.Now().UnixNano())
+	return s
+}
+
+func (s *wsSession) snapshot() WebSocketConnection {
+	info := s.info
+	info.MessagesFromClient = s.msgsFromClient.Load()
+	info.BytesFromClient = s.bytesFromClient.Load()
+	info.MessagesToClient = s.msgsToClient.Load()
+	info.BytesToClient = s.bytesToClient.Load()
+	return info
+}
+
+// run blocks until the session ends and returns why it ended.
+func (s *wsSession) run() string {
+	if limit := s.policy.maxMessageBytes(); limit > 0 {
+		s.client.SetReadLimit(limit)
+		s.backend.SetReadLimit(limit)
+	}
+	s.keepAlive(s.client)
+	s.keepAlive(s.backend)
+
+	go s.pump(s.client, s.backend, "client", &s.msgsFromClient, &s.bytesFromClient)
+	go s.pump(s.backend, s.client, "backend", &s.msgsToClient, &s.bytesToClient)
+	if idle := s.policy.idleTimeout(); idle > 0 {
+		go s.watchIdle(idle)
+	}
+
+	<-s.done
+	return s.reason
+}
+
+// finish closes both sides once; code and text go to both peers.
+func (s *wsSession) finish(code int, text, reason string) {
+	s.closeOnce.Do(func() {
+		s.reason = reason
+		deadline := time.Now().Add(wsWriteWait)
+		msg := websocket.FormatCloseMessage(code, text)
+		s.client.WriteControl(websocket.CloseMessage, msg, deadline)
+		s.backend.WriteControl(websocket.CloseMessage, msg, deadline)
+		close(s.done)
+	})
+}
+
+// keepAlive pings conn on the policy interval and drops it if pongs stop.
+func (s *wsSession) keepAlive(conn *websocket.Conn) {
+	interval, timeout := s.policy.pingInterval(), s.policy.pongTimeout()
+	conn.SetReadDeadline(time.Now().Add(interval + timeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(interval + timeout))
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					s.finish(websocket.CloseGoingAway, "keepalive failed", fmt.Sprintf("ping failed: %v", err))
+					return
+				}
+			}
+		}
+	}()
+}
+
+// pump copies data frames from src to dst and forwards src's close code to dst.
+func (s *wsSession) pump(src, dst *websocket.Conn, side string, msgs, bytes *atomic.Int64) {
+	for {
+		messageType, p, err := src.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			var netErr net.Error
+			switch {
+			case errors.As(err, &closeErr):
+				code := closeErr.Code
+				if code == websocket.CloseNoStatusReceived || code == websocket.CloseAbnormalClosure {
+					code = websocket.CloseNormalClosure
+				}
+				s.finish(code, closeErr.Text, fmt.Sprintf("%s closed with code %d", side, closeErr.Code))
+			case errors.Is(err, websocket.ErrReadLimit):
+				s.finish(websocket.CloseMessageTooBig, "message too big", side+" exceeded the max message size")
+			case errors.As(err, &netErr) && netErr.Timeout():
+				s.finish(websocket.CloseGoingAway, "keepalive timeout", side+" stopped answering pings")
+			default:
+				s.finish(websocket.CloseGoingAway, "gateway closing", fmt.Sprintf("%s read failed: %v", side, err))
+			}
+			return
+		}
+		s.lastActivity.Store(time.Now().UnixNano())
+
+		if err := dst.WriteMessage(messageType, p); err != nil {
+			s.finish(websocket.CloseGoingAway, "gateway closing", fmt.Sprintf("write to peer of %s failed: %v", side, err))
+			return
+		}
+		msgs.Add(1)
+		bytes.Add(int64(len(p)))
+	}
+}
+
+// watchIdle ends the session when no data frame has flowed for idle.
+func (s *wsSession) watchIdle(idle time.Duration) {
+	ticker := time.NewTicker(idle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, s.lastActivity.Load())) >= idle {
+				s.finish(websocket.CloseGoingAway, "idle timeout", fmt.Sprintf("idle for %s", idle))
+				return
+			}
+		}
+	}
+}
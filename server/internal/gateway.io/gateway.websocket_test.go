@@ -0,0 +1,159 @@
+package gatewayio
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSDialHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.RemoteAddr = "10.0.0.9:5000"
+	for k, v := range map[string]string{
+		"Connection":             "Upgrade",
+		"Upgrade":                "websocket",
+		"Sec-WebSocket-Key":      "abc",
+		"Sec-WebSocket-Protocol": "chat",
+		"Authorization":          "Bearer x",
+		"X-Forwarded-For":        "203.0.113.7",
+	} {
+		r.Header.Set(k, v)
+	}
+	h := wsDialHeaders(r)
+	for _, name := range []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Protocol"} {
+		if h.Get(name) != "" {
+			t.Errorf("%s forwarded to the dialer", name)
+		}
+	}
+	if h.Get("Authorization") != "Bearer x" {
+		t.Error("Authorization not forwarded")
+	}
+	if got := h.Get("X-Forwarded-For"); got != "203.0.113.7, 10.0.0.9" {
+		t.Errorf("X-Forwarded-For = %q", got)
+	}
+}
+
+func TestRelayWSRefusal(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header: http.Header{
+			"Www-Authenticate":      {`Bearer realm="api"`},
+			"Retry-After":           {"30"},
+			"Set-Cookie":            {"a=1", "b=2"},
+			"Connection":            {"close"},
+			"Sec-Websocket-Version": {"13"},
+			"Content-Length":        {"999"},
+			RequestIDHeader:         {"upstream-id"},
+		},
+		Body: io.NopCloser(strings.NewReader("login required")),
+	}
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "gateway-id")
+	relayWSRefusal(w, resp)
+
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "login required" {
+		t.Fatalf("relayed %d %q", w.Code, w.Body.String())
+	}
+	h := w.Header()
+	if h.Get("WWW-Authenticate") != `Bearer realm="api"` || h.Get("Retry-After") != "30" || len(h.Values("Set-Cookie")) != 2 {
+		t.Errorf("refusal headers lost: %v", h)
+	}
+	if h.Get("Connection") != "" || h.Get("Sec-WebSocket-Version") != "" || h.Get("Content-Length") != "" {
+		t.Errorf("handshake headers relayed: %v", h)
+	}
+	if h.Get(RequestIDHeader) != "gateway-id" {
+		t.Errorf("request ID = %q, want the gateway's", h.Get(RequestIDHeader))
+	}
+}
+
+func TestValidCloseCode(t *testing.T) {
+	tests := map[int]bool{
+		websocket.CloseNormalClosure:    true,
+		websocket.CloseGoingAway:        true,
+		websocket.CloseTryAgainLater:    true,
+		websocket.CloseNoStatusReceived: false, // reserved, never sent on the wire
+		websocket.CloseAbnormalClosure:  false,
+		2999:                            false,
+		3000:                            true,
+		4999:                            true,
+		5000:                            false,
+	}
+	for code, want := range tests {
+		if got := ValidCloseCode(code); got != want {
+			t.Errorf("ValidCloseCode(%d) = %t, want %t", code, got, want)
+		}
+	}
+}
+
+func TestWebSocketInspector(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append(p, '!'))
+		}
+	}))
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	cfg := &BackendConfig{ID: "b1", PathPrefix: "/chat",
+		Endpoints: []*BackendEndpoint{{ID: 3, URL: backend.URL, URLParsed: backendURL, IsHealthy: true}}}
+	g := &Gateway{}
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.proxyWebSocket(w, r, cfg)
+	}))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(gateway.URL, "http")+"/chat/room", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for _, msg := range []string{"hi", "there"} {
+		client.WriteMessage(websocket.TextMessage, []byte(msg))
+		if _, p, err := client.ReadMessage(); err != nil || string(p) != msg+"!" {
+			t.Fatalf("echo of %q = %q, %v", msg, p, err)
+		}
+	}
+
+	conns := g.WebSocketConnections("b1")
+	if len(conns) != 1 || len(g.WebSocketConnections("other")) != 0 {
+		t.Fatalf("connections = %+v", conns)
+	}
+	c := conns[0]
+	if c.EndpointID != 3 || c.Path != "/chat/room" || c.MessagesFromClient != 2 || c.BytesFromClient != 7 || c.MessagesToClient != 2 || c.BytesToClient != 9 {
+		t.Errorf("connection = %+v", c)
+	}
+
+	if g.CloseWebSocket("unknown", websocket.CloseNormalClosure, "") {
+		t.Error("closed an unknown connection")
+	}
+	if !g.CloseWebSocket(c.ID, 4001, "maintenance") {
+		t.Fatal("live connection not found")
+	}
+	_, _, err = client.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != 4001 || closeErr.Text != "maintenance" {
+		t.Errorf("client saw %v, want close 4001", err)
+	}
+	for range 50 {
+		if len(g.WebSocketConnections("")) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("closed connection still listed")
+}
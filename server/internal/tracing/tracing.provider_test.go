@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"imanager.io/config"
+)
+
+func TestInitProviderSampleRatio(t *testing.T) {
+	ratio := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		ratio   *float64
+		sampled bool
+	}{
+		{"unset samples everything", nil, true},
+		{"zero samples nothing", ratio(0), false},
+		{"one", ratio(1), true},
+		{"negative clamps to zero", ratio(-1), false},
+		{"above one clamps to one", ratio(5), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := InitProvider(config.TracingConfig{SampleRatio: tt.ratio})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer shutdown(context.Background())
+
+			_, span := Tracer().Start(context.Background(), "root")
+			defer span.End()
+			if got := span.SpanContext().IsSampled(); got != tt.sampled {
+				t.Errorf("root span sampled = %t, want %t", got, tt.sampled)
+			}
+			if !span.SpanContext().TraceID().IsValid() {
+				t.Error("unsampled span has no trace ID to propagate")
+			}
+		})
+	}
+}
+
+func TestInitProviderFollowsParent(t *testing.T) {
+	zero := 0.0
+	shutdown, err := InitProvider(config.TracingConfig{SampleRatio: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	_, span := Tracer().Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "child")
+	defer span.End()
+	if !span.SpanContext().IsSampled() || span.SpanContext().TraceID() != parent.TraceID() {
+		t.Error("child of a sampled upstream trace was not sampled in the same trace")
+	}
+}
+
+func TestNewExporterRejectsUnknown(t *testing.T) {
+	if _, _, err := newExporter(config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+}
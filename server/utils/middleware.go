@@ -3,26 +3,71 @@ package utils
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"imanager.io/config"
 )
 
-// CORSMiddleware adds CORS headers to the response
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// Management API CORS defaults, used for fields left empty in config.yml.
+var (
+	defaultCORSOrigins = []string{"http://localhost:5173"}
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Authorization"}
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+// CORSMiddleware answers CORS for the management API according to cfg.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	origins := ManagementOrigins(cfg)
+	maxAge := 12 * time.Hour
+	if cfg.MaxAgeSeconds > 0 {
+		maxAge = time.Duration(cfg.MaxAgeSeconds) * time.Second
+	}
+	return cors.New(cors.Config{
+		AllowOriginFunc:  func(origin string) bool { return MatchOrigin(origins, origin) },
+		AllowMethods:     orDefault(cfg.AllowMethods, defaultCORSMethods),
+		AllowHeaders:     orDefault(cfg.AllowHeaders, defaultCORSHeaders),
+		ExposeHeaders:    orDefault(cfg.ExposeHeaders, []string{"Content-Length"}),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           maxAge,
+	})
+}
+
+// ManagementOrigins returns the origins allowed to call the management API.
+func ManagementOrigins(cfg config.CORSConfig) []string {
+	return orDefault(cfg.AllowOrigins, defaultCORSOrigins)
+}
 
-		c.Next()
+func orDefault(values, fallback []string) []string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+// MatchOrigin reports whether origin is in allowed. Entries may be "*" or use a
+// subdomain wildcard such as "https://*.example.com"; scheme and case follow the
+// browser's serialized origin, trailing slashes are ignored.
+func MatchOrigin(allowed []string, origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSuffix(entry, "/"))
+		if entry == "*" || entry == origin {
+			return true
+		}
+		// "https://*.example.com" matches any subdomain with the same scheme.
+		if i := strings.Index(entry, "://*."); i >= 0 {
+			scheme, suffix := entry[:i+3], entry[i+4:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+len(suffix) {
+				return true
+			}
+		}
 	}
+	return false
 }
 
 func EncryptPassword(password string) (string, error) {
@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	allowed := []string{"https://app.example.com/", "https://*.example.org", "HTTP://Local.Test:5173"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com/", true},
+		{"http://app.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"http://a.example.org", false},
+		{"http://local.test:5173", true},
+		{"http://local.test:5174", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := MatchOrigin(allowed, tt.origin); got != tt.want {
+			t.Errorf("MatchOrigin(%q) = %t, want %t", tt.origin, got, tt.want)
+		}
+	}
+	if !MatchOrigin([]string{"*"}, "https://anything.test") {
+		t.Error(`"*" did not match`)
+	}
+}